@@ -1,6 +1,6 @@
 module geekbrains/examples
 
-go 1.14
+go 1.21
 
 require (
 	github.com/gogo/protobuf v1.3.2
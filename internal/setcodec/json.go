@@ -0,0 +1,140 @@
+package setcodec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"strconv"
+)
+
+// jsonSet is JSON form of a set. JSON has no NaN and infinities,
+// so they are written as strings "NaN", "+Inf" and "-Inf".
+// Checksum is CRC-32C of the values array text without
+// whitespace, so reformatting keeps it valid, but any
+// change of the values themselves is detected
+type jsonSet struct {
+	Version  int             `json:"version"`
+	Kind     string          `json:"kind"`
+	Values   json.RawMessage `json:"values"`
+	Checksum uint32          `json:"checksum"`
+}
+
+// MarshalJSON returns JSON form of {values}
+func MarshalJSON[T any](values []T) ([]byte, error) {
+	kind, err := kindOf[T]()
+	if err != nil {
+		return nil, err
+	}
+	raw := make([]json.RawMessage, len(values))
+	for i, v := range values {
+		raw[i] = json.RawMessage(formatJSON(v))
+	}
+	js := jsonSet{Version: Version, Kind: kind.String()}
+	if js.Values, err = json.Marshal(raw); err != nil {
+		return nil, err
+	}
+	js.Checksum = crc32.Checksum(js.Values, crcTable)
+	return json.Marshal(js)
+}
+
+// UnmarshalJSON parses JSON form and verifies its checksum
+func UnmarshalJSON[T any](data []byte) ([]T, error) {
+	kind, err := kindOf[T]()
+	if err != nil {
+		return nil, err
+	}
+	var js jsonSet
+	if err := json.Unmarshal(data, &js); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	if js.Version != Version {
+		return nil, fmt.Errorf("%w: %d", ErrVersion, js.Version)
+	}
+	if js.Kind != kind.String() {
+		return nil, fmt.Errorf("%w: stored %s, expected %v", ErrType, js.Kind, kind)
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, js.Values); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	if sum := crc32.Checksum(compact.Bytes(), crcTable); sum != js.Checksum {
+		return nil, fmt.Errorf("%w: checksum %08x, expected %08x", ErrCorrupt, js.Checksum, sum)
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(js.Values, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	values := make([]T, len(raw))
+	for i, r := range raw {
+		v, err := parseJSON[T](r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: value %d: %v", ErrCorrupt, i, err)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func formatJSON[T any](v T) string {
+	var f float64
+	bitSize := 64
+	switch v := any(v).(type) {
+	case float32:
+		f, bitSize = float64(v), 32
+	case float64:
+		f = v
+	default:
+		return fmt.Sprint(v)
+	}
+	switch {
+	case math.IsNaN(f):
+		return `"NaN"`
+	case math.IsInf(f, 1):
+		return `"+Inf"`
+	case math.IsInf(f, -1):
+		return `"-Inf"`
+	}
+	// the shortest form, which parses back to the same float
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
+
+func parseJSON[T any](raw json.RawMessage) (T, error) {
+	var zero T
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		// only special floats are strings
+		_, isFloat32 := any(zero).(float32)
+		_, isFloat64 := any(zero).(float64)
+		if !isFloat32 && !isFloat64 || s != "NaN" && s != "+Inf" && s != "-Inf" {
+			return zero, fmt.Errorf("unexpected string %s", raw)
+		}
+		// ParseFloat accepts exactly these forms
+		raw = json.RawMessage(s)
+	}
+	var v any
+	var err error
+	switch any(zero).(type) {
+	case int:
+		var n int64
+		n, err = strconv.ParseInt(string(raw), 10, 64)
+		v = int(n)
+	case int64:
+		v, err = strconv.ParseInt(string(raw), 10, 64)
+	case uint64:
+		v, err = strconv.ParseUint(string(raw), 10, 64)
+	case float32:
+		var f float64
+		f, err = strconv.ParseFloat(string(raw), 32)
+		v = float32(f)
+	case float64:
+		v, err = strconv.ParseFloat(string(raw), 64)
+	}
+	if err != nil {
+		return zero, err
+	}
+	return v.(T), nil
+}
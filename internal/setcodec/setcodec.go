@@ -0,0 +1,303 @@
+// Package setcodec is a file format for sets of numbers.
+//
+// Binary layout, all numbers are little endian:
+//
+//	magic    "GSET"
+//	version  uint8
+//	kind     uint8, element type
+//	count    uint64
+//	values   count elements of kind size, sorted by their bits
+//	checksum uint32, CRC-32C of everything above
+//
+// Decoders read exactly one set and return an error
+// instead of partial data if it is corrupt or truncated
+package setcodec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"sort"
+)
+
+// Version is the current format version
+const Version = 1
+
+var magic = [4]byte{'G', 'S', 'E', 'T'}
+
+var (
+	// ErrCorrupt is returned for damaged or truncated data
+	ErrCorrupt = errors.New("setcodec: corrupt data")
+	// ErrVersion is returned for data of unknown format version
+	ErrVersion = errors.New("setcodec: unsupported version")
+	// ErrType is returned if element type isn't supported
+	// or doesn't match the stored one
+	ErrType = errors.New("setcodec: unsupported element type")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+type Kind uint8
+
+const (
+	KindInt64 Kind = iota + 1
+	KindUint64
+	KindFloat32
+	KindFloat64
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindInt64:
+		return "int64"
+	case KindUint64:
+		return "uint64"
+	case KindFloat32:
+		return "float32"
+	case KindFloat64:
+		return "float64"
+	}
+	return fmt.Sprintf("Kind(%d)", uint8(k))
+}
+
+func (k Kind) size() int {
+	if k == KindFloat32 {
+		return 4
+	}
+	return 8
+}
+
+// kindOf returns kind of T, int is stored as int64
+func kindOf[T any]() (Kind, error) {
+	var zero T
+	switch any(zero).(type) {
+	case int, int64:
+		return KindInt64, nil
+	case uint64:
+		return KindUint64, nil
+	case float32:
+		return KindFloat32, nil
+	case float64:
+		return KindFloat64, nil
+	}
+	return 0, fmt.Errorf("%w: %T", ErrType, zero)
+}
+
+func toBits[T any](v T) uint64 {
+	switch v := any(v).(type) {
+	case int:
+		return uint64(v)
+	case int64:
+		return uint64(v)
+	case uint64:
+		return v
+	case float32:
+		return uint64(math.Float32bits(v))
+	case float64:
+		return math.Float64bits(v)
+	}
+	panic("setcodec: unreachable")
+}
+
+func fromBits[T any](b uint64) T {
+	var v any
+	var zero T
+	switch any(zero).(type) {
+	case int:
+		v = int(b)
+	case int64:
+		v = int64(b)
+	case uint64:
+		v = b
+	case float32:
+		v = math.Float32frombits(uint32(b))
+	case float64:
+		v = math.Float64frombits(b)
+	}
+	return v.(T)
+}
+
+const headerSize = 4 + 1 + 1 + 8
+
+// Write writes {values} to {w}
+func Write[T any](w io.Writer, values []T) (int64, error) {
+	kind, err := kindOf[T]()
+	if err != nil {
+		return 0, err
+	}
+	// sorted, so equal sets give equal files
+	encoded := make([]uint64, len(values))
+	for i, v := range values {
+		encoded[i] = toBits(v)
+	}
+	sort.Slice(encoded, func(i, j int) bool { return encoded[i] < encoded[j] })
+
+	cw := &countWriter{w: w, crc: crc32.New(crcTable)}
+	header := make([]byte, headerSize)
+	copy(header, magic[:])
+	header[4] = Version
+	header[5] = byte(kind)
+	binary.LittleEndian.PutUint64(header[6:], uint64(len(values)))
+	if _, err := cw.Write(header); err != nil {
+		return cw.n, err
+	}
+
+	// values are written by chunks to keep memory flat
+	buf := make([]byte, 0, 4096)
+	for i, b := range encoded {
+		if kind == KindFloat32 {
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(b))
+		} else {
+			buf = binary.LittleEndian.AppendUint64(buf, b)
+		}
+		if len(buf)+8 > cap(buf) || i == len(encoded)-1 {
+			if _, err := cw.Write(buf); err != nil {
+				return cw.n, err
+			}
+			buf = buf[:0]
+		}
+	}
+
+	sum := binary.LittleEndian.AppendUint32(nil, cw.crc.Sum32())
+	_, err = cw.Write(sum)
+	return cw.n, err
+}
+
+// Read reads values written by Write, it doesn't read past the set
+func Read[T any](r io.Reader) ([]T, int64, error) {
+	kind, err := kindOf[T]()
+	if err != nil {
+		return nil, 0, err
+	}
+	cr := &countReader{r: r, crc: crc32.New(crcTable)}
+
+	header := make([]byte, headerSize)
+	if err := cr.readFull(header); err != nil {
+		return nil, cr.n, err
+	}
+	if !bytes.Equal(header[:4], magic[:]) {
+		return nil, cr.n, fmt.Errorf("%w: bad magic %q", ErrCorrupt, header[:4])
+	}
+	if header[4] != Version {
+		return nil, cr.n, fmt.Errorf("%w: %d", ErrVersion, header[4])
+	}
+	if stored := Kind(header[5]); stored != kind {
+		return nil, cr.n, fmt.Errorf("%w: stored %v, expected %v", ErrType, stored, kind)
+	}
+	count := binary.LittleEndian.Uint64(header[6:])
+
+	// don't trust count of possibly corrupt header for allocation
+	values := make([]T, 0, min(count, 1<<16))
+	size := kind.size()
+	buf := make([]byte, 4096/size*size)
+	for left := count; left > 0; {
+		chunk := buf[:min(left, uint64(len(buf)/size))*uint64(size)]
+		if err := cr.readFull(chunk); err != nil {
+			return nil, cr.n, err
+		}
+		for i := 0; i < len(chunk); i += size {
+			if size == 4 {
+				values = append(values, fromBits[T](uint64(binary.LittleEndian.Uint32(chunk[i:]))))
+			} else {
+				values = append(values, fromBits[T](binary.LittleEndian.Uint64(chunk[i:])))
+			}
+		}
+		left -= uint64(len(chunk) / size)
+	}
+
+	expect := cr.crc.Sum32()
+	sum := make([]byte, 4)
+	if err := cr.readFull(sum); err != nil {
+		return nil, cr.n, err
+	}
+	if got := binary.LittleEndian.Uint32(sum); got != expect {
+		return nil, cr.n, fmt.Errorf("%w: checksum %08x, expected %08x", ErrCorrupt, got, expect)
+	}
+	return values, cr.n, nil
+}
+
+// Marshal returns binary form of {values}
+func Marshal[T any](values []T) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := Write(&buf, values); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal parses binary form, {data} should contain exactly one set
+func Unmarshal[T any](data []byte) ([]T, error) {
+	values, n, err := Read[T](bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if n != int64(len(data)) {
+		return nil, fmt.Errorf("%w: %d bytes after the set", ErrCorrupt, int64(len(data))-n)
+	}
+	return values, nil
+}
+
+// Sets implement their Unmarshal and ReadFrom methods with helpers below,
+// {replace} gets decoded values only if all of them are read
+
+// UnmarshalTo parses binary form and passes values to {replace}
+func UnmarshalTo[T any](data []byte, replace func([]T) error) error {
+	values, err := Unmarshal[T](data)
+	if err != nil {
+		return err
+	}
+	return replace(values)
+}
+
+// UnmarshalJSONTo parses JSON form and passes values to {replace}
+func UnmarshalJSONTo[T any](data []byte, replace func([]T) error) error {
+	values, err := UnmarshalJSON[T](data)
+	if err != nil {
+		return err
+	}
+	return replace(values)
+}
+
+// ReadTo reads binary form from {r} and passes values to {replace}
+func ReadTo[T any](r io.Reader, replace func([]T) error) (int64, error) {
+	values, n, err := Read[T](r)
+	if err != nil {
+		return n, err
+	}
+	return n, replace(values)
+}
+
+type countWriter struct {
+	w   io.Writer
+	crc hash.Hash32
+	n   int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.crc.Write(p[:n])
+	return n, err
+}
+
+type countReader struct {
+	r   io.Reader
+	crc hash.Hash32
+	n   int64
+}
+
+// readFull reports truncated data as ErrCorrupt
+func (cr *countReader) readFull(p []byte) error {
+	n, err := io.ReadFull(cr.r, p)
+	cr.n += int64(n)
+	cr.crc.Write(p[:n])
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: truncated at byte %d", ErrCorrupt, cr.n)
+	}
+	return err
+}
@@ -0,0 +1,173 @@
+package setcodec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// sortedBits allows to compare sets regardless of order
+func sortedBits[T any](values []T) []uint64 {
+	res := make([]uint64, len(values))
+	for i, v := range values {
+		res[i] = toBits(v)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
+
+func roundTrip[T any](t *testing.T, values []T) {
+	t.Helper()
+
+	data, err := Marshal(values)
+	if err != nil {
+		t.Fatalf("marshal %T: %v", values, err)
+	}
+	got, err := Unmarshal[T](data)
+	if err != nil {
+		t.Fatalf("unmarshal %T: %v", values, err)
+	}
+	if fmt.Sprint(sortedBits(got)) != fmt.Sprint(sortedBits(values)) {
+		t.Errorf("binary %T: got %v, expect %v", values, got, values)
+	}
+
+	js, err := MarshalJSON(values)
+	if err != nil {
+		t.Fatalf("marshal json %T: %v", values, err)
+	}
+	got, err = UnmarshalJSON[T](js)
+	if err != nil {
+		t.Fatalf("unmarshal json %T: %v\n%s", values, err, js)
+	}
+	if fmt.Sprint(sortedBits(got)) != fmt.Sprint(sortedBits(values)) {
+		t.Errorf("json %T: got %v, expect %v", values, got, values)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	roundTrip(t, []int{3, -1, math.MaxInt64, math.MinInt64, 0})
+	roundTrip(t, []int64{})
+	roundTrip(t, []uint64{math.MaxUint64, 1})
+	roundTrip(t, []float32{1.1, float32(math.NaN()), float32(math.Inf(-1)),
+		float32(math.Copysign(0, -1)), 0, math.MaxFloat32, math.SmallestNonzeroFloat32})
+	roundTrip(t, []float64{0.1, math.NaN(), math.Inf(1), math.Copysign(0, -1)})
+
+	// a few write chunks
+	many := make([]float32, 10000)
+	for i := range many {
+		many[i] = float32(i) / 3
+	}
+	roundTrip(t, many)
+}
+
+func TestUnsupportedType(t *testing.T) {
+	if _, err := Marshal([]string{"a"}); !errors.Is(err, ErrType) {
+		t.Errorf("marshal strings: got %v, expect ErrType", err)
+	}
+	data, _ := Marshal([]float32{1})
+	if _, err := Unmarshal[float64](data); !errors.Is(err, ErrType) {
+		t.Errorf("unmarshal float32 as float64: got %v, expect ErrType", err)
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	data, _ := Marshal([]int{1, 2, 3, 4, 5})
+
+	// every truncation fails
+	for n := 0; n < len(data); n++ {
+		if _, err := Unmarshal[int](data[:n]); !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("truncated to %d bytes: got %v, expect ErrCorrupt", n, err)
+		}
+	}
+	// every changed bit fails, except version and kind bytes,
+	// which are checked earlier
+	for i := 0; i < len(data)*8; i++ {
+		broken := bytes.Clone(data)
+		broken[i/8] ^= 1 << (i % 8)
+		if _, err := Unmarshal[int](broken); err == nil {
+			t.Fatalf("bit %d flipped: no error", i)
+		}
+	}
+	if _, err := Unmarshal[int](append(bytes.Clone(data), 0)); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("trailing data: got %v, expect ErrCorrupt", err)
+	}
+
+	broken := bytes.Clone(data)
+	broken[4] = Version + 1
+	if _, err := Unmarshal[int](broken); !errors.Is(err, ErrVersion) {
+		t.Errorf("future version: got %v, expect ErrVersion", err)
+	}
+
+	// huge count in header must not allocate all of it
+	broken = bytes.Clone(data)
+	copy(broken[6:], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x0f})
+	if _, err := Unmarshal[int](broken); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("huge count: got %v, expect ErrCorrupt", err)
+	}
+}
+
+func TestReadStream(t *testing.T) {
+	// two sets in a row, Read must stop after the first
+	var buf bytes.Buffer
+	n1, _ := Write(&buf, []int{1, 2})
+	Write(&buf, []int{3})
+
+	first, n, err := Read[int](&buf)
+	if err != nil || n != n1 || len(first) != 2 {
+		t.Fatalf("first set: %v %d %v", first, n, err)
+	}
+	second, _, err := Read[int](&buf)
+	if err != nil || fmt.Sprint(second) != "[3]" {
+		t.Fatalf("second set: %v %v", second, err)
+	}
+}
+
+func TestCorruptJSON(t *testing.T) {
+	js, _ := MarshalJSON([]float64{1.5, math.NaN()})
+	if !strings.Contains(string(js), `"NaN"`) {
+		t.Errorf("NaN should be a string: %s", js)
+	}
+
+	cases := map[string]string{
+		"changed value":   strings.Replace(string(js), "1.5", "2.5", 1),
+		"rewritten value": strings.Replace(string(js), "1.5", "15e-1", 1),
+		"removed value":   strings.Replace(string(js), `1.5,`, ``, 1),
+		"bad value":       strings.Replace(string(js), "1.5", `"x"`, 1),
+		"truncated":       string(js[:len(js)-5]),
+		"wrong checksum":  strings.Replace(string(js), `"checksum":`, `"checksum":1`, 1),
+	}
+	for name, data := range cases {
+		if _, err := UnmarshalJSON[float64]([]byte(data)); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: got %v, expect ErrCorrupt", name, err)
+		}
+	}
+
+	// whitespace isn't a part of checksum
+	var indented bytes.Buffer
+	json.Indent(&indented, js, "", "  ")
+	if got, err := UnmarshalJSON[float64](indented.Bytes()); err != nil || len(got) != 2 {
+		t.Errorf("indented: got %v, %v", got, err)
+	}
+}
+
+func TestStrictJSON(t *testing.T) {
+	// valid checksum, so only values are checked
+	set := func(values string) []byte {
+		sum := crc32.Checksum([]byte(values), crcTable)
+		return []byte(fmt.Sprintf(`{"version":1,"kind":%q,"values":%s,"checksum":%d}`, KindInt64, values, sum))
+	}
+	if got, err := UnmarshalJSON[int64](set(`[5,-7]`)); err != nil || len(got) != 2 {
+		t.Fatalf("valid set: got %v, %v", got, err)
+	}
+	for _, values := range []string{`["5"]`, `["NaN"]`, `[5.0]`} {
+		if _, err := UnmarshalJSON[int64](set(values)); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: got %v, expect ErrCorrupt", values, err)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+// Package cron runs jobs periodically by intervals
+// or by cron expressions
+//
+//	s := cron.New(cron.RealClock{})
+//	s.Add("report", cron.MustParse("0 9 * * MON-FRI", moscow), cron.Options{}, sendReport)
+//	s.Add("ping", cron.Every(time.Minute), cron.Options{Jitter: time.Second}, ping)
+//	err := s.Run(ctx)
+package cron
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Overlap is a policy for activation of a job which is still running
+type Overlap int
+
+const (
+	// Skip drops activation while previous run is not finished
+	Skip Overlap = iota
+	// Queue runs job again right after the previous run,
+	// activations are not accumulated: at most one is queued
+	Queue
+)
+
+type Job func(ctx context.Context) error
+
+type Options struct {
+	Overlap Overlap
+	// Jitter delays each run by random duration in [0, Jitter)
+	Jitter time.Duration
+}
+
+// Info describes job state
+type Info struct {
+	Name string
+	// Last is the start time of the last run
+	Last time.Time
+	// Next is the planned start time including jitter
+	Next    time.Time
+	Running bool
+	Runs    int
+	Skipped int
+	LastErr error
+}
+
+type job struct {
+	name     string
+	schedule Schedule
+	opts     Options
+	fn       Job
+
+	info   Info
+	queued bool
+	// planned is the next start time without jitter,
+	// schedule goes on from it, so jitter doesn't accumulate
+	planned time.Time
+}
+
+type Scheduler struct {
+	clock Clock
+	// jitter returns random value in [0, n)
+	jitter func(n int64) int64
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	wake    chan struct{}
+	running bool
+}
+
+func New(clock Clock) *Scheduler {
+	return &Scheduler{
+		clock:  clock,
+		jitter: rand.Int63n,
+		jobs:   map[string]*job{},
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Add registers job, it can be called before or during Run
+func (s *Scheduler) Add(name string, schedule Schedule, opts Options, fn Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("job %q already exists", name)
+	}
+	// next run at the same time would be started in a busy loop
+	if d, ok := schedule.(interval); ok && d <= 0 {
+		return fmt.Errorf("job %q: interval %v is not positive", name, time.Duration(d))
+	}
+	j := &job{name: name, schedule: schedule, opts: opts, fn: fn}
+	j.info.Name = name
+	s.plan(j, schedule.Next(s.clock.Now()))
+	s.jobs[name] = j
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Info returns state of job {name}
+func (s *Scheduler) Info(name string) (Info, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[name]
+	if !ok {
+		return Info{}, false
+	}
+	return j.info, true
+}
+
+// Infos returns states of all jobs sorted by name
+func (s *Scheduler) Infos() []Info {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos := make([]Info, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		infos = append(infos, j.info)
+	}
+	sort.Slice(infos, func(a, b int) bool { return infos[a].Name < infos[b].Name })
+	return infos
+}
+
+// plan sets next start of {j} to {planned} with jitter,
+// zero time means never, s.mu should be held
+func (s *Scheduler) plan(j *job, planned time.Time) {
+	j.planned = planned
+	j.info.Next = planned
+	if !planned.IsZero() && j.opts.Jitter > 0 {
+		j.info.Next = planned.Add(time.Duration(s.jitter(int64(j.opts.Jitter))))
+	}
+}
+
+// Run starts jobs on schedule until ctx is canceled,
+// then waits for running jobs, which get canceled ctx
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return fmt.Errorf("scheduler is already running")
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	wg := sync.WaitGroup{}
+	defer func() {
+		wg.Wait()
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+
+	for {
+		now := s.clock.Now()
+		wait, ok := s.startDue(ctx, now, &wg)
+
+		var timerC <-chan time.Time
+		var timer Timer
+		if ok {
+			timer = s.clock.NewTimer(wait)
+			timerC = timer.C()
+		}
+		select {
+		case <-timerC:
+		case <-s.wake:
+		case <-ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// startDue starts jobs planned not later than {now}
+// and returns time until the nearest next run, false if there is none
+func (s *Scheduler) startDue(ctx context.Context, now time.Time, wg *sync.WaitGroup) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var nearest time.Time
+	for _, j := range s.jobs {
+		if !j.info.Next.IsZero() && !j.info.Next.After(now) {
+			s.activate(ctx, j, now, wg)
+			next := j.schedule.Next(j.planned)
+			if !next.IsZero() && !next.After(now) {
+				// missed activations are not caught up
+				next = j.schedule.Next(now)
+			}
+			s.plan(j, next)
+		}
+		if next := j.info.Next; !next.IsZero() && (nearest.IsZero() || next.Before(nearest)) {
+			nearest = next
+		}
+	}
+	if nearest.IsZero() {
+		return 0, false
+	}
+	return nearest.Sub(now), true
+}
+
+// activate starts job or applies overlap policy, s.mu should be held
+func (s *Scheduler) activate(ctx context.Context, j *job, now time.Time, wg *sync.WaitGroup) {
+	if j.info.Running {
+		if j.opts.Overlap == Queue {
+			j.queued = true
+		} else {
+			j.info.Skipped++
+		}
+		return
+	}
+
+	j.info.Running = true
+	j.info.Last = now
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			err := j.fn(ctx)
+
+			s.mu.Lock()
+			j.info.Runs++
+			j.info.LastErr = err
+			if !j.queued || ctx.Err() != nil {
+				j.info.Running = false
+				s.mu.Unlock()
+				return
+			}
+			j.queued = false
+			j.info.Last = s.clock.Now()
+			s.mu.Unlock()
+		}
+	}()
+}
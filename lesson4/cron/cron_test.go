@@ -0,0 +1,220 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2021, 6, 11, 10, 0, 0, 0, time.UTC)
+
+// startScheduler runs scheduler in background, returns stop function
+func startScheduler(t *testing.T, s *Scheduler) (stop func() error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.Run(ctx) }()
+	return func() error {
+		cancel()
+		select {
+		case err := <-errCh:
+			return err
+		case <-time.After(time.Second):
+			t.Fatal("scheduler didn't stop")
+			return nil
+		}
+	}
+}
+
+func waitRun(t *testing.T, runs chan time.Time) time.Time {
+	t.Helper()
+	select {
+	case at := <-runs:
+		return at
+	case <-time.After(time.Second):
+		t.Fatal("job didn't run")
+		return time.Time{}
+	}
+}
+
+func TestInterval(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	s := New(clock)
+	runs := make(chan time.Time, 10)
+	s.Add("tick", Every(time.Minute), Options{}, func(ctx context.Context) error {
+		runs <- clock.Now()
+		return nil
+	})
+	stop := startScheduler(t, s)
+
+	for i := 1; i <= 3; i++ {
+		clock.WaitForTimers(1)
+		clock.Advance(time.Minute)
+		if at := waitRun(t, runs); !at.Equal(epoch.Add(time.Duration(i) * time.Minute)) {
+			t.Errorf("run %d at %v", i, at)
+		}
+	}
+	if err := stop(); err != context.Canceled {
+		t.Errorf("got error %v, expected %v", err, context.Canceled)
+	}
+
+	info, _ := s.Info("tick")
+	if info.Runs != 3 || !info.Last.Equal(epoch.Add(3*time.Minute)) || !info.Next.Equal(epoch.Add(4*time.Minute)) {
+		t.Errorf("unexpected info: %+v", info)
+	}
+}
+
+func TestCronSchedule(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	s := New(clock)
+	runs := make(chan time.Time, 10)
+	s.Add("hourly", MustParse("15 * * * *", time.UTC), Options{}, func(ctx context.Context) error {
+		runs <- clock.Now()
+		return errors.New("failed")
+	})
+	stop := startScheduler(t, s)
+
+	clock.WaitForTimers(1)
+	clock.Advance(10 * time.Minute)
+	clock.WaitForTimers(1)
+	clock.Advance(5 * time.Minute)
+	if at := waitRun(t, runs); !at.Equal(epoch.Add(15 * time.Minute)) {
+		t.Errorf("run at %v", at)
+	}
+	stop()
+
+	info, _ := s.Info("hourly")
+	if info.LastErr == nil || !info.Next.Equal(epoch.Add(75*time.Minute)) {
+		t.Errorf("unexpected info: %+v", info)
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	for _, policy := range []Overlap{Skip, Queue} {
+		clock := NewFakeClock(epoch)
+		s := New(clock)
+		release := make(chan struct{})
+		runs := make(chan time.Time, 10)
+		s.Add("slow", Every(time.Minute), Options{Overlap: policy}, func(ctx context.Context) error {
+			runs <- clock.Now()
+			<-release
+			return nil
+		})
+		stop := startScheduler(t, s)
+
+		clock.WaitForTimers(1)
+		clock.Advance(time.Minute)
+		waitRun(t, runs)
+		// two activations while the first run is in progress
+		for i := 0; i < 2; i++ {
+			clock.WaitForTimers(1)
+			clock.Advance(time.Minute)
+		}
+		clock.WaitForTimers(1)
+		close(release)
+
+		if policy == Queue {
+			// only one activation is queued
+			waitRun(t, runs)
+		}
+		stop()
+
+		info, _ := s.Info("slow")
+		switch policy {
+		case Skip:
+			if info.Runs != 1 || info.Skipped != 2 {
+				t.Errorf("skip: unexpected info %+v", info)
+			}
+		case Queue:
+			if info.Runs != 2 || info.Skipped != 0 {
+				t.Errorf("queue: unexpected info %+v", info)
+			}
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	s := New(clock)
+	s.jitter = func(n int64) int64 { return n / 2 }
+	s.Add("jittered", Every(time.Minute), Options{Jitter: 10 * time.Second}, func(ctx context.Context) error {
+		return nil
+	})
+
+	info, _ := s.Info("jittered")
+	if expect := epoch.Add(time.Minute + 5*time.Second); !info.Next.Equal(expect) {
+		t.Errorf("got next %v, expected %v", info.Next, expect)
+	}
+}
+
+func TestJitterDoesNotDrift(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	s := New(clock)
+	// the worst case: every run is delayed almost by whole jitter
+	const jitter = 10 * time.Second
+	s.jitter = func(n int64) int64 { return n - 1 }
+	runs := make(chan time.Time, 10)
+	s.Add("jittered", Every(time.Minute), Options{Jitter: jitter}, func(ctx context.Context) error {
+		runs <- clock.Now()
+		return nil
+	})
+	stop := startScheduler(t, s)
+	defer stop()
+
+	for n := 1; n <= 5; n++ {
+		clock.WaitForTimers(1)
+		info, _ := s.Info("jittered")
+		clock.Advance(info.Next.Sub(clock.Now()))
+		at := waitRun(t, runs)
+		planned := epoch.Add(time.Duration(n) * time.Minute)
+		if at.Before(planned) || !at.Before(planned.Add(jitter)) {
+			t.Fatalf("run %d at %v, expected in [%v, %v)", n, at, planned, planned.Add(jitter))
+		}
+	}
+}
+
+func TestCancelRunningJob(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	s := New(clock)
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	s.Add("long", Every(time.Minute), Options{}, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+		return ctx.Err()
+	})
+	stop := startScheduler(t, s)
+
+	clock.WaitForTimers(1)
+	clock.Advance(time.Minute)
+	<-started
+	stop()
+	// Run returns only after running job finished
+	select {
+	case <-canceled:
+	default:
+		t.Error("Run returned before job finished")
+	}
+}
+
+func TestDuplicateJob(t *testing.T) {
+	s := New(NewFakeClock(epoch))
+	noop := func(ctx context.Context) error { return nil }
+	if err := s.Add("a", Every(time.Second), Options{}, noop); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("a", Every(time.Second), Options{}, noop); err == nil {
+		t.Error("expected error on duplicate job")
+	}
+}
+
+func TestBadInterval(t *testing.T) {
+	s := New(NewFakeClock(epoch))
+	noop := func(ctx context.Context) error { return nil }
+	for _, d := range []time.Duration{0, -time.Second} {
+		if err := s.Add("b", Every(d), Options{}, noop); err == nil {
+			t.Errorf("expected error on interval %v", d)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule returns the next activation time after {t}
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// Every runs job with fixed interval,
+// Scheduler.Add fails if {d} isn't positive
+func Every(d time.Duration) Schedule {
+	return interval(d)
+}
+
+type interval time.Duration
+
+func (i interval) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(i))
+}
+
+// Expr is a parsed 5-field cron expression
+//
+//	minute hour day-of-month month day-of-week
+//
+// Fields support "*", lists "1,5", ranges "1-5", steps "*/15" or "0-30/10"
+// and names for months (JAN-DEC) and weekdays (SUN-SAT, 7 is Sunday too).
+// As in classic cron, when both day fields are restricted
+// the day matches if any of them matches. A field starting
+// with "*", like "*/2", isn't restricted, then both must match
+type Expr struct {
+	minute, hour, dom, month, dow uint64 // bitmasks
+	domAny, dowAny                bool
+	loc                           *time.Location
+}
+
+type field struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{min: 0, max: 59}
+	hourField   = field{min: 0, max: 23}
+	domField    = field{min: 1, max: 31}
+	monthField  = field{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = field{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// Parse parses cron expression evaluated in {loc}, nil means time.Local
+func Parse(expr string, loc *time.Location) (*Expr, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q: expected 5 fields, got %d", expr, len(fields))
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	e := &Expr{loc: loc}
+	var err error
+	parsers := []struct {
+		dst *uint64
+		f   field
+	}{
+		{&e.minute, minuteField},
+		{&e.hour, hourField},
+		{&e.dom, domField},
+		{&e.month, monthField},
+		{&e.dow, dowField},
+	}
+	for i, p := range parsers {
+		if *p.dst, err = parseField(fields[i], p.f); err != nil {
+			return nil, fmt.Errorf("cron %q: field %d: %w", expr, i+1, err)
+		}
+	}
+	// 7 is Sunday
+	if e.dow&(1<<7) != 0 {
+		e.dow |= 1
+	}
+	e.domAny = strings.HasPrefix(fields[2], "*")
+	e.dowAny = strings.HasPrefix(fields[4], "*")
+	return e, nil
+}
+
+// MustParse is like Parse but panics on error
+func MustParse(expr string, loc *time.Location) *Expr {
+	e, err := Parse(expr, loc)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+func parseField(s string, f field) (uint64, error) {
+	var mask uint64
+	for _, part := range strings.Split(s, ",") {
+		rangePart, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			rangePart = part[:i]
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+		default:
+			v, err := f.value(rangePart)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			// "5/10" means from 5 to max
+			if step == 1 {
+				hi = v
+			}
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("bad range in %q", part)
+		}
+		for v := lo; v <= hi; v += step {
+			mask |= 1 << uint(v)
+		}
+	}
+	return mask, nil
+}
+
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("bad value %q", s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+func has(mask uint64, v int) bool {
+	return mask&(1<<uint(v)) != 0
+}
+
+func (e *Expr) dayMatches(t time.Time) bool {
+	dom, dow := has(e.dom, t.Day()), has(e.dow, int(t.Weekday()))
+	if e.domAny || e.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first matching minute after {t},
+// zero time if nothing matches within 5 years (e.g. "0 0 30 2 *")
+func (e *Expr) Next(t time.Time) time.Time {
+	t = t.In(e.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case !has(e.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, e.loc)
+		case !e.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, e.loc)
+		case !has(e.hour, t.Hour()):
+			// absolute time step works across DST changes,
+			// where time.Date may return the same hour again
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+		case !has(e.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,82 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNext(t *testing.T) {
+	utc3 := time.FixedZone("UTC+3", 3*60*60)
+	// Friday
+	base := time.Date(2021, 6, 11, 10, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		expr   string
+		loc    *time.Location
+		expect time.Time
+	}{
+		{"* * * * *", time.UTC, time.Date(2021, 6, 11, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.UTC, time.Date(2021, 6, 11, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * *", time.UTC, time.Date(2021, 6, 12, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * MON-FRI", time.UTC, time.Date(2021, 6, 14, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan,jul *", time.UTC, time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"5/20 10 * * *", time.UTC, time.Date(2021, 6, 11, 10, 45, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.UTC, time.Date(2021, 6, 13, 0, 0, 0, 0, time.UTC)},
+		// day of month OR day of week
+		{"0 0 13 * 1", time.UTC, time.Date(2021, 6, 13, 0, 0, 0, 0, time.UTC)},
+		// but AND if one of them starts with "*": odd day and Friday
+		{"0 0 */2 * FRI", time.UTC, time.Date(2021, 6, 25, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.UTC, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// 10:30 UTC is 13:30 in UTC+3
+		{"0 14 * * *", utc3, time.Date(2021, 6, 11, 14, 0, 0, 0, utc3)},
+		{"0 0 30 2 *", time.UTC, time.Time{}},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.expr, tt.loc)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.expr, err)
+			continue
+		}
+		if got := e.Next(base); !got.Equal(tt.expect) {
+			t.Errorf("%q: got %v, expected %v", tt.expr, got, tt.expect)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	bad := []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"10-5 * * * *",
+		"a * * * *",
+	}
+	for _, expr := range bad {
+		if _, err := Parse(expr, time.UTC); err == nil {
+			t.Errorf("%q: expected error", expr)
+		}
+	}
+}
+
+func TestNextDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	// 2021-03-14 02:00 doesn't exist, 2021-11-07 01:00 happens twice
+	e := MustParse("30 * * * *", loc)
+	got := e.Next(time.Date(2021, 3, 14, 1, 45, 0, 0, loc))
+	if expect := time.Date(2021, 3, 14, 3, 30, 0, 0, loc); !got.Equal(expect) {
+		t.Errorf("spring forward: got %v, expected %v", got, expect)
+	}
+
+	first := e.Next(time.Date(2021, 11, 7, 0, 45, 0, 0, loc))
+	second := e.Next(first)
+	if second.Sub(first) != time.Hour {
+		t.Errorf("fall back: got %v and %v, expected an hour between", first, second)
+	}
+}
@@ -4,50 +4,49 @@ import (
 	"context"
 	"log"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
+
+	"geekbrains/examples/lesson4/supervisor"
 )
 
 const stopTimeout = 1 * time.Second
 
 func main() {
 	log.Printf("Started pid=%d\n", os.Getpid())
-	// setup signal handler
-	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM)
-	defer cancel()
 
-	workUntilTerm(ctx, superWork10Sec)
+	if err := workUntilTerm(context.Background(), superWork10Sec); err != nil {
+		log.Println(err)
+	}
 
 	log.Println("Finished")
 }
 
-func workUntilTerm(ctx context.Context, task func()) {
-	workDone := make(chan struct{})
-	for {
-		// do work
-		go func() {
-			task()
-			workDone <- struct{}{}
-		}()
-		// wait done or cancel
-		select {
-		case <-ctx.Done():
-			log.Println("break on signal!")
-			// after cancel signal
-			// wait {stopTimeout} to work done or exit
-			timeout, tCancel := context.WithTimeout(context.Background(), stopTimeout)
-			defer tCancel()
+// workUntilTerm repeats task until SIGTERM or SIGINT,
+// then waits {stopTimeout} for the current run to finish
+func workUntilTerm(ctx context.Context, task func()) error {
+	sv := supervisor.New(stopTimeout, supervisor.Spec{
+		Name:    "work",
+		Restart: supervisor.Always,
+		Run: func(ctx context.Context) error {
+			// task doesn't know about ctx, so run it aside
+			// and let supervisor give up after stopTimeout
+			workDone := make(chan struct{})
+			go func() {
+				defer close(workDone)
+				task()
+			}()
 			select {
-			case <-timeout.Done():
-				log.Println("timeout expires")
 			case <-workDone:
+				return nil
+			case <-ctx.Done():
+				log.Println("break on signal!")
+				<-workDone
 				log.Println("work done")
+				return nil
 			}
-			return
-		case <-workDone:
-		}
-	}
+		},
+	})
+	return sv.RunWithSignals(ctx)
 }
 
 // simulates long work
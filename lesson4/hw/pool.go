@@ -1,41 +1,211 @@
 package main
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"geekbrains/examples/lesson4/observe"
+	"geekbrains/examples/lesson4/sched"
+)
+
+// ErrPoolClosed is returned on submit to a pool that is shutting down
+var ErrPoolClosed = errors.New("pool is closed")
+
+// Task is a job executed by Pool workers.
+// ctx is canceled when the pool is aborted, so long tasks should watch it
+type Task func(ctx context.Context)
+
+type poolState int
+
+const (
+	poolRunning  poolState = iota // accepts and executes tasks
+	poolDraining                  // executes queued tasks, rejects new ones
+	poolStopped                   // neither accepts nor starts tasks
+)
 
 type Pool struct {
-	mx          sync.Mutex
-	wg          *sync.WaitGroup
-	jobsQ       chan func()
-	jobsQClosed bool
+	mx       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	jobsQ    sched.Scheduler
+	capacity int
+	state    poolState
+	observer observe.Observer
+
+	// ctx is passed to every task, cancel aborts running tasks
+	ctx    context.Context
+	cancel context.CancelFunc
+	// done is closed when all workers finished
+	done chan struct{}
 }
 
 // NewPool creates new Pool with {size} workers
+// and FIFO queue for {size} pending tasks
 func NewPool(size int) *Pool {
-	inQ := make(chan func(), size)
+	return NewScheduledPool(size, size, sched.NewFIFO())
+}
+
+// NewScheduledPool creates new Pool with {size} workers
+// and queue for {queueSize} pending tasks ordered by {scheduler}
+func NewScheduledPool(size, queueSize int, scheduler sched.Scheduler) *Pool {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		jobsQ:    scheduler,
+		capacity: queueSize,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	p.notEmpty = sync.NewCond(&p.mx)
+	p.notFull = sync.NewCond(&p.mx)
 
 	wg := sync.WaitGroup{}
 	// create workers
 	for i := 0; i < size; i++ {
 		wg.Add(1)
-		go func(workerId int, inQ chan func()) {
+		go func(workerId int) {
 			defer wg.Done()
-			for task := range inQ {
-				task()
+			for {
+				task, ok := p.next()
+				if !ok {
+					return
+				}
+				task(p.ctx)
 			}
-		}(i, inQ)
+		}(i)
 	}
+	go func() {
+		wg.Wait()
+		close(p.done)
+	}()
+
+	return p
+}
 
-	return &Pool{wg: &wg, jobsQ: inQ}
+// queuedTask keeps the submitted task along with its observed wrapper,
+// so tasks returned by ShutdownNow are not reported twice
+type queuedTask struct {
+	task Task
+	run  Task
+}
+
+// next blocks until a task is available,
+// returns false if the worker should exit
+func (p *Pool) next() (Task, bool) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	for p.jobsQ.Len() == 0 && p.state == poolRunning {
+		p.notEmpty.Wait()
+	}
+	if p.state == poolStopped {
+		return nil, false
+	}
+	job, ok := p.jobsQ.Pop()
+	if !ok {
+		return nil, false
+	}
+	p.notFull.Signal()
+	return job.Value.(queuedTask).run, true
+}
+
+// Submit puts task into the queue, blocks while queue is full
+func (p *Pool) Submit(task func()) error {
+	return p.SubmitCtx(func(context.Context) { task() })
+}
+
+// SubmitCtx puts context-aware task into the queue, blocks while queue is full
+func (p *Pool) SubmitCtx(task Task) error {
+	return p.SubmitClass(sched.Class{}, task)
+}
+
+// SubmitClass puts task with given priority and tenant into the queue,
+// blocks while queue is full
+func (p *Pool) SubmitClass(class sched.Class, task Task) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	for p.jobsQ.Len() >= p.capacity && p.state == poolRunning {
+		p.notFull.Wait()
+	}
+	if p.state != poolRunning {
+		if p.observer != nil {
+			p.observer.OnReject(ErrPoolClosed)
+		}
+		return ErrPoolClosed
+	}
+	queued := queuedTask{task: task, run: task}
+	if p.observer != nil {
+		run := observe.Wrap(p.observer, func() { task(p.ctx) })
+		queued.run = func(context.Context) { run() }
+		p.observer.OnSubmit()
+	}
+	p.jobsQ.Push(sched.Job{Class: class, Value: queued})
+	p.notEmpty.Signal()
+	return nil
+}
+
+// SetObserver sets hooks for task events, should be called before submits.
+// Task panics are recovered and reported to the observer
+func (p *Pool) SetObserver(o observe.Observer) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.observer = o
+}
+
+// Shutdown stops accepting tasks and waits until queued ones are done.
+// If ctx expires first, running tasks are canceled and
+// tasks which were never started are returned along with ctx error
+func (p *Pool) Shutdown(ctx context.Context) ([]Task, error) {
+	p.setState(poolDraining)
+
+	select {
+	case <-p.done:
+		p.cancel()
+		return nil, nil
+	case <-ctx.Done():
+		return p.ShutdownNow(), ctx.Err()
+	}
+}
+
+// ShutdownNow stops accepting tasks, cancels running ones
+// and returns tasks which were never started. It doesn't wait for workers
+func (p *Pool) ShutdownNow() []Task {
+	p.mx.Lock()
+	p.setStateLocked(poolStopped)
+	var notStarted []Task
+	for job, ok := p.jobsQ.Pop(); ok; job, ok = p.jobsQ.Pop() {
+		notStarted = append(notStarted, job.Value.(queuedTask).task)
+		if p.observer != nil {
+			p.observer.OnDrop()
+		}
+	}
+	p.mx.Unlock()
+
+	p.cancel()
+	return notStarted
 }
 
 // Close stops pool and waits until all workers finished
 func (p *Pool) Close() {
+	p.Shutdown(context.Background())
+}
+
+func (p *Pool) setState(state poolState) {
 	p.mx.Lock()
 	defer p.mx.Unlock()
 
-	if p.jobsQClosed == false {
-		close(p.jobsQ)
-		p.jobsQClosed = true
-		p.wg.Wait()
+	p.setStateLocked(state)
+}
+
+// setStateLocked moves pool forward to {state}, p.mx should be held
+func (p *Pool) setStateLocked(state poolState) {
+	if p.state < state {
+		p.state = state
 	}
+	// wake up everyone to recheck state
+	p.notEmpty.Broadcast()
+	p.notFull.Broadcast()
 }
@@ -1,10 +1,20 @@
 package main
 
 import (
+	"context"
 	"sync"
 	"testing"
+	"time"
+
+	"geekbrains/examples/lesson4/leak"
+	"geekbrains/examples/lesson4/observe"
+	"geekbrains/examples/lesson4/sched"
 )
 
+func TestMain(m *testing.M) {
+	leak.VerifyTestMain(m)
+}
+
 func newIncrementor() (counterPtr *int, incrementor func()) {
 	counter := 0
 	incOne := func() {
@@ -24,7 +34,9 @@ func TestIncrementorSingleThread(t *testing.T) {
 		pool := NewPool(1)
 		// put jobs
 		for i := 0; i < jobsCount; i++ {
-			pool.jobsQ <- inrementor
+			if err := pool.Submit(inrementor); err != nil {
+				t.Fatalf("submit failed: %v", err)
+			}
 		}
 		// wait until done
 		pool.Close()
@@ -47,7 +59,9 @@ func TestIncrementorMultiThread(t *testing.T) {
 		pool := NewPool(jobsCount)
 		// put jobs
 		for i := 0; i < jobsCount; i++ {
-			pool.jobsQ <- inrementor
+			if err := pool.Submit(inrementor); err != nil {
+				t.Fatalf("submit failed: %v", err)
+			}
 		}
 		// wait until done
 		pool.Close()
@@ -81,7 +95,9 @@ func TestMutexIncrementorMultiThread(t *testing.T) {
 		pool := NewPool(jobsCount)
 		// put jobs
 		for i := 0; i < jobsCount; i++ {
-			pool.jobsQ <- inrementor
+			if err := pool.Submit(inrementor); err != nil {
+				t.Fatalf("submit failed: %v", err)
+			}
 		}
 		// wait until done
 		pool.Close()
@@ -92,3 +108,214 @@ func TestMutexIncrementorMultiThread(t *testing.T) {
 		}
 	}
 }
+
+// TestShutdownDrain checks that Shutdown executes all queued tasks
+func TestShutdownDrain(t *testing.T) {
+	jobsCount := 100
+	counterPtr, incrementor := newMutexIncrementor()
+	pool := NewPool(4)
+	for i := 0; i < jobsCount; i++ {
+		if err := pool.Submit(incrementor); err != nil {
+			t.Fatalf("submit failed: %v", err)
+		}
+	}
+
+	notStarted, err := pool.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(notStarted) != 0 {
+		t.Errorf("got %d not started tasks, expected 0", len(notStarted))
+	}
+	if *counterPtr != jobsCount {
+		t.Errorf("got %d, expected %d", *counterPtr, jobsCount)
+	}
+}
+
+// TestShutdownTimeout checks that Shutdown cancels running tasks
+// and returns queued ones when ctx expires
+func TestShutdownTimeout(t *testing.T) {
+	size := 2
+	pool := NewPool(size)
+	started := make(chan struct{}, size)
+	canceled := make(chan struct{}, size)
+	blocker := func(ctx context.Context) {
+		started <- struct{}{}
+		<-ctx.Done()
+		canceled <- struct{}{}
+	}
+	// occupy all workers
+	for i := 0; i < size; i++ {
+		if err := pool.SubmitCtx(blocker); err != nil {
+			t.Fatalf("submit failed: %v", err)
+		}
+	}
+	for i := 0; i < size; i++ {
+		<-started
+	}
+	// fill the queue
+	for i := 0; i < size; i++ {
+		if err := pool.Submit(func() {}); err != nil {
+			t.Fatalf("submit failed: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	notStarted, err := pool.Shutdown(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("got error %v, expected %v", err, context.DeadlineExceeded)
+	}
+	if len(notStarted) != size {
+		t.Errorf("got %d not started tasks, expected %d", len(notStarted), size)
+	}
+	for i := 0; i < size; i++ {
+		select {
+		case <-canceled:
+		case <-time.After(time.Second):
+			t.Fatal("running task was not canceled")
+		}
+	}
+}
+
+// TestShutdownNow checks that ShutdownNow abandons the queue
+func TestShutdownNow(t *testing.T) {
+	pool := NewPool(1)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	pool.Submit(func() {
+		close(started)
+		<-release
+	})
+	<-started
+	pool.Submit(func() { t.Error("abandoned task was executed") })
+
+	notStarted := pool.ShutdownNow()
+	close(release)
+	if len(notStarted) != 1 {
+		t.Errorf("got %d not started tasks, expected 1", len(notStarted))
+	}
+	pool.Close()
+}
+
+// TestSubmitAfterShutdown checks that closed pool rejects tasks
+func TestSubmitAfterShutdown(t *testing.T) {
+	pool := NewPool(1)
+	pool.Close()
+	// second close must not panic or block
+	pool.Close()
+
+	if err := pool.Submit(func() {}); err != ErrPoolClosed {
+		t.Errorf("got error %v, expected %v", err, ErrPoolClosed)
+	}
+}
+
+// TestSubmitBlockedOnShutdown checks that submitter waiting
+// for a free slot is released by shutdown
+func TestSubmitBlockedOnShutdown(t *testing.T) {
+	pool := NewPool(1)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	pool.Submit(func() {
+		close(started)
+		<-release
+	})
+	<-started
+	// fill the queue
+	pool.Submit(func() {})
+
+	errCh := make(chan error)
+	go func() {
+		errCh <- pool.Submit(func() {})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	pool.ShutdownNow()
+
+	if err := <-errCh; err != ErrPoolClosed {
+		t.Errorf("got error %v, expected %v", err, ErrPoolClosed)
+	}
+	close(release)
+}
+
+// TestPriorityPool checks that queued tasks are started by priority
+func TestPriorityPool(t *testing.T) {
+	pool := NewScheduledPool(1, 10, sched.NewStrictPriority(0))
+	release := make(chan struct{})
+	started := make(chan struct{})
+	pool.Submit(func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	// single worker is busy, so all tasks wait in the queue
+	order := []int{}
+	priorities := []int{1, 3, 2}
+	for _, prio := range priorities {
+		prio := prio
+		err := pool.SubmitClass(sched.Class{Priority: prio}, func(context.Context) {
+			order = append(order, prio)
+		})
+		if err != nil {
+			t.Fatalf("submit failed: %v", err)
+		}
+	}
+	close(release)
+	pool.Close()
+
+	expect := []int{3, 2, 1}
+	for i := range expect {
+		if i >= len(order) || order[i] != expect[i] {
+			t.Fatalf("got order %v, expected %v", order, expect)
+		}
+	}
+}
+
+// TestPoolObserver checks that pool reports task events
+func TestPoolObserver(t *testing.T) {
+	metrics := observe.NewCollector()
+	pool := NewPool(2)
+	pool.SetObserver(metrics.Pool("hw", 2))
+
+	pool.Submit(func() {})
+	pool.Submit(func() { panic("boom") })
+	pool.Close()
+	pool.Submit(func() {})
+
+	s := metrics.Snapshot()[0]
+	if s.Submitted != 2 || s.Finished != 1 || s.Panicked != 1 || s.Rejected != 1 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
+
+// TestShutdownNowObserver checks that dropped tasks leave the queued gauge
+func TestShutdownNowObserver(t *testing.T) {
+	metrics := observe.NewCollector()
+	pool := NewPool(1)
+	pool.SetObserver(metrics.Pool("hw", 1))
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	pool.Submit(func() {
+		close(started)
+		<-release
+	})
+	<-started
+	ran := false
+	pool.Submit(func() { ran = true })
+
+	notStarted := pool.ShutdownNow()
+	close(release)
+	<-pool.done
+
+	s := metrics.Snapshot()[0]
+	if s.Queued != 0 || s.Dropped != 1 {
+		t.Errorf("got queued=%d dropped=%d, expected 0 and 1", s.Queued, s.Dropped)
+	}
+	// returned task is the submitted one, running it
+	// later doesn't touch the pool metrics
+	notStarted[0](context.Background())
+	if !ran || metrics.Snapshot()[0].Started != 1 {
+		t.Errorf("unexpected stats after running dropped task: %+v", metrics.Snapshot()[0])
+	}
+}
@@ -0,0 +1,235 @@
+// Package leak finds goroutines left running by tests
+//
+//	func TestMain(m *testing.M) {
+//		leak.VerifyTestMain(m)
+//	}
+//
+// or for a single test
+//
+//	func TestPool(t *testing.T) {
+//		leak.VerifyNoLeaks(t)
+//		...
+//	}
+//
+// VerifyNoLeaks must not be used in tests with t.Parallel:
+// goroutines of tests running at the same time are reported as leaked
+package leak
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Goroutine is a parsed item of runtime.Stack output
+type Goroutine struct {
+	ID    uint64
+	State string
+	// Top is the function goroutine is currently in
+	Top string
+	// CreatedBy is the function which started goroutine,
+	// it is empty for the main goroutine
+	CreatedBy string
+	// Stack is the full stack trace with header
+	Stack string
+}
+
+// ignoredTop are top functions of goroutines which
+// belong to runtime and testing, not to the tested code
+var ignoredTop = []string{
+	"testing.RunTests",
+	"testing.(*T).Run",
+	"testing.(*T).Parallel",
+	"testing.(*F).Fuzz",
+	"testing.runFuzzing",
+	"testing.tRunner.func1",
+	"os/signal.signal_recv",
+	"os/signal.loop",
+	"runtime.ensureSigM",
+	"runtime.goexit",
+	"runtime/trace.Start.func1",
+}
+
+// testRunners start goroutines running tests themselves
+var testRunners = []string{
+	"testing.(*T).Run",
+	"testing.runTests",
+	"testing.(*F).Fuzz",
+	"testing.runFuzzing",
+}
+
+// Current returns all goroutines except the calling one
+func Current() []Goroutine {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	var all []Goroutine
+	for i, block := range bytes.Split(buf, []byte("\n\n")) {
+		g, ok := parse(string(block))
+		// the first one is the calling goroutine
+		if !ok || i == 0 {
+			continue
+		}
+		all = append(all, g)
+	}
+	return all
+}
+
+// parse parses block like
+//
+//	goroutine 7 [chan receive]:
+//	main.worker(0xc000010000)
+//		/src/main.go:10 +0x25
+func parse(block string) (Goroutine, bool) {
+	lines := strings.Split(strings.TrimSpace(block), "\n")
+	header := strings.TrimPrefix(lines[0], "goroutine ")
+	if len(lines) < 2 || header == lines[0] {
+		return Goroutine{}, false
+	}
+	fields := strings.SplitN(header, " ", 2)
+	id, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil || len(fields) < 2 {
+		return Goroutine{}, false
+	}
+	state := strings.TrimSuffix(strings.TrimPrefix(fields[1], "["), "]:")
+	// "[chan receive, 2 minutes]" -> "chan receive"
+	if i := strings.IndexByte(state, ','); i >= 0 {
+		state = state[:i]
+	}
+
+	top := lines[1]
+	if i := strings.LastIndexByte(top, '('); i > 0 {
+		top = top[:i]
+	}
+	// "created by main.main in goroutine 1"
+	var createdBy string
+	for _, line := range lines[2:] {
+		if fn, ok := strings.CutPrefix(line, "created by "); ok {
+			createdBy, _, _ = strings.Cut(fn, " in goroutine ")
+			break
+		}
+	}
+	return Goroutine{ID: id, State: state, Top: top, CreatedBy: createdBy, Stack: block}, true
+}
+
+func (g Goroutine) ignored(extra []string) bool {
+	for _, list := range [][]string{ignoredTop, extra} {
+		for _, top := range list {
+			if g.Top == top {
+				return true
+			}
+		}
+	}
+	// only goroutines of tests themselves, goroutines
+	// started by the tested code are created by it
+	for _, runner := range testRunners {
+		if g.CreatedBy == runner {
+			return true
+		}
+	}
+	return g.CreatedBy == "" && strings.Contains(g.Stack, "\ntesting.(*M).Run(")
+}
+
+type options struct {
+	timeout time.Duration
+	ignored []string
+	exclude map[uint64]bool
+}
+
+type Option func(*options)
+
+// Timeout sets how long to wait for goroutines to finish, 1s by default
+func Timeout(d time.Duration) Option {
+	return func(o *options) { o.timeout = d }
+}
+
+// IgnoreTopFunction skips goroutines which are in function {name},
+// e.g. "net/http.(*persistConn).readLoop"
+func IgnoreTopFunction(name string) Option {
+	return func(o *options) { o.ignored = append(o.ignored, name) }
+}
+
+// Find returns goroutines which are still running after retries
+func Find(opts ...Option) []Goroutine {
+	o := options{timeout: time.Second}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return find(o)
+}
+
+func find(o options) []Goroutine {
+	deadline := time.Now().Add(o.timeout)
+	delay := time.Millisecond
+	for {
+		var leaked []Goroutine
+		for _, g := range Current() {
+			if !o.exclude[g.ID] && !g.ignored(o.ignored) {
+				leaked = append(leaked, g)
+			}
+		}
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			return leaked
+		}
+		// let stragglers exit
+		time.Sleep(delay)
+		if delay < 100*time.Millisecond {
+			delay *= 2
+		}
+	}
+}
+
+func report(leaked []Goroutine) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "found %d leaked goroutines:\n", len(leaked))
+	for _, g := range leaked {
+		b.WriteString("\n")
+		b.WriteString(g.Stack)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// VerifyNoLeaks remembers running goroutines and fails the test
+// if new goroutines are still running when the test is finished
+func VerifyNoLeaks(t testing.TB, opts ...Option) {
+	t.Helper()
+	o := options{timeout: time.Second, exclude: map[uint64]bool{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	for _, g := range Current() {
+		o.exclude[g.ID] = true
+	}
+
+	t.Cleanup(func() {
+		if leaked := find(o); len(leaked) > 0 {
+			t.Error(report(leaked))
+		}
+	})
+}
+
+// VerifyTestMain runs tests and fails the test binary
+// if any goroutine is running after them
+func VerifyTestMain(m *testing.M, opts ...Option) {
+	code := m.Run()
+	if code == 0 {
+		if leaked := Find(opts...); len(leaked) > 0 {
+			fmt.Fprint(os.Stderr, report(leaked))
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
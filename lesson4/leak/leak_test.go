@@ -0,0 +1,115 @@
+package leak
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeT catches errors of VerifyNoLeaks
+type fakeT struct {
+	testing.TB
+	cleanups []func()
+	errors   []string
+}
+
+func (f *fakeT) Helper()           {}
+func (f *fakeT) Cleanup(fn func()) { f.cleanups = append(f.cleanups, fn) }
+func (f *fakeT) Error(args ...interface{}) {
+	f.errors = append(f.errors, args[0].(string))
+}
+
+func (f *fakeT) finish() {
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+}
+
+func leakyWorker(stop chan struct{}) {
+	<-stop
+}
+
+func TestDetectsLeak(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	ft := &fakeT{}
+	VerifyNoLeaks(ft, Timeout(50*time.Millisecond))
+	go leakyWorker(stop)
+	ft.finish()
+
+	if len(ft.errors) != 1 {
+		t.Fatalf("got %d errors, expected 1", len(ft.errors))
+	}
+	report := ft.errors[0]
+	if !strings.Contains(report, "found 1 leaked goroutines") ||
+		!strings.Contains(report, "leak.leakyWorker") {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+}
+
+func TestWaitsForStragglers(t *testing.T) {
+	ft := &fakeT{}
+	VerifyNoLeaks(ft)
+	go time.Sleep(20 * time.Millisecond)
+	ft.finish()
+
+	if len(ft.errors) != 0 {
+		t.Errorf("unexpected errors: %v", ft.errors)
+	}
+}
+
+func TestIgnoreTopFunction(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	go leakyWorker(stop)
+	time.Sleep(10 * time.Millisecond)
+
+	leaked := Find(Timeout(10*time.Millisecond), IgnoreTopFunction("geekbrains/examples/lesson4/leak.leakyWorker"))
+	if len(leaked) != 0 {
+		t.Errorf("got %d leaked goroutines, expected all ignored", len(leaked))
+	}
+	leaked = Find(Timeout(10 * time.Millisecond))
+	if len(leaked) != 1 || leaked[0].State != "chan receive" {
+		t.Errorf("unexpected leaked goroutines: %+v", leaked)
+	}
+}
+
+func TestParse(t *testing.T) {
+	block := "goroutine 42 [select, 3 minutes]:\n" +
+		"main.worker(0xc000010000, 0x1)\n" +
+		"\t/src/main.go:10 +0x25\n" +
+		"created by main.main in goroutine 1\n" +
+		"\t/src/main.go:20 +0x40"
+	g, ok := parse(block)
+	if !ok {
+		t.Fatal("parse failed")
+	}
+	if g.ID != 42 || g.State != "select" || g.Top != "main.worker" {
+		t.Errorf("unexpected goroutine: %+v", g)
+	}
+}
+
+func TestIgnoresOnlyTestRunners(t *testing.T) {
+	runner := "goroutine 7 [chan receive]:\n" +
+		"geekbrains/examples/lesson4/leak.TestX(0xc000010000)\n" +
+		"\t/src/x_test.go:10 +0x25\n" +
+		"testing.tRunner(0xc000010000, 0x1)\n" +
+		"\t/go/src/testing/testing.go:1690 +0xf4\n" +
+		"created by testing.(*T).Run in goroutine 1\n" +
+		"\t/go/src/testing/testing.go:1743 +0x390"
+	worker := "goroutine 8 [chan receive]:\n" +
+		"geekbrains/examples/lesson4/leak.leakyWorker(0xc000010000)\n" +
+		"\t/src/leak_test.go:28 +0x25\n" +
+		"created by geekbrains/examples/lesson4/leak.TestX in goroutine 7\n" +
+		"\t/src/x_test.go:9 +0x40"
+	for block, expect := range map[string]bool{runner: true, worker: false} {
+		g, ok := parse(block)
+		if !ok {
+			t.Fatal("parse failed")
+		}
+		if got := g.ignored(nil); got != expect {
+			t.Errorf("goroutine %d created by %q: ignored %v, expected %v", g.ID, g.CreatedBy, got, expect)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package observe
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are histogram upper bounds in seconds, from 100µs to 10s
+var DefaultBuckets = []float64{
+	0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01,
+	0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10,
+}
+
+// Histogram counts observations by buckets like Prometheus histogram
+type Histogram struct {
+	// Bounds are bucket upper bounds in seconds
+	Bounds []float64
+	// Counts are per bucket (not cumulative), last one is +Inf
+	Counts []uint64
+	Sum    float64
+	Count  uint64
+}
+
+func newHistogram(bounds []float64) Histogram {
+	return Histogram{Bounds: bounds, Counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	v := d.Seconds()
+	i := sort.SearchFloat64s(h.Bounds, v)
+	h.Counts[i]++
+	h.Sum += v
+	h.Count++
+}
+
+func (h Histogram) clone() Histogram {
+	h.Counts = append([]uint64(nil), h.Counts...)
+	return h
+}
+
+// Stats is a snapshot of one pool metrics
+type Stats struct {
+	Pool    string
+	Workers int
+
+	Submitted uint64
+	Started   uint64
+	Finished  uint64
+	Panicked  uint64
+	Rejected  uint64
+	Dropped   uint64
+	// Queued and Running are current values
+	Queued  int64
+	Running int64
+
+	QueueWait Histogram
+	Exec      Histogram
+	// Busy is total execution time of all tasks
+	Busy time.Duration
+	// Elapsed is time since pool was registered
+	Elapsed time.Duration
+}
+
+// Throughput is average count of completed tasks per second
+func (s Stats) Throughput() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Finished+s.Panicked) / s.Elapsed.Seconds()
+}
+
+// Utilization is the average share of busy workers in [0, 1]
+func (s Stats) Utilization() float64 {
+	if s.Elapsed <= 0 || s.Workers <= 0 {
+		return 0
+	}
+	return s.Busy.Seconds() / (s.Elapsed.Seconds() * float64(s.Workers))
+}
+
+// Collector keeps metrics of registered pools, it is http.Handler
+// serving them in Prometheus text exposition format
+type Collector struct {
+	mu      sync.Mutex
+	buckets []float64
+	pools   map[string]*poolObserver
+	now     func() time.Time
+}
+
+func NewCollector() *Collector {
+	return NewCollectorWithBuckets(DefaultBuckets)
+}
+
+// NewCollectorWithBuckets creates collector with custom
+// histogram upper bounds in seconds, sorted ascending
+func NewCollectorWithBuckets(buckets []float64) *Collector {
+	return &Collector{
+		buckets: buckets,
+		pools:   map[string]*poolObserver{},
+		now:     time.Now,
+	}
+}
+
+// Pool returns observer for pool {name} with {workers} workers.
+// Calling it again with the same name returns the same observer
+func (c *Collector) Pool(name string, workers int) Observer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if po, ok := c.pools[name]; ok {
+		return po
+	}
+	po := &poolObserver{c: c}
+	po.stats = Stats{
+		Pool:      name,
+		Workers:   workers,
+		QueueWait: newHistogram(c.buckets),
+		Exec:      newHistogram(c.buckets),
+	}
+	po.registered = c.now()
+	c.pools[name] = po
+	return po
+}
+
+// Snapshot returns stats of all pools sorted by name
+func (c *Collector) Snapshot() []Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	stats := make([]Stats, 0, len(c.pools))
+	for _, po := range c.pools {
+		s := po.stats
+		s.QueueWait = s.QueueWait.clone()
+		s.Exec = s.Exec.clone()
+		s.Elapsed = now.Sub(po.registered)
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Pool < stats[j].Pool })
+	return stats
+}
+
+// poolObserver updates stats of one pool under collector lock
+type poolObserver struct {
+	c          *Collector
+	registered time.Time
+	stats      Stats
+}
+
+func (po *poolObserver) update(f func(s *Stats)) {
+	po.c.mu.Lock()
+	defer po.c.mu.Unlock()
+	f(&po.stats)
+}
+
+func (po *poolObserver) OnSubmit() {
+	po.update(func(s *Stats) {
+		s.Submitted++
+		s.Queued++
+	})
+}
+
+func (po *poolObserver) OnStart(wait time.Duration) {
+	po.update(func(s *Stats) {
+		s.Started++
+		s.Queued--
+		s.Running++
+		s.QueueWait.observe(wait)
+	})
+}
+
+func (po *poolObserver) OnFinish(exec time.Duration) {
+	po.update(func(s *Stats) {
+		s.Finished++
+		s.Running--
+		s.Busy += exec
+		s.Exec.observe(exec)
+	})
+}
+
+func (po *poolObserver) OnPanic(recovered interface{}, exec time.Duration) {
+	po.update(func(s *Stats) {
+		s.Panicked++
+		s.Running--
+		s.Busy += exec
+		s.Exec.observe(exec)
+	})
+}
+
+func (po *poolObserver) OnReject(err error) {
+	po.update(func(s *Stats) {
+		s.Rejected++
+	})
+}
+
+func (po *poolObserver) OnDrop() {
+	po.update(func(s *Stats) {
+		s.Dropped++
+		s.Queued--
+	})
+}
+
+//
+// Prometheus text exposition format
+//
+
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WriteTo(w)
+}
+
+// WriteTo writes all metrics in Prometheus text format
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	ew := &errWriter{w: w}
+	stats := c.Snapshot()
+
+	counters := []struct {
+		name, help string
+		value      func(Stats) uint64
+	}{
+		{"pool_tasks_submitted_total", "Tasks accepted by the pool.", func(s Stats) uint64 { return s.Submitted }},
+		{"pool_tasks_started_total", "Tasks started by workers.", func(s Stats) uint64 { return s.Started }},
+		{"pool_tasks_finished_total", "Tasks returned normally.", func(s Stats) uint64 { return s.Finished }},
+		{"pool_tasks_panicked_total", "Tasks which panicked.", func(s Stats) uint64 { return s.Panicked }},
+		{"pool_tasks_rejected_total", "Tasks not accepted by the pool.", func(s Stats) uint64 { return s.Rejected }},
+		{"pool_tasks_dropped_total", "Queued tasks removed without running.", func(s Stats) uint64 { return s.Dropped }},
+	}
+	for _, m := range counters {
+		ew.printf("# HELP %s %s\n# TYPE %s counter\n", m.name, m.help, m.name)
+		for _, s := range stats {
+			ew.printf("%s{pool=\"%s\"} %d\n", m.name, escapeLabel(s.Pool), m.value(s))
+		}
+	}
+
+	values := []struct {
+		name, typ, help string
+		value           func(Stats) float64
+	}{
+		{"pool_busy_seconds_total", "counter", "Total execution time of tasks.", func(s Stats) float64 { return s.Busy.Seconds() }},
+		{"pool_workers", "gauge", "Workers count.", func(s Stats) float64 { return float64(s.Workers) }},
+		{"pool_tasks_queued", "gauge", "Tasks waiting in the queue.", func(s Stats) float64 { return float64(s.Queued) }},
+		{"pool_tasks_running", "gauge", "Tasks being executed.", func(s Stats) float64 { return float64(s.Running) }},
+		{"pool_throughput_tasks_per_second", "gauge", "Average completed tasks per second.", Stats.Throughput},
+		{"pool_utilization_ratio", "gauge", "Average share of busy workers.", Stats.Utilization},
+	}
+	for _, m := range values {
+		ew.printf("# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+		for _, s := range stats {
+			ew.printf("%s{pool=\"%s\"} %g\n", m.name, escapeLabel(s.Pool), m.value(s))
+		}
+	}
+
+	histograms := []struct {
+		name, help string
+		value      func(Stats) Histogram
+	}{
+		{"pool_queue_wait_seconds", "Time tasks spent in the queue.", func(s Stats) Histogram { return s.QueueWait }},
+		{"pool_exec_seconds", "Task execution time.", func(s Stats) Histogram { return s.Exec }},
+	}
+	for _, m := range histograms {
+		ew.printf("# HELP %s %s\n# TYPE %s histogram\n", m.name, m.help, m.name)
+		for _, s := range stats {
+			h := m.value(s)
+			cumulative := uint64(0)
+			for i, bound := range h.Bounds {
+				cumulative += h.Counts[i]
+				ew.printf("%s_bucket{pool=\"%s\",le=\"%g\"} %d\n", m.name, escapeLabel(s.Pool), bound, cumulative)
+			}
+			ew.printf("%s_bucket{pool=\"%s\",le=\"+Inf\"} %d\n", m.name, escapeLabel(s.Pool), h.Count)
+			ew.printf("%s_sum{pool=\"%s\"} %g\n", m.name, escapeLabel(s.Pool), h.Sum)
+			ew.printf("%s_count{pool=\"%s\"} %d\n", m.name, escapeLabel(s.Pool), h.Count)
+		}
+	}
+	return ew.n, ew.err
+}
+
+// labelEscaper escapes label value for Prometheus text format,
+// which defines only these three escapes, unlike Go quoting
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+// errWriter keeps the first write error and written bytes count
+type errWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(ew.w, format, args...)
+	ew.n += int64(n)
+	ew.err = err
+}
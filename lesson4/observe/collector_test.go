@@ -0,0 +1,115 @@
+package observe
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrap(t *testing.T) {
+	c := NewCollector()
+	o := c.Pool("test", 2)
+
+	ok := Wrap(o, func() {})
+	o.OnSubmit()
+	broken := Wrap(o, func() { panic("boom") })
+	o.OnSubmit()
+	o.OnReject(errors.New("closed"))
+
+	ok()
+	// panic must not escape
+	broken()
+
+	stats := c.Snapshot()
+	if len(stats) != 1 {
+		t.Fatalf("got %d pools, expected 1", len(stats))
+	}
+	s := stats[0]
+	if s.Submitted != 2 || s.Started != 2 || s.Finished != 1 || s.Panicked != 1 || s.Rejected != 1 {
+		t.Errorf("unexpected counters: %+v", s)
+	}
+	if s.Queued != 0 || s.Running != 0 {
+		t.Errorf("got queued=%d running=%d, expected zeros", s.Queued, s.Running)
+	}
+	if s.QueueWait.Count != 2 || s.Exec.Count != 2 {
+		t.Errorf("got histogram counts %d and %d, expected 2", s.QueueWait.Count, s.Exec.Count)
+	}
+}
+
+func TestUtilization(t *testing.T) {
+	c := NewCollector()
+	start := time.Now()
+	c.now = func() time.Time { return start }
+	o := c.Pool("test", 2)
+
+	o.OnSubmit()
+	o.OnStart(0)
+	o.OnFinish(time.Second)
+	c.now = func() time.Time { return start.Add(2 * time.Second) }
+
+	s := c.Snapshot()[0]
+	// 1 busy second of 2 workers * 2 seconds
+	if u := s.Utilization(); u != 0.25 {
+		t.Errorf("got utilization %v, expected 0.25", u)
+	}
+	if tp := s.Throughput(); tp != 0.5 {
+		t.Errorf("got throughput %v, expected 0.5", tp)
+	}
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	c := NewCollectorWithBuckets([]float64{0.1, 1})
+	o := c.Pool("hw", 4)
+	o.OnSubmit()
+	o.OnStart(50 * time.Millisecond)
+	o.OnFinish(500 * time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	expected := []string{
+		"# TYPE pool_tasks_submitted_total counter",
+		`pool_tasks_submitted_total{pool="hw"} 1`,
+		`pool_workers{pool="hw"} 4`,
+		"# TYPE pool_exec_seconds histogram",
+		`pool_exec_seconds_bucket{pool="hw",le="0.1"} 0`,
+		`pool_exec_seconds_bucket{pool="hw",le="1"} 1`,
+		`pool_exec_seconds_bucket{pool="hw",le="+Inf"} 1`,
+		`pool_queue_wait_seconds_bucket{pool="hw",le="0.1"} 1`,
+		`pool_exec_seconds_sum{pool="hw"} 0.5`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("line %q not found in:\n%s", line, body)
+		}
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	c := NewCollector()
+	c.Pool("пул \"a\\b\"\nx", 1)
+
+	var sb strings.Builder
+	c.WriteTo(&sb)
+	// only backslash, quote and newline are escaped, UTF-8 stays as is
+	expect := `pool_workers{pool="пул \"a\\b\"\nx"} 1`
+	if !strings.Contains(sb.String(), expect+"\n") {
+		t.Errorf("line %q not found in:\n%s", expect, sb.String())
+	}
+}
+
+func TestDrop(t *testing.T) {
+	c := NewCollector()
+	o := c.Pool("hw", 1)
+	o.OnSubmit()
+	o.OnSubmit()
+	o.OnDrop()
+
+	s := c.Snapshot()[0]
+	if s.Queued != 1 || s.Dropped != 1 {
+		t.Errorf("got queued=%d dropped=%d, expected 1 and 1", s.Queued, s.Dropped)
+	}
+}
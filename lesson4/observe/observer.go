@@ -0,0 +1,81 @@
+// Package observe provides hooks to watch worker pools
+// and a collector exporting pool metrics in Prometheus format
+//
+//	metrics := observe.NewCollector()
+//	pool.SetObserver(metrics.Pool("hw", workers))
+//	http.Handle("/metrics", metrics)
+package observe
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// Observer receives task lifecycle events of one pool.
+// Callbacks are called from pool goroutines concurrently,
+// so they should be fast and safe for concurrent use
+type Observer interface {
+	// OnSubmit is called when task is accepted by the pool
+	OnSubmit()
+	// OnStart is called when worker starts the task,
+	// wait is the time task spent in the queue
+	OnStart(wait time.Duration)
+	// OnFinish is called when task returned normally
+	OnFinish(exec time.Duration)
+	// OnPanic is called when task panicked, the worker keeps running
+	OnPanic(recovered interface{}, exec time.Duration)
+	// OnReject is called when task is not accepted
+	OnReject(err error)
+	// OnDrop is called when accepted task is removed
+	// from the queue without running, e.g. by ShutdownNow
+	OnDrop()
+}
+
+// Nop observer ignores all events
+type Nop struct{}
+
+func (Nop) OnSubmit()                          {}
+func (Nop) OnStart(time.Duration)              {}
+func (Nop) OnFinish(time.Duration)             {}
+func (Nop) OnPanic(interface{}, time.Duration) {}
+func (Nop) OnReject(error)                     {}
+func (Nop) OnDrop()                            {}
+
+// PanicError is a task panic converted to error
+type PanicError struct {
+	Value interface{}
+	// Stack of the panicked goroutine
+	Stack []byte
+}
+
+// NewPanicError should be called in the deferred function,
+// which recovered {value}, to capture the panic stack
+func NewPanicError(value interface{}) *PanicError {
+	return &PanicError{Value: value, Stack: debug.Stack()}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n\n%s", e.Value, e.Stack)
+}
+
+// Wrap remembers submit time and returns task reporting its start
+// and finish to {o}. Panic of the task is recovered and reported
+// by OnPanic, so one broken task doesn't crash the whole pool.
+// Pool should call OnSubmit or OnReject itself after enqueueing
+func Wrap(o Observer, task func()) func() {
+	submitted := time.Now()
+	return func() {
+		started := time.Now()
+		o.OnStart(started.Sub(submitted))
+		defer func() {
+			exec := time.Since(started)
+			if r := recover(); r != nil {
+				o.OnPanic(r, exec)
+				return
+			}
+			o.OnFinish(exec)
+		}()
+		task()
+	}
+}
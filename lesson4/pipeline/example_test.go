@@ -0,0 +1,48 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+)
+
+type Msg struct {
+	id   int
+	data int
+}
+
+type Res struct {
+	id  int
+	res string
+}
+
+// Msg/Res worker pool from lesson4/worker_pool without
+// knowing messages count and without sorting results
+func Example() {
+	p := New(context.Background())
+
+	msgs := Generate(p, func(ctx context.Context, emit func(Msg) bool) error {
+		for i := 0; i < 5; i++ {
+			if !emit(Msg{id: i, data: i * 100}) {
+				break
+			}
+		}
+		return nil
+	})
+	resps := Stage(p, msgs, Options{Workers: 3, Ordered: true}, func(ctx context.Context, m Msg) (Res, error) {
+		return Res{id: m.id, res: fmt.Sprintf("processed %+v", m)}, nil
+	})
+
+	err := ForEach(p, resps, func(ctx context.Context, res Res) error {
+		fmt.Println(res.res)
+		return nil
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// processed {id:0 data:0}
+	// processed {id:1 data:100}
+	// processed {id:2 data:200}
+	// processed {id:3 data:300}
+	// processed {id:4 data:400}
+}
@@ -0,0 +1,343 @@
+// Package pipeline wires typed channel stages together,
+// so the number of messages doesn't have to be known in advance
+//
+//	p := pipeline.New(ctx)
+//	msgs := pipeline.Generate(p, readMessages)
+//	res := pipeline.Stage(p, msgs, pipeline.Options{Workers: 3, Ordered: true}, process)
+//	results, err := pipeline.Collect(p, res)
+//
+// Every stage closes its output when input is exhausted or
+// pipeline is canceled, so consumers just range over channels
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Pipeline owns the context shared by all stages
+// and keeps the first error which stopped it
+type Pipeline struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	errOnce sync.Once
+	err     error
+}
+
+// New creates pipeline canceled with {ctx}
+func New(ctx context.Context) *Pipeline {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Pipeline{ctx: ctx, cancel: cancel}
+}
+
+// Context is canceled when pipeline stops
+func (p *Pipeline) Context() context.Context {
+	return p.ctx
+}
+
+// Stop cancels all stages with {err}, only the first error is kept
+func (p *Pipeline) Stop(err error) {
+	p.errOnce.Do(func() { p.err = err })
+	p.cancel()
+}
+
+// Wait waits until all stages finished and returns the first error.
+// If parent context was canceled its error is returned
+func (p *Pipeline) Wait() error {
+	p.wg.Wait()
+	p.errOnce.Do(func() { p.err = p.ctx.Err() })
+	p.cancel()
+	return p.err
+}
+
+func (p *Pipeline) goStage(stage func()) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		stage()
+	}()
+}
+
+// send blocks until {v} is sent or pipeline stops
+func send[T any](ctx context.Context, ch chan<- T, v T) bool {
+	select {
+	case ch <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// recv blocks until item is received, returns false
+// when {ch} is closed or pipeline stops
+func recv[T any](ctx context.Context, ch <-chan T) (T, bool) {
+	select {
+	case item, ok := <-ch:
+		return item, ok
+	case <-ctx.Done():
+		var zero T
+		return zero, false
+	}
+}
+
+// ErrorHandler decides what to do with a failed item:
+// return nil to skip it or an error to stop the pipeline
+type ErrorHandler func(err error) error
+
+// Skip handler drops failed items
+func Skip(error) error { return nil }
+
+type Options struct {
+	// Name is used in error messages
+	Name string
+	// Workers is the count of concurrent goroutines, 1 by default
+	Workers int
+	// Ordered keeps output in input order
+	Ordered bool
+	// Buffer is the output channel capacity
+	Buffer int
+	// OnError handles item errors, nil stops the pipeline on first error
+	OnError ErrorHandler
+}
+
+func (o Options) workers() int {
+	if o.Workers < 1 {
+		return 1
+	}
+	return o.Workers
+}
+
+// handle returns false if pipeline should stop
+func (o Options) handle(p *Pipeline, err error) bool {
+	if o.Name != "" {
+		err = fmt.Errorf("stage %q: %w", o.Name, err)
+	}
+	if o.OnError != nil {
+		err = o.OnError(err)
+	}
+	if err != nil {
+		p.Stop(err)
+		return false
+	}
+	return true
+}
+
+//
+// Sources
+//
+
+// FromSlice sends {items} to the returned channel
+func FromSlice[T any](p *Pipeline, items []T) <-chan T {
+	return Generate(p, func(ctx context.Context, emit func(T) bool) error {
+		for _, item := range items {
+			if !emit(item) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// Generate runs {gen} which sends items by {emit} until it returns.
+// emit returns false when pipeline is stopped, gen should return then.
+// Error returned by gen stops the pipeline
+func Generate[T any](p *Pipeline, gen func(ctx context.Context, emit func(T) bool) error) <-chan T {
+	out := make(chan T)
+	p.goStage(func() {
+		defer close(out)
+		emit := func(item T) bool { return send(p.ctx, out, item) }
+		if err := gen(p.ctx, emit); err != nil {
+			p.Stop(err)
+		}
+	})
+	return out
+}
+
+//
+// Stages
+//
+
+// Stage applies {fn} to every item of {in} with {opts.Workers} goroutines
+func Stage[In, Out any](p *Pipeline, in <-chan In, opts Options, fn func(ctx context.Context, item In) (Out, error)) <-chan Out {
+	if opts.Ordered && opts.workers() > 1 {
+		return orderedStage(p, in, opts, fn)
+	}
+
+	out := make(chan Out, opts.Buffer)
+	wg := sync.WaitGroup{}
+	for i := 0; i < opts.workers(); i++ {
+		wg.Add(1)
+		p.goStage(func() {
+			defer wg.Done()
+			for {
+				item, ok := recv(p.ctx, in)
+				if !ok {
+					return
+				}
+				res, err := fn(p.ctx, item)
+				if err != nil {
+					if !opts.handle(p, err) {
+						return
+					}
+					continue
+				}
+				if !send(p.ctx, out, res) {
+					return
+				}
+			}
+		})
+	}
+	p.goStage(func() {
+		wg.Wait()
+		close(out)
+	})
+	return out
+}
+
+type result[T any] struct {
+	value T
+	ok    bool
+}
+
+// orderedStage keeps a queue of per item futures:
+// workers fill them in any order, the collector reads them in input order
+func orderedStage[In, Out any](p *Pipeline, in <-chan In, opts Options, fn func(ctx context.Context, item In) (Out, error)) <-chan Out {
+	type job struct {
+		item   In
+		future chan result[Out]
+	}
+	workers := opts.workers()
+	jobs := make(chan job)
+	// limits items processed ahead of the slowest one
+	futures := make(chan chan result[Out], workers)
+	out := make(chan Out, opts.Buffer)
+
+	// dispatcher
+	p.goStage(func() {
+		defer close(jobs)
+		defer close(futures)
+		for {
+			item, ok := recv(p.ctx, in)
+			if !ok {
+				return
+			}
+			j := job{item: item, future: make(chan result[Out], 1)}
+			if !send(p.ctx, futures, j.future) || !send(p.ctx, jobs, j) {
+				return
+			}
+		}
+	})
+
+	// workers
+	for i := 0; i < workers; i++ {
+		p.goStage(func() {
+			for j := range jobs {
+				res, err := fn(p.ctx, j.item)
+				if err != nil {
+					j.future <- result[Out]{ok: false}
+					if !opts.handle(p, err) {
+						return
+					}
+					continue
+				}
+				j.future <- result[Out]{value: res, ok: true}
+			}
+		})
+	}
+
+	// collector
+	p.goStage(func() {
+		defer close(out)
+		for future := range futures {
+			var res result[Out]
+			select {
+			case res = <-future:
+			case <-p.ctx.Done():
+				return
+			}
+			if res.ok && !send(p.ctx, out, res.value) {
+				return
+			}
+		}
+	})
+	return out
+}
+
+//
+// Fan-out / fan-in
+//
+
+// Split distributes items of {in} between {n} outputs,
+// so a slow consumer holds back at most one item
+func Split[T any](p *Pipeline, in <-chan T, n int) []<-chan T {
+	outs := make([]<-chan T, n)
+	for i := range outs {
+		out := make(chan T)
+		outs[i] = out
+		p.goStage(func() {
+			defer close(out)
+			for {
+				item, ok := recv(p.ctx, in)
+				if !ok || !send(p.ctx, out, item) {
+					return
+				}
+			}
+		})
+	}
+	return outs
+}
+
+// Merge sends items of all {ins} to one channel, order is not preserved
+func Merge[T any](p *Pipeline, ins ...<-chan T) <-chan T {
+	out := make(chan T)
+	wg := sync.WaitGroup{}
+	for _, in := range ins {
+		wg.Add(1)
+		in := in
+		p.goStage(func() {
+			defer wg.Done()
+			for {
+				item, ok := recv(p.ctx, in)
+				if !ok || !send(p.ctx, out, item) {
+					return
+				}
+			}
+		})
+	}
+	p.goStage(func() {
+		wg.Wait()
+		close(out)
+	})
+	return out
+}
+
+//
+// Sinks
+//
+
+// Collect reads all items of {in} and waits for the pipeline
+func Collect[T any](p *Pipeline, in <-chan T) ([]T, error) {
+	var items []T
+	for item := range in {
+		items = append(items, item)
+	}
+	return items, p.Wait()
+}
+
+// ForEach calls {fn} for all items of {in} and waits for the pipeline.
+// Error returned by fn stops the pipeline
+func ForEach[T any](p *Pipeline, in <-chan T, fn func(ctx context.Context, item T) error) error {
+	for item := range in {
+		if err := fn(p.ctx, item); err != nil {
+			p.Stop(err)
+			break
+		}
+	}
+	// let stages exit if we stopped early
+	for range in {
+	}
+	return p.Wait()
+}
@@ -0,0 +1,156 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"geekbrains/examples/lesson4/leak"
+)
+
+func numbers(n int) []int {
+	values := make([]int, n)
+	for i := range values {
+		values[i] = i
+	}
+	return values
+}
+
+func jitter() {
+	time.Sleep(time.Duration(rand.Intn(50)) * time.Microsecond)
+}
+
+func TestStageOrdered(t *testing.T) {
+	p := New(context.Background())
+	in := FromSlice(p, numbers(500))
+	out := Stage(p, in, Options{Workers: 8, Ordered: true}, func(ctx context.Context, i int) (string, error) {
+		jitter()
+		return strconv.Itoa(i), nil
+	})
+
+	got, err := Collect(p, out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 500 {
+		t.Fatalf("got %d items, expected 500", len(got))
+	}
+	for i, s := range got {
+		if s != strconv.Itoa(i) {
+			t.Fatalf("item %d: got %q", i, s)
+		}
+	}
+}
+
+func TestStageUnordered(t *testing.T) {
+	p := New(context.Background())
+	in := FromSlice(p, numbers(500))
+	out := Stage(p, in, Options{Workers: 8}, func(ctx context.Context, i int) (int, error) {
+		jitter()
+		return i * 2, nil
+	})
+
+	got, err := Collect(p, out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sort.Ints(got)
+	for i, v := range got {
+		if v != i*2 {
+			t.Fatalf("item %d: got %d", i, v)
+		}
+	}
+}
+
+func TestStageError(t *testing.T) {
+	for _, ordered := range []bool{false, true} {
+		p := New(context.Background())
+		errBad := errors.New("bad item")
+		// endless source, only error can stop it
+		in := Generate(p, func(ctx context.Context, emit func(int) bool) error {
+			for i := 0; emit(i); i++ {
+			}
+			return nil
+		})
+		out := Stage(p, in, Options{Name: "check", Workers: 4, Ordered: ordered}, func(ctx context.Context, i int) (int, error) {
+			if i == 100 {
+				return 0, errBad
+			}
+			return i, nil
+		})
+
+		_, err := Collect(p, out)
+		if !errors.Is(err, errBad) {
+			t.Errorf("ordered=%v: got error %v, expected %v", ordered, err, errBad)
+		}
+	}
+}
+
+func TestStageSkipErrors(t *testing.T) {
+	for _, ordered := range []bool{false, true} {
+		p := New(context.Background())
+		in := FromSlice(p, numbers(100))
+		out := Stage(p, in, Options{Workers: 4, Ordered: ordered, OnError: Skip}, func(ctx context.Context, i int) (int, error) {
+			if i%2 == 1 {
+				return 0, errors.New("odd")
+			}
+			return i, nil
+		})
+
+		got, err := Collect(p, out)
+		if err != nil {
+			t.Errorf("ordered=%v: unexpected error %v", ordered, err)
+		}
+		if len(got) != 50 {
+			t.Errorf("ordered=%v: got %d items, expected 50", ordered, len(got))
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	// all stage goroutines are finished after Wait
+	leak.VerifyNoLeaks(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := New(ctx)
+	// source which is never closed
+	src := make(chan int)
+	a := Stage(p, src, Options{Workers: 3, Ordered: true}, func(ctx context.Context, i int) (int, error) {
+		return i, nil
+	})
+	parts := Split(p, a, 2)
+	merged := Merge(p, parts...)
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err := Collect(p, merged)
+	if err != context.Canceled {
+		t.Errorf("got error %v, expected %v", err, context.Canceled)
+	}
+}
+
+func TestSplitMerge(t *testing.T) {
+	p := New(context.Background())
+	in := FromSlice(p, numbers(100))
+	parts := Split(p, in, 3)
+	for i := range parts {
+		parts[i] = Stage(p, parts[i], Options{}, func(ctx context.Context, v int) (int, error) {
+			return v + 1, nil
+		})
+	}
+
+	sum := 0
+	err := ForEach(p, Merge(p, parts...), func(ctx context.Context, v int) error {
+		sum += v
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := 100 * 101 / 2; sum != expected {
+		t.Errorf("got sum %d, expected %d", sum, expected)
+	}
+}
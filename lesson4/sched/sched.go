@@ -0,0 +1,238 @@
+// Package sched contains task queues with different
+// scheduling disciplines for worker pools
+//
+//	s := sched.NewStrictPriority(time.Second)
+//	s.Push(sched.Job{Class: sched.Class{Priority: 10}, Value: task})
+//	job, ok := s.Pop()
+//
+// Schedulers are not safe for concurrent use,
+// pools guard them with their own locks
+package sched
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Class describes how job should be scheduled
+type Class struct {
+	// Priority: jobs with higher value go first
+	Priority int
+	// Tenant groups jobs for fair queuing
+	Tenant string
+}
+
+// Job is a queued item, Value is the payload owned by the pool
+type Job struct {
+	Class
+	Value interface{}
+}
+
+type Scheduler interface {
+	// Push adds job to the queue
+	Push(job Job)
+	// Pop removes next job, returns false on empty queue
+	Pop() (Job, bool)
+	// Len returns count of queued jobs
+	Len() int
+}
+
+//
+// FIFO
+//
+
+// FIFO ignores job class and keeps submission order
+type FIFO struct {
+	jobs []Job
+}
+
+func NewFIFO() *FIFO {
+	return &FIFO{}
+}
+
+func (q *FIFO) Push(job Job) {
+	q.jobs = append(q.jobs, job)
+}
+
+func (q *FIFO) Pop() (Job, bool) {
+	if len(q.jobs) == 0 {
+		return Job{}, false
+	}
+	job := q.jobs[0]
+	q.jobs[0] = Job{}
+	q.jobs = q.jobs[1:]
+	return job, true
+}
+
+func (q *FIFO) Len() int {
+	return len(q.jobs)
+}
+
+//
+// Strict priority
+//
+
+// StrictPriority always pops job with the highest priority,
+// jobs with equal priority are popped in submission order.
+//
+// With non zero aging, waiting job gains one priority level
+// per {aging} duration, so low priorities are not starved forever
+type StrictPriority struct {
+	aging time.Duration
+	start time.Time
+	now   func() time.Time
+	seq   uint64
+	jobs  jobHeap
+}
+
+func NewStrictPriority(aging time.Duration) *StrictPriority {
+	return &StrictPriority{
+		aging: aging,
+		start: time.Now(),
+		now:   time.Now,
+	}
+}
+
+func (q *StrictPriority) Push(job Job) {
+	q.seq++
+	item := heapItem{job: job, seq: q.seq}
+	if q.aging > 0 {
+		// effective priority is
+		//   Priority + (now - enqueuedAt) / aging
+		// the "now" part is the same for all jobs, so ordering
+		// doesn't change over time and we can use a static key
+		enqueuedAt := float64(q.now().Sub(q.start)) / float64(q.aging)
+		item.key = enqueuedAt - float64(job.Priority)
+	} else {
+		item.key = -float64(job.Priority)
+	}
+	heap.Push(&q.jobs, item)
+}
+
+func (q *StrictPriority) Pop() (Job, bool) {
+	if q.jobs.Len() == 0 {
+		return Job{}, false
+	}
+	return heap.Pop(&q.jobs).(heapItem).job, true
+}
+
+func (q *StrictPriority) Len() int {
+	return q.jobs.Len()
+}
+
+//
+// Weighted fair queuing
+//
+
+// WeightedFair shares execution between tenants in proportion
+// to their weights: tenant with weight 3 gets 3 jobs started
+// for every job of tenant with weight 1 while both have queued jobs.
+// Tenant which was idle doesn't accumulate credit
+type WeightedFair struct {
+	weights       map[string]int
+	defaultWeight int
+	// virtual time is the finish tag of the last popped job
+	virtual float64
+	// lastFinish is the finish tag of the last pushed job per tenant,
+	// queued counts jobs per tenant, both are kept only for tenants
+	// with queued jobs
+	lastFinish map[string]float64
+	queued     map[string]int
+	seq        uint64
+	jobs       jobHeap
+}
+
+// NewWeightedFair creates scheduler with weights per tenant,
+// tenants not listed in {weights} get {defaultWeight}
+func NewWeightedFair(weights map[string]int, defaultWeight int) *WeightedFair {
+	if defaultWeight < 1 {
+		defaultWeight = 1
+	}
+	w := make(map[string]int, len(weights))
+	for tenant, weight := range weights {
+		w[tenant] = weight
+	}
+	return &WeightedFair{
+		weights:       w,
+		defaultWeight: defaultWeight,
+		lastFinish:    map[string]float64{},
+		queued:        map[string]int{},
+	}
+}
+
+func (q *WeightedFair) weight(tenant string) int {
+	if w, ok := q.weights[tenant]; ok && w > 0 {
+		return w
+	}
+	return q.defaultWeight
+}
+
+func (q *WeightedFair) Push(job Job) {
+	start := q.lastFinish[job.Tenant]
+	if start < q.virtual {
+		start = q.virtual
+	}
+	finish := start + 1/float64(q.weight(job.Tenant))
+	q.lastFinish[job.Tenant] = finish
+	q.queued[job.Tenant]++
+
+	q.seq++
+	heap.Push(&q.jobs, heapItem{job: job, key: finish, seq: q.seq})
+}
+
+func (q *WeightedFair) Pop() (Job, bool) {
+	if q.jobs.Len() == 0 {
+		return Job{}, false
+	}
+	item := heap.Pop(&q.jobs).(heapItem)
+	q.virtual = item.key
+
+	// finish tag of idle tenant is not above virtual time,
+	// so forgetting it doesn't change the order
+	tenant := item.job.Tenant
+	if q.queued[tenant]--; q.queued[tenant] == 0 {
+		delete(q.queued, tenant)
+		delete(q.lastFinish, tenant)
+	}
+	return item.job, true
+}
+
+func (q *WeightedFair) Len() int {
+	return q.jobs.Len()
+}
+
+//
+// heap helpers
+//
+
+type heapItem struct {
+	job Job
+	key float64 // lower goes first
+	seq uint64  // breaks ties in submission order
+}
+
+type jobHeap []heapItem
+
+func (h jobHeap) Len() int { return len(h) }
+
+func (h jobHeap) Less(i, j int) bool {
+	if h[i].key != h[j].key {
+		return h[i].key < h[j].key
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h jobHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *jobHeap) Push(x interface{}) {
+	*h = append(*h, x.(heapItem))
+}
+
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = heapItem{}
+	*h = old[:n-1]
+	return item
+}
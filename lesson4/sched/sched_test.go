@@ -0,0 +1,136 @@
+package sched
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func popAll(t *testing.T, s Scheduler) (values []int) {
+	for s.Len() > 0 {
+		job, ok := s.Pop()
+		if !ok {
+			t.Fatal("pop from non empty queue failed")
+		}
+		values = append(values, job.Value.(int))
+	}
+	if _, ok := s.Pop(); ok {
+		t.Error("pop from empty queue succeeded")
+	}
+	return values
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFIFO(t *testing.T) {
+	s := NewFIFO()
+	for i := 0; i < 5; i++ {
+		s.Push(Job{Class: Class{Priority: i}, Value: i})
+	}
+	expect := []int{0, 1, 2, 3, 4}
+	if got := popAll(t, s); !equalInts(got, expect) {
+		t.Errorf("got %v, expect %v", got, expect)
+	}
+}
+
+func TestStrictPriority(t *testing.T) {
+	s := NewStrictPriority(0)
+	priorities := []int{1, 5, 1, 10, 5}
+	for i, prio := range priorities {
+		s.Push(Job{Class: Class{Priority: prio}, Value: i})
+	}
+	// higher priority first, FIFO inside priority
+	expect := []int{3, 1, 4, 0, 2}
+	if got := popAll(t, s); !equalInts(got, expect) {
+		t.Errorf("got %v, expect %v", got, expect)
+	}
+}
+
+func TestStrictPriorityAging(t *testing.T) {
+	s := NewStrictPriority(time.Second)
+	now := s.start
+	s.now = func() time.Time { return now }
+
+	// low priority job waits 3 seconds
+	s.Push(Job{Class: Class{Priority: 0}, Value: 0})
+	now = now.Add(3 * time.Second)
+	// it is aged to priority 3 and goes before 2, but not before 5
+	s.Push(Job{Class: Class{Priority: 2}, Value: 1})
+	s.Push(Job{Class: Class{Priority: 5}, Value: 2})
+
+	expect := []int{2, 0, 1}
+	if got := popAll(t, s); !equalInts(got, expect) {
+		t.Errorf("got %v, expect %v", got, expect)
+	}
+}
+
+func TestWeightedFair(t *testing.T) {
+	s := NewWeightedFair(map[string]int{"batch": 1, "online": 3}, 1)
+	// batch floods the queue first
+	for i := 0; i < 8; i++ {
+		s.Push(Job{Class: Class{Tenant: "batch"}, Value: 0})
+	}
+	for i := 0; i < 6; i++ {
+		s.Push(Job{Class: Class{Tenant: "online"}, Value: 1})
+	}
+
+	// first 8 pops: online gets 3 of each 4 slots
+	counts := map[int]int{}
+	for i := 0; i < 8; i++ {
+		job, _ := s.Pop()
+		counts[job.Value.(int)]++
+	}
+	if counts[1] != 6 || counts[0] != 2 {
+		t.Errorf("got batch=%d online=%d, expect batch=2 online=6", counts[0], counts[1])
+	}
+	if s.Len() != 6 {
+		t.Errorf("got len %d, expect 6", s.Len())
+	}
+}
+
+func TestWeightedFairIdleTenant(t *testing.T) {
+	s := NewWeightedFair(nil, 1)
+	for i := 0; i < 10; i++ {
+		s.Push(Job{Class: Class{Tenant: "a"}, Value: 0})
+	}
+	for i := 0; i < 5; i++ {
+		s.Pop()
+	}
+	// tenant "b" was idle, it shouldn't get 5 jobs in a row
+	s.Push(Job{Class: Class{Tenant: "b"}, Value: 1})
+	s.Push(Job{Class: Class{Tenant: "b"}, Value: 1})
+
+	expect := []int{0, 1, 0, 1, 0, 0, 0}
+	if got := popAll(t, s); !equalInts(got, expect) {
+		t.Errorf("got %v, expect %v", got, expect)
+	}
+}
+
+func TestWeightedFairForgetsIdleTenants(t *testing.T) {
+	s := NewWeightedFair(nil, 1)
+	s.Push(Job{Class: Class{Tenant: "long"}, Value: 0})
+	s.Push(Job{Class: Class{Tenant: "long"}, Value: 0})
+	// many short-lived tenants while the queue never drains
+	for i := 0; i < 1000; i++ {
+		s.Push(Job{Class: Class{Tenant: fmt.Sprint("t", i)}, Value: 1})
+		s.Push(Job{Class: Class{Tenant: "long"}, Value: 0})
+		s.Pop()
+		s.Pop()
+	}
+	if n := len(s.lastFinish); n > 2 {
+		t.Errorf("%d tenants are remembered, expect only queued ones", n)
+	}
+	if len(s.lastFinish) != len(s.queued) {
+		t.Errorf("lastFinish %d and queued %d differ", len(s.lastFinish), len(s.queued))
+	}
+}
@@ -0,0 +1,316 @@
+// Package supervisor runs a set of long living services,
+// restarts them by policy and stops them in reverse order
+//
+//	sv := supervisor.New(time.Second,
+//		supervisor.Spec{Name: "db", Run: runDB, WaitReady: true},
+//		supervisor.Spec{Name: "http", Run: runHTTP, Restart: supervisor.OnFailure},
+//	)
+//	err := sv.RunWithSignals(context.Background())
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type RestartPolicy int
+
+const (
+	// Never: service exit is final, failure stops the supervisor
+	Never RestartPolicy = iota
+	// OnFailure: service is restarted if it returned an error
+	OnFailure
+	// Always: service is restarted whatever it returned
+	Always
+)
+
+const (
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 10 * time.Second
+)
+
+// Spec describes a supervised service
+type Spec struct {
+	Name string
+	// Run should work until ctx is canceled
+	Run func(ctx context.Context) error
+	// Reload is called on SIGHUP, optional
+	Reload func(ctx context.Context) error
+	// Restart policy, Never by default
+	Restart RestartPolicy
+	// MinBackoff and MaxBackoff limit the delay before restart,
+	// it doubles on each restart in a row and is reset
+	// after a run lasted longer than MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// WaitReady delays start of next services until
+	// this one calls Ready(ctx) or exits
+	WaitReady bool
+}
+
+type readyKey struct{}
+
+// Ready marks service as ready, ctx is the one passed to Run
+func Ready(ctx context.Context) {
+	if ready, ok := ctx.Value(readyKey{}).(func()); ok {
+		ready()
+	}
+}
+
+type Supervisor struct {
+	specs       []Spec
+	stopTimeout time.Duration
+	logger      *log.Logger
+
+	mu      sync.Mutex
+	running []bool
+}
+
+// New creates supervisor for services started in {specs} order.
+// On stop all services together have {stopTimeout} to exit
+func New(stopTimeout time.Duration, specs ...Spec) *Supervisor {
+	return &Supervisor{
+		specs:       specs,
+		stopTimeout: stopTimeout,
+		logger:      log.New(os.Stderr, "", log.LstdFlags),
+		running:     make([]bool, len(specs)),
+	}
+}
+
+// SetLogger sets logger for restarts and stops, nil disables logging
+func (s *Supervisor) SetLogger(logger *log.Logger) {
+	s.logger = logger
+}
+
+func (s *Supervisor) logf(format string, args ...interface{}) {
+	if s.logger != nil {
+		s.logger.Printf(format, args...)
+	}
+}
+
+// service is a running instance of spec
+type service struct {
+	spec   Spec
+	cancel context.CancelFunc
+	// done is closed when service won't be restarted anymore
+	done chan struct{}
+	// err is the final error, read after done
+	err error
+}
+
+// Run starts services in order and works until ctx is canceled,
+// a service fails without restart or all services finished.
+// Then services are stopped
+// in reverse order. Returns all service errors joined
+func (s *Supervisor) Run(ctx context.Context) error {
+	// fatal is signaled by services which failed permanently
+	fatal := make(chan struct{})
+	var fatalOnce sync.Once
+	stopAll := func() { fatalOnce.Do(func() { close(fatal) }) }
+
+	// allDone is closed when no service is left to supervise
+	allDone := make(chan struct{})
+	wg := sync.WaitGroup{}
+
+	services := make([]*service, 0, len(s.specs))
+	for i, spec := range s.specs {
+		svcCtx, cancel := context.WithCancel(context.Background())
+		svc := &service{spec: spec, cancel: cancel, done: make(chan struct{})}
+		services = append(services, svc)
+
+		ready := make(chan struct{})
+		var readyOnce sync.Once
+		markReady := func() { readyOnce.Do(func() { close(ready) }) }
+		svcCtx = context.WithValue(svcCtx, readyKey{}, markReady)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer close(svc.done)
+			svc.err = s.supervise(svcCtx, i, svc.spec, markReady)
+			if svc.err != nil {
+				stopAll()
+			}
+		}(i)
+
+		if spec.WaitReady {
+			select {
+			case <-ready:
+			case <-svc.done:
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil || isClosed(fatal) {
+			break
+		}
+	}
+
+	go func() {
+		wg.Wait()
+		close(allDone)
+	}()
+
+	select {
+	case <-ctx.Done():
+		s.logf("supervisor: stopping on %v", ctx.Err())
+	case <-fatal:
+	case <-allDone:
+	}
+	return s.stop(services)
+}
+
+// stop cancels services in reverse order
+// waiting for each of them until common deadline
+func (s *Supervisor) stop(services []*service) error {
+	deadline := time.NewTimer(s.stopTimeout)
+	defer deadline.Stop()
+	timedOut := false
+
+	errs := make([]error, len(services))
+	for i := len(services) - 1; i >= 0; i-- {
+		svc := services[i]
+		svc.cancel()
+		if !timedOut {
+			select {
+			case <-svc.done:
+			case <-deadline.C:
+				timedOut = true
+			}
+		}
+		select {
+		case <-svc.done:
+			if svc.err != nil {
+				errs[i] = fmt.Errorf("service %q: %w", svc.spec.Name, svc.err)
+			}
+		default:
+			errs[i] = fmt.Errorf("service %q: stop timeout expired", svc.spec.Name)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// supervise runs service and restarts it by policy until ctx is canceled.
+// Returns error only if service failed and shouldn't be restarted
+func (s *Supervisor) supervise(ctx context.Context, i int, spec Spec, markReady func()) error {
+	minBackoff, maxBackoff := spec.MinBackoff, spec.MaxBackoff
+	if minBackoff <= 0 {
+		minBackoff = defaultMinBackoff
+	}
+	if maxBackoff < minBackoff {
+		maxBackoff = defaultMaxBackoff
+		if maxBackoff < minBackoff {
+			maxBackoff = minBackoff
+		}
+	}
+	backoff := minBackoff
+
+	for {
+		started := time.Now()
+		s.setRunning(i, true)
+		err := spec.Run(ctx)
+		s.setRunning(i, false)
+		// service exited, don't let the next ones wait for it
+		markReady()
+
+		if ctx.Err() != nil {
+			// stopped by supervisor, cancellation is not a failure
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		}
+
+		restart := spec.Restart == Always || (spec.Restart == OnFailure && err != nil)
+		if !restart {
+			if err != nil {
+				s.logf("supervisor: service %q failed: %v", spec.Name, err)
+			}
+			return err
+		}
+		// service which worked for a while is healthy,
+		// one exiting at once is not, whatever it returned
+		if time.Since(started) > maxBackoff {
+			backoff = minBackoff
+		}
+		if err != nil {
+			s.logf("supervisor: service %q failed: %v, restart in %v", spec.Name, err, backoff)
+		} else {
+			s.logf("supervisor: service %q exited, restart in %v", spec.Name, backoff)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func (s *Supervisor) setRunning(i int, running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running[i] = running
+}
+
+// Reload calls Reload callbacks of running services in start order
+func (s *Supervisor) Reload(ctx context.Context) error {
+	var errs []error
+	for i, spec := range s.specs {
+		s.mu.Lock()
+		running := s.running[i]
+		s.mu.Unlock()
+		if spec.Reload == nil || !running {
+			continue
+		}
+		if err := spec.Reload(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("reload %q: %w", spec.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RunWithSignals runs services until SIGINT or SIGTERM,
+// SIGHUP triggers Reload
+func (s *Supervisor) RunWithSignals(ctx context.Context) error {
+	ctx, cancel := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	go func() {
+		for {
+			select {
+			case <-hup:
+				s.logf("supervisor: reloading")
+				if err := s.Reload(ctx); err != nil {
+					s.logf("supervisor: %v", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return s.Run(ctx)
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
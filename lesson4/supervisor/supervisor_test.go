@@ -0,0 +1,220 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// recorder keeps events of services in order
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) get() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.events, ",")
+}
+
+func (r *recorder) service(name string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		r.add("start " + name)
+		Ready(ctx)
+		<-ctx.Done()
+		r.add("stop " + name)
+		return ctx.Err()
+	}
+}
+
+func newQuiet(stopTimeout time.Duration, specs ...Spec) *Supervisor {
+	s := New(stopTimeout, specs...)
+	s.SetLogger(nil)
+	return s
+}
+
+func TestOrderedStartStop(t *testing.T) {
+	r := &recorder{}
+	s := newQuiet(time.Second,
+		Spec{Name: "a", Run: r.service("a"), WaitReady: true},
+		Spec{Name: "b", Run: r.service("b"), WaitReady: true},
+		Spec{Name: "c", Run: r.service("c"), WaitReady: true},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := s.Run(ctx); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	expected := "start a,start b,start c,stop c,stop b,stop a"
+	if got := r.get(); got != expected {
+		t.Errorf("got events %q, expected %q", got, expected)
+	}
+}
+
+func TestRestartOnFailure(t *testing.T) {
+	errBoom := errors.New("boom")
+	runs := int32(0)
+	s := newQuiet(time.Second, Spec{
+		Name:       "flaky",
+		Restart:    OnFailure,
+		MinBackoff: time.Millisecond,
+		Run: func(ctx context.Context) error {
+			// fails twice, then finishes successfully
+			if atomic.AddInt32(&runs, 1) < 3 {
+				return errBoom
+			}
+			return nil
+		},
+	})
+
+	if err := s.Run(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if runs != 3 {
+		t.Errorf("got %d runs, expected 3", runs)
+	}
+}
+
+func TestNeverRestartFailureStopsAll(t *testing.T) {
+	errBoom := errors.New("boom")
+	r := &recorder{}
+	s := newQuiet(time.Second,
+		Spec{Name: "long", Run: r.service("long"), WaitReady: true},
+		Spec{Name: "broken", Run: func(ctx context.Context) error { return errBoom }},
+	)
+
+	err := s.Run(context.Background())
+	if !errors.Is(err, errBoom) {
+		t.Errorf("got error %v, expected %v", err, errBoom)
+	}
+	if got := r.get(); got != "start long,stop long" {
+		t.Errorf("got events %q", got)
+	}
+}
+
+func TestAlwaysRestart(t *testing.T) {
+	runs := int32(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	s := newQuiet(time.Second, Spec{
+		Name:       "loop",
+		Restart:    Always,
+		MinBackoff: time.Millisecond,
+		Run: func(context.Context) error {
+			if atomic.AddInt32(&runs, 1) == 5 {
+				cancel()
+			}
+			return nil
+		},
+	})
+
+	if err := s.Run(ctx); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if n := atomic.LoadInt32(&runs); n < 5 {
+		t.Errorf("got %d runs, expected at least 5", n)
+	}
+}
+
+func TestAlwaysRestartBackoff(t *testing.T) {
+	runs := int32(0)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	s := newQuiet(time.Second, Spec{
+		Name:       "spin",
+		Restart:    Always,
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 40 * time.Millisecond,
+		// returns at once, e.g. its input is closed
+		Run: func(context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			return nil
+		},
+	})
+
+	if err := s.Run(ctx); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// restarts after 10, 20, 40, 40... ms give 7 runs in 200ms
+	if n := atomic.LoadInt32(&runs); n < 4 || n > 10 {
+		t.Errorf("got %d runs in 200ms, expected about 7", n)
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	r := &recorder{}
+	s := newQuiet(50*time.Millisecond,
+		Spec{Name: "polite", Run: r.service("polite"), WaitReady: true},
+		Spec{Name: "stubborn", Run: func(ctx context.Context) error {
+			// ignores ctx
+			<-release
+			return nil
+		}},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	err := s.Run(ctx)
+	if err == nil || !strings.Contains(err.Error(), `"stubborn": stop timeout`) {
+		t.Errorf("got error %v, expected stop timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("stop took %v", elapsed)
+	}
+	// polite service is canceled even after timeout
+	time.Sleep(10 * time.Millisecond)
+	if got := r.get(); got != "start polite,stop polite" {
+		t.Errorf("got events %q", got)
+	}
+}
+
+func TestReloadOnSIGHUP(t *testing.T) {
+	reloaded := make(chan struct{}, 1)
+	r := &recorder{}
+	s := newQuiet(time.Second, Spec{
+		Name:      "config",
+		Run:       r.service("config"),
+		WaitReady: true,
+		Reload: func(context.Context) error {
+			reloaded <- struct{}{}
+			return nil
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() { errCh <- s.RunWithSignals(ctx) }()
+
+	// wait until service and signal handler are running
+	for r.get() == "" {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	syscall.Kill(syscall.Getpid(), syscall.SIGHUP)
+
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Error("reload was not called")
+	}
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"geekbrains/examples/lesson4/observe"
+)
+
+// MapFunc обрабатывает один входной элемент
+type MapFunc[In, Out any] func(ctx context.Context, in In) (Out, error)
+
+// Map обрабатывает {inputs} в пуле и возвращает результаты в порядке входа,
+// сортировка не нужна. На первой ошибке оставшиеся элементы не запускаются,
+// а ctx, переданный в {fn}, отменяется.
+// Элементы отправляются в пул по мере обработки, поэтому Close
+// ждет завершения Map целиком; чтобы прервать Map, отмените ctx
+func Map[In, Out any](ctx context.Context, p *BatchWorkerPool, inputs []In, fn MapFunc[In, Out]) ([]Out, error) {
+	outputs := make([]Out, len(inputs))
+	err := mapOrdered(ctx, p, inputs, fn, func(i int, out Out) {
+		outputs[i] = out
+	})
+	if err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+// MapReduce обрабатывает {inputs} в пуле и сворачивает результаты
+// функцией {combine} строго в порядке входа, начиная с {init}.
+// Все результаты в памяти не держим: в полете не больше
+// 2 * workers элементов, поэтому память не растет с размером входа
+func MapReduce[In, Out, Acc any](ctx context.Context, p *BatchWorkerPool, inputs []In,
+	fn MapFunc[In, Out], init Acc, combine func(acc Acc, out Out) Acc) (Acc, error) {
+	acc := init
+	err := mapOrdered(ctx, p, inputs, fn, func(_ int, out Out) {
+		acc = combine(acc, out)
+	})
+	return acc, err
+}
+
+type mapResult[Out any] struct {
+	idx int
+	out Out
+	err error
+}
+
+// mapOrdered запускает {fn} для каждого входа и вызывает {emit}
+// по порядку индексов. Результаты, пришедшие раньше своей очереди,
+// ждут в {pending}, размер которого ограничен окном.
+// closeMu держим до конца: входы отправляются в msgChan по ходу работы
+func mapOrdered[In, Out any](ctx context.Context, p *BatchWorkerPool, inputs []In,
+	fn MapFunc[In, Out], emit func(i int, out Out)) error {
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+	if p.closed {
+		p.reject(len(inputs))
+		return ErrClosed
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	window := 2 * p.workers
+	// observer should see panics too, it recovers them itself
+	observed := p.observer != nil
+	results := make(chan mapResult[Out], window)
+	pending := make(map[int]mapResult[Out], window)
+
+	sent, nextEmit, inFlight := 0, 0, 0
+	var firstErr error
+	for nextEmit < len(inputs) {
+		// шлем, пока окно не заполнено и нет ошибок
+		if firstErr == nil && sent < len(inputs) && sent-nextEmit < window {
+			if err := ctx.Err(); err != nil {
+				firstErr = err
+				continue
+			}
+			idx, in := sent, inputs[sent]
+			p.msgChan <- WorkerMsg{task: p.observe(func() {
+				defer func() {
+					// паника становится ошибкой элемента, процесс не падает;
+					// observer узнает о ней и перехватит сам
+					if r := recover(); r != nil {
+						perr := observe.NewPanicError(r)
+						results <- mapResult[Out]{idx: idx, err: perr}
+						if observed {
+							panic(perr)
+						}
+					}
+				}()
+				out, err := fn(ctx, in)
+				results <- mapResult[Out]{idx: idx, out: out, err: err}
+			})}
+			sent++
+			inFlight++
+			continue
+		}
+		if inFlight == 0 {
+			// ошибка и все отправленные уже вернулись
+			break
+		}
+
+		res := <-results
+		inFlight--
+		if res.err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("input %d: %w", res.idx, res.err)
+				cancel()
+			}
+			continue
+		}
+		if firstErr != nil {
+			continue
+		}
+		pending[res.idx] = res
+		// выдаем все, что уже готово по порядку
+		for {
+			ready, ok := pending[nextEmit]
+			if !ok {
+				break
+			}
+			delete(pending, nextEmit)
+			emit(nextEmit, ready.out)
+			nextEmit++
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"geekbrains/examples/lesson4/leak"
+	"geekbrains/examples/lesson4/observe"
+)
+
+func TestMain(m *testing.M) {
+	leak.VerifyTestMain(m)
+}
+
+func TestMapOrder(t *testing.T) {
+	pool := New(4)
+	defer pool.Close()
+
+	inputs := make([]int, 200)
+	for i := range inputs {
+		inputs[i] = i
+	}
+	// random delays mix up finish order
+	outputs, err := Map(context.Background(), pool, inputs, func(ctx context.Context, in int) (int, error) {
+		time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+		return in * 2, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, out := range outputs {
+		if out != i*2 {
+			t.Fatalf("output %d: got %d, expected %d", i, out, i*2)
+		}
+	}
+}
+
+func TestMapError(t *testing.T) {
+	pool := New(2)
+	defer pool.Close()
+
+	errBad := errors.New("bad input")
+	started := int32(0)
+	inputs := make([]int, 1000)
+	for i := range inputs {
+		inputs[i] = i
+	}
+	_, err := Map(context.Background(), pool, inputs, func(ctx context.Context, in int) (int, error) {
+		atomic.AddInt32(&started, 1)
+		if in == 10 {
+			return 0, errBad
+		}
+		return in, nil
+	})
+	if !errors.Is(err, errBad) {
+		t.Errorf("got error %v, expected %v", err, errBad)
+	}
+	// window is 2 * workers, so only a few inputs after the bad one are started
+	if n := atomic.LoadInt32(&started); n > 10+1+2*2 {
+		t.Errorf("started %d inputs after error", n)
+	}
+}
+
+func TestMapReduceWindow(t *testing.T) {
+	workers := 3
+	pool := New(workers)
+	defer pool.Close()
+
+	inputs := make([]int, 500)
+	for i := range inputs {
+		inputs[i] = i
+	}
+	inFlight, maxInFlight := int32(0), int32(0)
+	var order []int
+	sum, err := MapReduce(context.Background(), pool, inputs,
+		func(ctx context.Context, in int) (int, error) {
+			cur := atomic.AddInt32(&inFlight, 1)
+			for {
+				prev := atomic.LoadInt32(&maxInFlight)
+				if cur <= prev || atomic.CompareAndSwapInt32(&maxInFlight, prev, cur) {
+					break
+				}
+			}
+			defer atomic.AddInt32(&inFlight, -1)
+			return in, nil
+		},
+		0, func(acc, out int) int {
+			order = append(order, out)
+			return acc + out
+		})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sum != 500*499/2 {
+		t.Errorf("got sum %d, expected %d", sum, 500*499/2)
+	}
+	for i, out := range order {
+		if out != i {
+			t.Fatalf("combine order broken at %d: got %d", i, out)
+		}
+	}
+	if maxInFlight > int32(workers) {
+		t.Errorf("got %d tasks in flight, expected at most %d", maxInFlight, workers)
+	}
+}
+
+func TestClosedPool(t *testing.T) {
+	pool := New(2)
+	pool.Close()
+	pool.Close()
+
+	if err := pool.ExecuteBatch(func() {}); err != ErrClosed {
+		t.Errorf("ExecuteBatch: got %v, expected %v", err, ErrClosed)
+	}
+	_, err := Map(context.Background(), pool, []int{1}, func(ctx context.Context, in int) (int, error) {
+		return in, nil
+	})
+	if err != ErrClosed {
+		t.Errorf("Map: got %v, expected %v", err, ErrClosed)
+	}
+}
+
+func TestNoWorkers(t *testing.T) {
+	for _, workers := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("New(%d) didn't panic", workers)
+				}
+			}()
+			// Map on such pool would return zero outputs without error
+			New(workers)
+		}()
+	}
+}
+
+func TestCloseWaitsForMap(t *testing.T) {
+	pool := New(1)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mapped := make(chan error, 1)
+	go func() {
+		_, err := Map(context.Background(), pool, []int{1, 2, 3}, func(ctx context.Context, in int) (int, error) {
+			if in == 1 {
+				close(started)
+				<-release
+			}
+			return in, nil
+		})
+		mapped <- err
+	}()
+	<-started
+
+	closed := make(chan struct{})
+	go func() {
+		pool.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned while Map is running")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	<-closed
+	if err := <-mapped; err != nil {
+		t.Errorf("Map failed: %v", err)
+	}
+}
+
+func TestObserver(t *testing.T) {
+	metrics := observe.NewCollector()
+	pool := New(2)
+	pool.SetObserver(metrics.Pool("alter", 2))
+
+	pool.ExecuteBatch(func() {}, func() {})
+	_, err := Map(context.Background(), pool, []int{1, 2}, func(ctx context.Context, in int) (int, error) {
+		if in == 2 {
+			panic("boom")
+		}
+		return in, nil
+	})
+	if err == nil {
+		t.Error("expected error on panicked input")
+	}
+	pool.Close()
+	pool.ExecuteBatch(func() {})
+
+	s := metrics.Snapshot()[0]
+	if s.Submitted != 4 || s.Finished != 3 || s.Panicked != 1 || s.Rejected != 1 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
+
+func TestMapPanicWithoutObserver(t *testing.T) {
+	pool := New(2)
+	defer pool.Close()
+
+	_, err := Map(context.Background(), pool, []int{1, 2}, func(ctx context.Context, in int) (int, error) {
+		if in == 2 {
+			panic("boom")
+		}
+		return in, nil
+	})
+	var perr *observe.PanicError
+	if !errors.As(err, &perr) || perr.Value != "boom" {
+		t.Errorf("expected PanicError, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"geekbrains/examples/lesson4/observe"
+)
+
+// ErrClosed возвращается при попытке отправить таски в закрытый пул
+var ErrClosed = errors.New("batch worker pool is closed")
+
+type Task = func()
+
+type WorkerMsg struct {
+	task    Task
+	resChan chan struct{}
+}
+
+type BatchWorkerPool struct {
+	workers int
+	msgChan chan WorkerMsg
+
+	closeMu  sync.RWMutex
+	closed   bool
+	observer observe.Observer
+	wg       sync.WaitGroup
+}
+
+// Эта штука создает воркер пул с {workers} воркеров,
+// пул без воркеров ничего не исполнит, поэтому {workers} < 1 - паника
+func New(workers int) *BatchWorkerPool {
+	if workers < 1 {
+		panic("worker pool needs at least one worker")
+	}
+	p := &BatchWorkerPool{
+		workers: workers,
+		msgChan: make(chan WorkerMsg, workers),
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			for msg := range p.msgChan {
+				msg.task()
+				// nil - значит отправитель сам узнает о завершении
+				if msg.resChan != nil {
+					msg.resChan <- struct{}{}
+				}
+			}
+		}()
+	}
+	return p
+}
+
+// Эта функция принимает таски, исполняет их в пуле и ждет, пока все таски не завершатся
+func (p *BatchWorkerPool) ExecuteBatch(tasks ...Task) error {
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+	if p.closed {
+		p.reject(len(tasks))
+		return ErrClosed
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	resChan := make(chan struct{}, p.workers)
+	go func() {
+		defer cancel()
+		for tasksCount := len(tasks); tasksCount > 0; tasksCount-- {
+			<-resChan
+		}
+	}()
+
+	for _, task := range tasks {
+		p.msgChan <- WorkerMsg{
+			resChan: resChan,
+			task:    p.observe(task),
+		}
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+// SetObserver задает хуки для событий тасков, вызывать до отправки тасков.
+// Паники тасков перехватываются и передаются в observer
+func (p *BatchWorkerPool) SetObserver(o observe.Observer) {
+	p.closeMu.Lock()
+	defer p.closeMu.Unlock()
+
+	p.observer = o
+}
+
+// observe оборачивает таск для отчета о событиях, closeMu должен быть взят
+func (p *BatchWorkerPool) observe(task Task) Task {
+	if p.observer == nil {
+		return task
+	}
+	p.observer.OnSubmit()
+	return observe.Wrap(p.observer, task)
+}
+
+// reject сообщает об {n} отклоненных тасках, closeMu должен быть взят
+func (p *BatchWorkerPool) reject(n int) {
+	if p.observer == nil {
+		return
+	}
+	for i := 0; i < n; i++ {
+		p.observer.OnReject(ErrClosed)
+	}
+}
+
+// Close дожидается завершения текущих батчей и вызовов Map/MapReduce,
+// останавливает воркеры и ждет их завершения.
+// Повторный вызов ничего не делает
+func (p *BatchWorkerPool) Close() {
+	p.closeMu.Lock()
+	defer p.closeMu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.msgChan)
+	p.wg.Wait()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"geekbrains/examples/lesson4/observe"
+	"geekbrains/examples/lesson4/sched"
+)
+
+// TaskErr is a context-aware task which can fail
+type TaskErr = func(ctx context.Context) error
+
+type BatchOptions struct {
+	// Class is priority and tenant for all batch tasks
+	Class sched.Class
+	// FailFast cancels the rest of the batch on first error
+	FailFast bool
+}
+
+// BatchError aggregates errors of a batch
+type BatchError struct {
+	// Errors has an item per task in input order, nil for succeeded ones.
+	// Tasks skipped after fail-fast cancellation get the context error
+	Errors []error
+}
+
+func (e *BatchError) Error() string {
+	failed := e.Unwrap()
+	msgs := make([]string, 0, len(failed))
+	for i, err := range e.Errors {
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("task %d: %v", i, err))
+		}
+	}
+	return fmt.Sprintf("%d of %d tasks failed: %s",
+		len(failed), len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns non nil task errors, so errors.Is
+// and errors.As check each of them
+func (e *BatchError) Unwrap() []error {
+	var failed []error
+	for _, err := range e.Errors {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return failed
+}
+
+// DoBatchErr executes tasks in the pool and waits until all of them are done.
+// Returns *BatchError if some tasks failed or ErrClosed if pool is closed
+func (wp *WorkerPool) DoBatchErr(ctx context.Context, opts BatchOptions, tasks ...TaskErr) error {
+	funcs := make([]func(context.Context) (struct{}, error), len(tasks))
+	for i, task := range tasks {
+		task := task
+		funcs[i] = func(ctx context.Context) (struct{}, error) {
+			return struct{}{}, task(ctx)
+		}
+	}
+	_, err := DoBatchResults(ctx, wp, opts, funcs...)
+	return err
+}
+
+// DoBatchResults executes tasks in the pool and returns their results in input order.
+// Returns *BatchError if some tasks failed or ErrClosed if pool is closed,
+// results of failed tasks are zero values
+func DoBatchResults[T any](ctx context.Context, wp *WorkerPool, opts BatchOptions, tasks ...func(context.Context) (T, error)) ([]T, error) {
+	batchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// observer should see panics too, it recovers them itself
+	observed := wp.observed()
+
+	results := make([]T, len(tasks))
+	errs := make([]error, len(tasks))
+	wrapped := make([]TaskCtx, len(tasks))
+	for i, task := range tasks {
+		i, task := i, task
+		// every task writes only its own slot,
+		// DoBatchClass waits for all of them before we read
+		wrapped[i] = func(taskCtx context.Context) {
+			defer func() {
+				// panic becomes the task error instead of crashing
+				if r := recover(); r != nil {
+					perr := observe.NewPanicError(r)
+					errs[i] = perr
+					if opts.FailFast {
+						cancel()
+					}
+					if observed {
+						panic(perr)
+					}
+				}
+			}()
+			if err := taskCtx.Err(); err != nil {
+				// batch was canceled before task started
+				errs[i] = err
+				return
+			}
+			results[i], errs[i] = task(taskCtx)
+			if errs[i] != nil && opts.FailFast {
+				cancel()
+			}
+		}
+	}
+
+	if err := wp.DoBatchCtx(batchCtx, opts.Class, wrapped...); err != nil {
+		return nil, err
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return results, &BatchError{Errors: errs}
+		}
+	}
+	return results, nil
+}
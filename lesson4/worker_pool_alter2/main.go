@@ -1,10 +1,13 @@
 package main
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
 type WP interface {
-	Do(func())
-	DoBatch(...func())
+	Do(func()) error
+	DoBatch(...func()) error
 	Close()
 }
 
@@ -12,8 +15,17 @@ func printNum(i int) func() {
 	return func() { fmt.Println("i'm printing", i) }
 }
 
+func square(i int) func(context.Context) (int, error) {
+	return func(context.Context) (int, error) {
+		if i < 0 {
+			return 0, fmt.Errorf("negative number %d", i)
+		}
+		return i * i, nil
+	}
+}
+
 func main() {
-	var wp WP = NewWorkerPool(100)
+	wp := NewWorkerPool(100)
 	defer wp.Close()
 
 	tasks := []func(){}
@@ -25,4 +37,10 @@ func main() {
 	wp.DoBatch(tasks[:len(tasks)/2]...)
 	fmt.Println("================")
 	wp.DoBatch(tasks[len(tasks)/2:]...)
+	fmt.Println("================")
+
+	// results are returned in input order
+	squares, err := DoBatchResults(context.Background(), wp, BatchOptions{},
+		square(1), square(-2), square(3))
+	fmt.Println("squares:", squares, "error:", err)
 }
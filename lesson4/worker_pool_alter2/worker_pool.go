@@ -1,32 +1,80 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"sync"
+	"sync/atomic"
+
+	"geekbrains/examples/lesson4/observe"
+	"geekbrains/examples/lesson4/sched"
 )
 
 var _ WP = &WorkerPool{}
 
+// ErrClosed is returned on submit to closed pool
+var ErrClosed = errors.New("worker pool is closed")
+
 type Task = func()
 
+// TaskCtx gets ctx, which marks that the task runs in the pool,
+// nested batches must be submitted with it
+type TaskCtx = func(ctx context.Context)
+
+// workerKey marks ctx of tasks executed by the pool
+type workerKey struct {
+	wp *WorkerPool
+}
+
 type TaskStruct struct {
 	task Task
-	done chan struct{}
 }
 
 type WorkerPool struct {
 	tasksCh chan TaskStruct
 
-	closeMu sync.RWMutex
-	closed  bool
-	wg      sync.WaitGroup
+	// tasks wait in the queue until dispatcher
+	// hands them over to a free worker
+	queueMu  sync.Mutex
+	notFull  *sync.Cond
+	queue    sched.Scheduler
+	capacity int
+	// stopping is set by Close, queue accepts nothing after it
+	stopping bool
+	queued   chan struct{}
+	stop     chan struct{}
+
+	closeMu  sync.RWMutex
+	closed   bool
+	observer observe.Observer
+	wg       sync.WaitGroup
 }
 
+// NewWorkerPool creates pool with {workers} workers
+// and FIFO queue for {workers} pending tasks
 func NewWorkerPool(workers int) *WorkerPool {
-	tasksCh := make(chan TaskStruct, workers)
+	return NewScheduledWorkerPool(workers, workers, sched.NewFIFO())
+}
+
+// NewScheduledWorkerPool creates pool with {workers} workers and queue
+// for {queueSize} pending tasks ordered by {scheduler}.
+// Do and DoBatch block while the queue is full
+func NewScheduledWorkerPool(workers, queueSize int, scheduler sched.Scheduler) *WorkerPool {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	// unbuffered, so the order is decided by scheduler
+	// at the moment when worker becomes free
+	tasksCh := make(chan TaskStruct)
 
 	wp := &WorkerPool{
-		tasksCh: tasksCh,
+		tasksCh:  tasksCh,
+		queue:    scheduler,
+		capacity: queueSize,
+		queued:   make(chan struct{}, 1),
+		stop:     make(chan struct{}),
 	}
+	wp.notFull = sync.NewCond(&wp.queueMu)
 
 	wp.wg.Add(workers)
 	for i := 0; i < workers; i++ {
@@ -35,63 +83,248 @@ func NewWorkerPool(workers int) *WorkerPool {
 
 			for task := range tasksCh {
 				task.task()
-				task.done <- struct{}{}
 			}
 		}(i)
 	}
+	go wp.dispatch()
 
 	return wp
 }
 
-func (wp *WorkerPool) Do(task Task) {
+// dispatch moves tasks from the queue to workers,
+// closes tasksCh when pool is closed and queue is empty
+func (wp *WorkerPool) dispatch() {
+	for {
+		wp.queueMu.Lock()
+		job, ok := wp.queue.Pop()
+		if ok {
+			wp.notFull.Signal()
+		}
+		wp.queueMu.Unlock()
+
+		if ok {
+			wp.tasksCh <- job.Value.(TaskStruct)
+			continue
+		}
+
+		select {
+		case <-wp.queued:
+		case <-wp.stop:
+			// nobody can push after stop, check the rest
+			wp.queueMu.Lock()
+			empty := wp.queue.Len() == 0
+			wp.queueMu.Unlock()
+			if empty {
+				close(wp.tasksCh)
+				return
+			}
+		}
+	}
+}
+
+// push queues task, if {wait} is set it blocks while the queue is full.
+// {onQueued} is called under the queue lock, so before the task starts.
+// Returns false if the pool is stopping or the queue is full
+func (wp *WorkerPool) push(class sched.Class, task TaskStruct, wait bool, onQueued func()) bool {
+	wp.queueMu.Lock()
+	for wait && wp.queue.Len() >= wp.capacity && !wp.stopping {
+		wp.notFull.Wait()
+	}
+	if wp.stopping || wp.queue.Len() >= wp.capacity {
+		wp.queueMu.Unlock()
+		return false
+	}
+	if onQueued != nil {
+		onQueued()
+	}
+	wp.queue.Push(sched.Job{Class: class, Value: task})
+	wp.queueMu.Unlock()
+
+	// wake up dispatcher, skip if it is already notified
+	select {
+	case wp.queued <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func (wp *WorkerPool) Do(task Task) error {
+	return wp.DoClass(sched.Class{}, task)
+}
+
+// DoCtx queues task, which can submit nested batches by DoBatchCtx
+func (wp *WorkerPool) DoCtx(task TaskCtx) error {
+	ctx := wp.workerCtx(context.Background())
+	return wp.DoClass(sched.Class{}, func() { task(ctx) })
+}
+
+// workerCtx marks {ctx} as belonging to a task of the pool
+func (wp *WorkerPool) workerCtx(ctx context.Context) context.Context {
+	return context.WithValue(ctx, workerKey{wp}, true)
+}
+
+// DoClass queues task with given priority and tenant,
+// blocks while the queue is full
+func (wp *WorkerPool) DoClass(class sched.Class, task Task) error {
+	wp.closeMu.RLock()
+	closed, observer := wp.closed, wp.observer
+	wp.closeMu.RUnlock()
+
+	var onQueued func()
+	if observer != nil {
+		task = observe.Wrap(observer, task)
+		onQueued = observer.OnSubmit
+	}
+	// closeMu is not held while waiting for free space,
+	// Close stops the waiting instead
+	if closed || !wp.push(class, TaskStruct{task: task}, true, onQueued) {
+		if observer != nil {
+			observer.OnReject(ErrClosed)
+		}
+		return ErrClosed
+	}
+	return nil
+}
+
+// SetObserver sets hooks for task events, should be called before submits.
+// Task panics are recovered and reported to the observer
+func (wp *WorkerPool) SetObserver(o observe.Observer) {
+	wp.closeMu.Lock()
+	defer wp.closeMu.Unlock()
+
+	wp.observer = o
+}
+
+func (wp *WorkerPool) observed() bool {
 	wp.closeMu.RLock()
 	defer wp.closeMu.RUnlock()
-	if wp.closed {
-		return
+
+	return wp.observer != nil
+}
+
+// observe wraps task to report its events, closeMu should be held
+func (wp *WorkerPool) observe(task Task) Task {
+	if wp.observer == nil {
+		return task
 	}
+	wp.observer.OnSubmit()
+	return observe.Wrap(wp.observer, task)
+}
 
-	wp.tasksCh <- TaskStruct{
-		task: task,
-		done: make(chan struct{}, 1),
+// reject reports rejected task, closeMu should be held
+func (wp *WorkerPool) reject() {
+	if wp.observer != nil {
+		wp.observer.OnReject(ErrClosed)
 	}
 }
 
-func (wp *WorkerPool) DoBatch(tasks ...Task) {
+func (wp *WorkerPool) DoBatch(tasks ...Task) error {
+	return wp.DoBatchClass(sched.Class{}, tasks...)
+}
+
+// DoBatchClass queues tasks with given priority and tenant
+// and waits until all of them are done.
+// Tasks submitting nested batches should use DoBatchCtx
+func (wp *WorkerPool) DoBatchClass(class sched.Class, tasks ...Task) error {
+	ctxTasks := make([]TaskCtx, len(tasks))
+	for i, task := range tasks {
+		task := task
+		ctxTasks[i] = func(context.Context) { task() }
+	}
+	return wp.DoBatchCtx(context.Background(), class, ctxTasks...)
+}
+
+// DoBatchCtx queues tasks with given priority and tenant
+// and waits until all of them are done.
+//
+// It is safe to call DoBatchCtx from a task running in the same pool,
+// if the task passes ctx it got: instead of blocking the worker,
+// the calling worker executes tasks of its batch itself
+// while other workers steal the rest.
+// Such nested batches are executed inline even if the pool is closed,
+// so outer tasks can finish
+func (wp *WorkerPool) DoBatchCtx(ctx context.Context, class sched.Class, tasks ...TaskCtx) error {
+	nested := ctx.Value(workerKey{wp}) != nil
+	// batch tasks are always executed by workers
+	taskCtx := wp.workerCtx(ctx)
+
 	wp.closeMu.RLock()
-	defer wp.closeMu.RUnlock()
-	if wp.closed {
-		return
+	if wp.closed && !nested {
+		for range tasks {
+			wp.reject()
+		}
+		wp.closeMu.RUnlock()
+		return ErrClosed
+	}
+	b := &batch{
+		tasks: make([]Task, len(tasks)),
+		done:  make(chan struct{}, len(tasks)),
 	}
+	for i, task := range tasks {
+		task := task
+		b.tasks[i] = wp.observe(func() { task(taskCtx) })
+	}
+	closed := wp.closed
+	wp.closeMu.RUnlock()
 
-	doneCh := make(chan struct{})
-	addedTasks := 0
-
-	for _, task := range tasks {
-		for taskAdded := false; !taskAdded; {
-			select {
-			case wp.tasksCh <- TaskStruct{
-				task: task,
-				done: doneCh,
-			}:
-				addedTasks++
-				taskAdded = true
-			case <-doneCh:
-				addedTasks--
-			}
+	// each stub runs one not yet claimed task of the batch.
+	// Nested batch doesn't wait for free space in the queue,
+	// the calling worker runs not queued tasks itself
+	steal := func() { b.runNext() }
+	inline := nested
+	for range tasks {
+		if closed || !wp.push(class, TaskStruct{task: steal}, !nested, nil) {
+			// pool stopped in the middle, finish the batch here
+			inline = true
+			break
+		}
+	}
+	if inline {
+		for b.runNext() {
 		}
 	}
 
-	for ; addedTasks > 0; addedTasks-- {
-		<-doneCh
+	for range tasks {
+		<-b.done
 	}
+	return nil
+}
+
+// batch is a list of tasks claimed one by one
+// by pool workers or by the waiting worker itself
+type batch struct {
+	tasks []Task
+	next  int32
+	done  chan struct{}
+}
+
+// runNext executes next not claimed task,
+// returns false if all tasks are already claimed
+func (b *batch) runNext() bool {
+	i := int(atomic.AddInt32(&b.next, 1)) - 1
+	if i >= len(b.tasks) {
+		return false
+	}
+	b.tasks[i]()
+	b.done <- struct{}{}
+	return true
 }
 
 func (wp *WorkerPool) Close() {
 	wp.closeMu.Lock()
+	if wp.closed {
+		wp.closeMu.Unlock()
+		return
+	}
 	wp.closed = true
 	wp.closeMu.Unlock()
 
-	close(wp.tasksCh)
+	wp.queueMu.Lock()
+	wp.stopping = true
+	wp.notFull.Broadcast()
+	wp.queueMu.Unlock()
+
+	close(wp.stop)
 
 	wp.wg.Wait()
 }
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"geekbrains/examples/lesson4/leak"
+	"geekbrains/examples/lesson4/observe"
+	"geekbrains/examples/lesson4/sched"
+)
+
+func TestMain(m *testing.M) {
+	leak.VerifyTestMain(m)
+}
+
+func TestDoBatchResultsOrder(t *testing.T) {
+	wp := NewWorkerPool(4)
+	defer wp.Close()
+
+	tasks := []func(context.Context) (int, error){}
+	for i := 0; i < 100; i++ {
+		i := i
+		tasks = append(tasks, func(context.Context) (int, error) { return i * i, nil })
+	}
+
+	results, err := DoBatchResults(context.Background(), wp, BatchOptions{}, tasks...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, res := range results {
+		if res != i*i {
+			t.Errorf("result %d: got %d, expected %d", i, res, i*i)
+		}
+	}
+}
+
+func TestDoBatchErrCollectsAll(t *testing.T) {
+	wp := NewWorkerPool(4)
+	defer wp.Close()
+
+	errOdd := errors.New("odd")
+	tasks := []TaskErr{}
+	for i := 0; i < 10; i++ {
+		i := i
+		tasks = append(tasks, func(context.Context) error {
+			if i%2 == 1 {
+				return errOdd
+			}
+			return nil
+		})
+	}
+
+	err := wp.DoBatchErr(context.Background(), BatchOptions{}, tasks...)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("got error %v, expected *BatchError", err)
+	}
+	if !errors.Is(err, errOdd) {
+		t.Errorf("errors.Is(%v, errOdd) = false", err)
+	}
+	for i, taskErr := range batchErr.Errors {
+		if (i%2 == 1) != (taskErr != nil) {
+			t.Errorf("task %d: unexpected error %v", i, taskErr)
+		}
+	}
+}
+
+func TestDoBatchErrFailFast(t *testing.T) {
+	// single worker executes tasks one by one
+	wp := NewWorkerPool(1)
+	defer wp.Close()
+
+	errFirst := errors.New("first")
+	executed := int32(0)
+	tasks := []TaskErr{func(context.Context) error {
+		atomic.AddInt32(&executed, 1)
+		return errFirst
+	}}
+	for i := 0; i < 10; i++ {
+		tasks = append(tasks, func(context.Context) error {
+			atomic.AddInt32(&executed, 1)
+			return nil
+		})
+	}
+
+	err := wp.DoBatchErr(context.Background(), BatchOptions{FailFast: true}, tasks...)
+	if !errors.Is(err, errFirst) {
+		t.Errorf("got error %v, expected %v", err, errFirst)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("skipped tasks should report context.Canceled, got %v", err)
+	}
+	if executed != 1 {
+		t.Errorf("executed %d tasks, expected 1", executed)
+	}
+}
+
+func TestClosedPool(t *testing.T) {
+	wp := NewWorkerPool(1)
+	wp.Close()
+
+	if err := wp.Do(func() {}); err != ErrClosed {
+		t.Errorf("Do: got %v, expected %v", err, ErrClosed)
+	}
+	if err := wp.DoBatch(func() {}); err != ErrClosed {
+		t.Errorf("DoBatch: got %v, expected %v", err, ErrClosed)
+	}
+	_, err := DoBatchResults(context.Background(), wp, BatchOptions{},
+		func(context.Context) (int, error) { return 1, nil })
+	if err != ErrClosed {
+		t.Errorf("DoBatchResults: got %v, expected %v", err, ErrClosed)
+	}
+}
+
+// parallelMergeSort sorts halves of {values} as nested batches in the same pool
+func parallelMergeSort(ctx context.Context, wp *WorkerPool, values []int) []int {
+	if len(values) <= 4 {
+		sorted := append([]int{}, values...)
+		sort.Ints(sorted)
+		return sorted
+	}
+	var left, right []int
+	mid := len(values) / 2
+	wp.DoBatchCtx(ctx, sched.Class{},
+		func(ctx context.Context) { left = parallelMergeSort(ctx, wp, values[:mid]) },
+		func(ctx context.Context) { right = parallelMergeSort(ctx, wp, values[mid:]) },
+	)
+
+	merged := make([]int, 0, len(values))
+	for len(left) > 0 && len(right) > 0 {
+		if left[0] <= right[0] {
+			merged, left = append(merged, left[0]), left[1:]
+		} else {
+			merged, right = append(merged, right[0]), right[1:]
+		}
+	}
+	merged = append(merged, left...)
+	return append(merged, right...)
+}
+
+// runWithTimeout fails the test if {f} looks deadlocked
+func runWithTimeout(t *testing.T, f func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadlock: nested batch didn't finish")
+	}
+}
+
+func TestNestedBatchMergeSort(t *testing.T) {
+	// recursion is much deeper than workers count
+	wp := NewWorkerPool(2)
+	defer wp.Close()
+
+	values := rand.Perm(1000)
+	var sorted []int
+	runWithTimeout(t, func() {
+		sorted = parallelMergeSort(context.Background(), wp, values)
+	})
+	if !sort.IntsAreSorted(sorted) || len(sorted) != len(values) {
+		t.Errorf("values are not sorted: %v", sorted)
+	}
+}
+
+func TestNestedBatchResults(t *testing.T) {
+	wp := NewWorkerPool(1)
+	defer wp.Close()
+
+	// sum of tree with 3^5 leaves, each inner node is a nested batch
+	var treeSum func(depth int) func(context.Context) (int, error)
+	treeSum = func(depth int) func(context.Context) (int, error) {
+		return func(ctx context.Context) (int, error) {
+			if depth == 0 {
+				return 1, nil
+			}
+			sums, err := DoBatchResults(ctx, wp, BatchOptions{},
+				treeSum(depth-1), treeSum(depth-1), treeSum(depth-1))
+			total := 0
+			for _, sum := range sums {
+				total += sum
+			}
+			return total, err
+		}
+	}
+
+	var got []int
+	var err error
+	runWithTimeout(t, func() {
+		got, err = DoBatchResults(context.Background(), wp, BatchOptions{}, treeSum(5))
+	})
+	if err != nil || got[0] != 243 {
+		t.Errorf("got %v, %v, expected 243", got, err)
+	}
+}
+
+func TestNestedBatchOnClose(t *testing.T) {
+	wp := NewWorkerPool(1)
+	started := make(chan struct{})
+	closing := make(chan struct{})
+	nestedErr := make(chan error, 1)
+	wp.DoCtx(func(ctx context.Context) {
+		close(started)
+		<-closing
+		nestedErr <- wp.DoBatchCtx(ctx, sched.Class{}, func(context.Context) {}, func(context.Context) {})
+	})
+	<-started
+
+	go func() {
+		// let Close mark the pool closed
+		time.Sleep(10 * time.Millisecond)
+		close(closing)
+	}()
+	runWithTimeout(t, wp.Close)
+
+	if err := <-nestedErr; err != nil {
+		t.Errorf("nested batch on closing pool failed: %v", err)
+	}
+}
+
+func TestWorkerPoolObserver(t *testing.T) {
+	metrics := observe.NewCollector()
+	wp := NewWorkerPool(2)
+	wp.SetObserver(metrics.Pool("alter2", 2))
+
+	wp.DoBatch(func() {}, func() { panic("boom") }, func() {})
+	wp.Close()
+	wp.Do(func() {})
+
+	s := metrics.Snapshot()[0]
+	if s.Submitted != 3 || s.Finished != 2 || s.Panicked != 1 || s.Rejected != 1 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
+
+func TestObservedBatchPanic(t *testing.T) {
+	wp := NewWorkerPool(2)
+	defer wp.Close()
+	wp.SetObserver(observe.NewCollector().Pool("alter2", 2))
+
+	_, err := DoBatchResults(context.Background(), wp, BatchOptions{},
+		func(context.Context) (int, error) { panic("boom") })
+	if err == nil {
+		t.Error("expected error on panicked task")
+	}
+}
+
+func TestDoBlocksOnFullQueue(t *testing.T) {
+	wp := NewScheduledWorkerPool(1, 2, sched.NewFIFO())
+	release := make(chan struct{})
+	wp.Do(func() { <-release })
+	// wait until the worker takes the first task
+	time.Sleep(10 * time.Millisecond)
+
+	// one task waits in dispatcher, two in the queue
+	for i := 0; i < 3; i++ {
+		wp.Do(func() {})
+	}
+	submitted := make(chan error, 1)
+	go func() {
+		submitted <- wp.Do(func() {})
+	}()
+	select {
+	case <-submitted:
+		t.Fatal("Do didn't block on full queue")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-submitted:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Do is still blocked after queue drained")
+	}
+	wp.Close()
+}
+
+func TestCloseUnblocksDo(t *testing.T) {
+	wp := NewScheduledWorkerPool(1, 1, sched.NewFIFO())
+	release := make(chan struct{})
+	wp.Do(func() { <-release })
+	time.Sleep(10 * time.Millisecond)
+	wp.Do(func() {})
+	wp.Do(func() {})
+
+	submitted := make(chan error, 1)
+	go func() {
+		submitted <- wp.Do(func() {})
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		wp.Close()
+		close(closed)
+	}()
+	select {
+	case err := <-submitted:
+		if err != ErrClosed {
+			t.Errorf("expected ErrClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close didn't unblock Do")
+	}
+	close(release)
+	<-closed
+}
+
+func TestBatchPanicWithoutObserver(t *testing.T) {
+	wp := NewWorkerPool(2)
+	defer wp.Close()
+
+	_, err := DoBatchResults(context.Background(), wp, BatchOptions{},
+		func(context.Context) (int, error) { return 1, nil },
+		func(context.Context) (int, error) { panic("boom") })
+	var perr *observe.PanicError
+	if !errors.As(err, &perr) || perr.Value != "boom" || len(perr.Stack) == 0 {
+		t.Errorf("expected PanicError, got %v", err)
+	}
+}
@@ -1,35 +1,41 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"time"
+
+	"geekbrains/examples/lesson5/batcher"
 )
 
 func main() {
-	batches := [][]int{}
-	batch := make([]int, 0, 16)
-	for i := 0; i < 1000; i++ {
-		batch = append(batch, i)
-
-		if len(batch) == 16 {
-			batches = append(batches, batch)
-			batch = make([]int, 0, 16)
-		}
-	}
-
-	if len(batch) != 0 {
-		batches = append(batches, batch)
-	}
+	// items arrive from several producers, batches are cut
+	// on 16 items or after 10ms of waiting, whichever first
+	b := batcher.New(func(_ context.Context, batch []int) error {
+		fmt.Printf("processing batch %+v\n", batch)
+		return nil
+	}, batcher.Options[int]{
+		MaxItems:    16,
+		Linger:      10 * time.Millisecond,
+		Concurrency: 4,
+	})
 
 	wg := sync.WaitGroup{}
-
-	for i, batch := range batches {
+	for p := 0; p < 4; p++ {
 		wg.Add(1)
-		go func(i int, batch []int) {
+		go func(p int) {
 			defer wg.Done()
-			fmt.Printf("processing batch %d %+v\n", i, batch)
-		}(i, batch)
+			for i := p * 250; i < (p+1)*250; i++ {
+				b.Add(i)
+			}
+		}(p)
 	}
-
 	wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := b.Close(ctx); err != nil {
+		fmt.Println("close:", err)
+	}
 }
@@ -0,0 +1,298 @@
+// Package batcher accumulates items from many goroutines
+// and hands them to a flush handler in batches
+//
+//	b := batcher.New(saveRows, batcher.Options[Row]{MaxItems: 100, Linger: time.Second})
+//	b.Add(row)
+//	...
+//	err := b.Close(ctx)
+package batcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned on add to closed batcher
+var ErrClosed = errors.New("batcher is closed")
+
+// maxErrors limits errors kept until Flush or Close,
+// the rest are only counted, OnError gets all of them
+const maxErrors = 16
+
+// FlushFunc handles one batch, ctx is canceled when batcher
+// is aborted by Close, which waits for the flush to return.
+// The batch must not be retained after return
+type FlushFunc[T any] func(ctx context.Context, batch []T) error
+
+type Options[T any] struct {
+	// MaxItems flushes batch when it has this many items, default is 16
+	MaxItems int
+	// MaxBytes flushes batch before its total Size exceeds this value,
+	// 0 means no limit. An item larger than MaxBytes is flushed alone
+	MaxBytes int
+	Size     func(T) int
+	// Linger flushes not full batch this long after its first item,
+	// 0 means batch waits until it is full or Flush is called
+	Linger time.Duration
+	// Concurrency limits flushes running at once, default is 1.
+	// Add blocks while all flushes are busy and its batch is full
+	Concurrency int
+	// Retries of failed flush, each after Backoff doubled on every attempt
+	Retries int
+	Backoff time.Duration
+	// OnError is called with a batch which failed after all retries,
+	// it may be called concurrently
+	OnError func(batch []T, err error)
+}
+
+type Batcher[T any] struct {
+	flush FlushFunc[T]
+	opts  Options[T]
+	sem   chan struct{}
+
+	// ctx is passed to flushes, cancel aborts them
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mx     sync.Mutex
+	batch  []T
+	bytes  int
+	closed bool
+	// timer flushes the batch of generation gen by linger
+	timer *time.Timer
+	gen   uint64
+	// inflight counts cut batches which are not flushed yet,
+	// idle is closed when it drops to zero
+	inflight int
+	idle     chan struct{}
+	errs     []error
+	// dropped counts errors over maxErrors
+	dropped int
+}
+
+// New creates batcher, which passes batches to {flush}
+func New[T any](flush FlushFunc[T], opts Options[T]) *Batcher[T] {
+	if opts.MaxItems <= 0 {
+		opts.MaxItems = 16
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.MaxBytes > 0 && opts.Size == nil {
+		panic("batcher: MaxBytes requires Size")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	idle := make(chan struct{})
+	close(idle)
+	return &Batcher[T]{
+		flush:  flush,
+		opts:   opts,
+		sem:    make(chan struct{}, opts.Concurrency),
+		ctx:    ctx,
+		cancel: cancel,
+		idle:   idle,
+	}
+}
+
+// Add puts {item} to the current batch and flushes the batch if it is full
+func (b *Batcher[T]) Add(item T) error {
+	size := 0
+	if b.opts.MaxBytes > 0 {
+		size = b.opts.Size(item)
+	}
+
+	b.mx.Lock()
+	if b.closed {
+		b.mx.Unlock()
+		return ErrClosed
+	}
+	var full [][]T
+	if b.opts.MaxBytes > 0 && len(b.batch) > 0 && b.bytes+size > b.opts.MaxBytes {
+		full = append(full, b.cut())
+	}
+	if len(b.batch) == 0 && b.opts.Linger > 0 {
+		gen := b.gen
+		b.timer = time.AfterFunc(b.opts.Linger, func() { b.lingerFlush(gen) })
+	}
+	b.batch = append(b.batch, item)
+	b.bytes += size
+	if len(b.batch) >= b.opts.MaxItems || (b.opts.MaxBytes > 0 && b.bytes >= b.opts.MaxBytes) {
+		full = append(full, b.cut())
+	}
+	b.mx.Unlock()
+
+	for _, batch := range full {
+		b.dispatch(batch)
+	}
+	return nil
+}
+
+// Flush flushes the current batch and waits until no flushes are running.
+// It returns errors of batches failed since the previous Flush,
+// at most maxErrors of them and the number of others
+func (b *Batcher[T]) Flush(ctx context.Context) error {
+	b.mx.Lock()
+	batch := b.cut()
+	b.mx.Unlock()
+
+	if batch != nil {
+		b.dispatch(batch)
+	}
+	return b.wait(ctx)
+}
+
+// Close stops accepting items and flushes the rest.
+// If ctx expires first, running flushes are canceled
+// and not started batches are dropped with ctx error,
+// Close returns after canceled flushes have returned
+func (b *Batcher[T]) Close(ctx context.Context) error {
+	b.mx.Lock()
+	if b.closed {
+		b.mx.Unlock()
+		return ErrClosed
+	}
+	b.closed = true
+	batch := b.cut()
+	b.mx.Unlock()
+
+	if batch != nil {
+		// slot may be never freed if flush hangs, so abort on ctx
+		go func() {
+			select {
+			case <-ctx.Done():
+				b.cancel()
+			case <-b.ctx.Done():
+			}
+		}()
+		b.dispatch(batch)
+	}
+	err := b.wait(ctx)
+	b.cancel()
+	if ctx.Err() != nil {
+		<-b.idleChan()
+	}
+	return err
+}
+
+// cut takes the current batch, b.mx should be held
+func (b *Batcher[T]) cut() []T {
+	if len(b.batch) == 0 {
+		return nil
+	}
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.gen++
+
+	batch := b.batch
+	b.batch = make([]T, 0, len(batch))
+	b.bytes = 0
+	if b.inflight == 0 {
+		b.idle = make(chan struct{})
+	}
+	b.inflight++
+	return batch
+}
+
+func (b *Batcher[T]) lingerFlush(gen uint64) {
+	b.mx.Lock()
+	var batch []T
+	if gen == b.gen {
+		batch = b.cut()
+	}
+	b.mx.Unlock()
+
+	if batch != nil {
+		b.dispatch(batch)
+	}
+}
+
+// dispatch waits for a free slot and flushes {batch} aside
+func (b *Batcher[T]) dispatch(batch []T) {
+	select {
+	case b.sem <- struct{}{}:
+	case <-b.ctx.Done():
+		b.done(batch, b.ctx.Err())
+		return
+	}
+	go func() {
+		err := b.flushRetry(batch)
+		<-b.sem
+		b.done(batch, err)
+	}()
+}
+
+func (b *Batcher[T]) flushRetry(batch []T) error {
+	backoff := b.opts.Backoff
+	for attempt := 0; ; attempt++ {
+		err := b.flush(b.ctx, batch)
+		if err == nil || attempt >= b.opts.Retries {
+			return err
+		}
+		if backoff > 0 {
+			t := time.NewTimer(backoff)
+			select {
+			case <-t.C:
+			case <-b.ctx.Done():
+				t.Stop()
+				return err
+			}
+			backoff *= 2
+		}
+		if b.ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+func (b *Batcher[T]) done(batch []T, err error) {
+	if err != nil && b.opts.OnError != nil {
+		b.opts.OnError(batch, err)
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	if err != nil {
+		if len(b.errs) < maxErrors {
+			b.errs = append(b.errs, err)
+		} else {
+			b.dropped++
+		}
+	}
+	b.inflight--
+	if b.inflight == 0 {
+		close(b.idle)
+	}
+}
+
+// wait blocks until no flushes are running and returns collected errors
+func (b *Batcher[T]) wait(ctx context.Context) error {
+	select {
+	case <-b.idleChan():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	errs := b.errs
+	if b.dropped > 0 {
+		errs = append(errs, fmt.Errorf("batcher: %d more flushes failed", b.dropped))
+	}
+	b.errs, b.dropped = nil, 0
+	return errors.Join(errs...)
+}
+
+// idleChan returns channel closed when no flushes are running
+func (b *Batcher[T]) idleChan() <-chan struct{} {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	return b.idle
+}
@@ -0,0 +1,263 @@
+package batcher
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recorder collects flushed batches
+type recorder struct {
+	mx      sync.Mutex
+	batches [][]int
+}
+
+func (r *recorder) flush(_ context.Context, batch []int) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	r.batches = append(r.batches, append([]int(nil), batch...))
+	return nil
+}
+
+func (r *recorder) items() []int {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	var res []int
+	for _, b := range r.batches {
+		res = append(res, b...)
+	}
+	sort.Ints(res)
+	return res
+}
+
+func checkAll(t *testing.T, got []int, n int) {
+	t.Helper()
+	if len(got) != n {
+		t.Fatalf("got %d items, expect %d", len(got), n)
+	}
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("item %d is %d, lost or duplicated", i, v)
+		}
+	}
+}
+
+func TestMaxItems(t *testing.T) {
+	r := &recorder{}
+	b := New(r.flush, Options[int]{MaxItems: 10})
+	for i := 0; i < 95; i++ {
+		b.Add(i)
+	}
+	if err := b.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.batches) != 10 {
+		t.Fatalf("got %d batches, expect 10", len(r.batches))
+	}
+	for i, batch := range r.batches[:9] {
+		if len(batch) != 10 {
+			t.Errorf("batch %d has %d items", i, len(batch))
+		}
+	}
+	checkAll(t, r.items(), 95)
+}
+
+func TestMaxBytes(t *testing.T) {
+	r := &recorder{}
+	b := New(r.flush, Options[int]{
+		MaxItems: 1000,
+		MaxBytes: 10,
+		Size:     func(v int) int { return v },
+	})
+	for _, v := range []int{3, 4, 5, 10, 2, 20, 1} {
+		b.Add(v)
+	}
+	b.Close(context.Background())
+
+	expect := [][]int{{3, 4}, {5}, {10}, {2}, {20}, {1}}
+	if len(r.batches) != len(expect) {
+		t.Fatalf("got %v, expect %v", r.batches, expect)
+	}
+	for i := range expect {
+		if len(r.batches[i]) != len(expect[i]) || r.batches[i][0] != expect[i][0] {
+			t.Fatalf("got %v, expect %v", r.batches, expect)
+		}
+	}
+}
+
+func TestLinger(t *testing.T) {
+	flushed := make(chan []int, 1)
+	b := New(func(_ context.Context, batch []int) error {
+		flushed <- append([]int(nil), batch...)
+		return nil
+	}, Options[int]{MaxItems: 100, Linger: 20 * time.Millisecond})
+	defer b.Close(context.Background())
+
+	start := time.Now()
+	b.Add(1)
+	b.Add(2)
+	select {
+	case batch := <-flushed:
+		if len(batch) != 2 {
+			t.Errorf("got %v", batch)
+		}
+		if d := time.Since(start); d < 20*time.Millisecond {
+			t.Errorf("flushed after %v, before linger", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("batch was not flushed by linger")
+	}
+}
+
+func TestConcurrentAdd(t *testing.T) {
+	const writers, perWriter = 8, 1000
+
+	r := &recorder{}
+	var running, peak int32
+	b := New(func(ctx context.Context, batch []int) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(100 * time.Microsecond)
+		return r.flush(ctx, batch)
+	}, Options[int]{MaxItems: 7, Concurrency: 3, Linger: time.Millisecond})
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				b.Add(w*perWriter + i)
+			}
+		}(w)
+	}
+	wg.Wait()
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	checkAll(t, r.items(), writers*perWriter)
+	if peak > 3 {
+		t.Errorf("%d flushes ran at once, limit 3", peak)
+	}
+	if err := b.Add(0); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	errFlaky := errors.New("flaky")
+	var calls int32
+	r := &recorder{}
+	b := New(func(ctx context.Context, batch []int) error {
+		// every batch fails twice
+		if atomic.AddInt32(&calls, 1)%3 != 0 {
+			return errFlaky
+		}
+		return r.flush(ctx, batch)
+	}, Options[int]{MaxItems: 5, Retries: 2, Backoff: time.Millisecond})
+
+	for i := 0; i < 20; i++ {
+		b.Add(i)
+	}
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	checkAll(t, r.items(), 20)
+	if calls != 12 {
+		t.Errorf("got %d calls, expect 12", calls)
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	errDown := errors.New("down")
+	var mx sync.Mutex
+	var failed [][]int
+	b := New(func(context.Context, []int) error { return errDown }, Options[int]{
+		MaxItems: 2,
+		Retries:  1,
+		OnError: func(batch []int, err error) {
+			mx.Lock()
+			defer mx.Unlock()
+			failed = append(failed, batch)
+		},
+	})
+	b.Add(1)
+	b.Add(2)
+	b.Add(3)
+
+	err := b.Flush(context.Background())
+	if !errors.Is(err, errDown) {
+		t.Fatalf("expected errDown, got %v", err)
+	}
+	if len(failed) != 2 {
+		t.Errorf("OnError got %v", failed)
+	}
+	// errors are reported once
+	if err := b.Flush(context.Background()); err != nil {
+		t.Errorf("second flush: %v", err)
+	}
+}
+
+func TestCloseTimeout(t *testing.T) {
+	started := make(chan struct{}, 1)
+	var aborted int32
+	b := New(func(ctx context.Context, batch []int) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		atomic.StoreInt32(&aborted, 1)
+		return ctx.Err()
+	}, Options[int]{MaxItems: 1})
+
+	b.Add(1)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := b.Close(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline, got %v", err)
+	}
+	if atomic.LoadInt32(&aborted) == 0 {
+		t.Fatal("Close returned before canceled flush")
+	}
+}
+
+func TestErrorsLimit(t *testing.T) {
+	errDown := errors.New("down")
+	var reported int32
+	b := New(func(context.Context, []int) error { return errDown }, Options[int]{
+		MaxItems: 1,
+		OnError:  func([]int, error) { atomic.AddInt32(&reported, 1) },
+	})
+	// nobody calls Flush, errors are not piled up
+	for i := 0; i < 100; i++ {
+		b.Add(i)
+	}
+	for atomic.LoadInt32(&reported) < 100 {
+		time.Sleep(time.Millisecond)
+	}
+	b.mx.Lock()
+	kept := len(b.errs)
+	b.mx.Unlock()
+	if kept > maxErrors {
+		t.Errorf("%d errors kept, limit %d", kept, maxErrors)
+	}
+
+	err := b.Close(context.Background())
+	if !errors.Is(err, errDown) || !strings.Contains(err.Error(), "84 more flushes failed") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
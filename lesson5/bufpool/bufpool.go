@@ -0,0 +1,195 @@
+// Package bufpool pools byte buffers by power of two size classes.
+//
+// Unlike a single sync.Pool, a huge buffer doesn't serve tiny requests
+// and buffers larger than MaxSize are never pooled, so one big request
+// can't pin its memory forever
+//
+//	p := bufpool.New(bufpool.Options{MaxSize: 1 << 20})
+//	buf := p.Get(n)
+//	defer p.Put(buf)
+package bufpool
+
+import (
+	"bytes"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultMinSize = 64
+	defaultMaxSize = 64 << 10
+)
+
+type Options struct {
+	// MinSize is capacity of the smallest class, default is 64
+	MinSize int
+	// MaxSize is capacity of the largest class, default is 64K.
+	// Larger buffers are allocated on Get and dropped on Put
+	MaxSize int
+	// Debug tracks buffers taken from the pool, see Outstanding
+	Debug bool
+}
+
+// Stats are counters of pool usage
+type Stats struct {
+	Gets int64
+	Puts int64
+	// News counts allocations on Get, when pool had nothing to reuse
+	News int64
+	// Dropped counts buffers not retained by Put because of their size
+	Dropped int64
+}
+
+type Pool struct {
+	minShift int
+	maxShift int
+	// classes[i] keeps *[]byte with capacity at least 1<<(minShift+i)
+	classes []sync.Pool
+	// buffers[i] keeps *bytes.Buffer the same way
+	buffers []sync.Pool
+	// holders reuses *[]byte, so Put doesn't allocate
+	holders sync.Pool
+
+	gets, puts, news, dropped atomic.Int64
+
+	debug *tracker
+}
+
+// New creates pool with classes from MinSize to MaxSize,
+// both are rounded up to power of two
+func New(opts Options) *Pool {
+	if opts.MinSize <= 0 {
+		opts.MinSize = defaultMinSize
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = defaultMaxSize
+	}
+	opts.MaxSize = max(opts.MaxSize, opts.MinSize)
+
+	p := &Pool{
+		minShift: ceilLog2(opts.MinSize),
+		maxShift: ceilLog2(opts.MaxSize),
+	}
+	n := p.maxShift - p.minShift + 1
+	p.classes = make([]sync.Pool, n)
+	p.buffers = make([]sync.Pool, n)
+	if opts.Debug {
+		p.debug = newTracker()
+	}
+	return p
+}
+
+// ceilLog2 returns the smallest s with 1<<s >= n
+func ceilLog2(n int) int {
+	if n <= 1 {
+		return 0
+	}
+	return bits.Len(uint(n - 1))
+}
+
+// getClass returns index of the smallest class fitting {n} bytes,
+// false if {n} is larger than MaxSize
+func (p *Pool) getClass(n int) (int, bool) {
+	s := max(ceilLog2(n), p.minShift)
+	return s - p.minShift, s <= p.maxShift
+}
+
+// putClass returns index of the largest class,
+// which {capacity} satisfies, false if it shouldn't be retained
+func (p *Pool) putClass(capacity int) (int, bool) {
+	if capacity < 1<<p.minShift || capacity > 1<<p.maxShift {
+		return 0, false
+	}
+	return bits.Len(uint(capacity)) - 1 - p.minShift, true
+}
+
+// Get returns slice of length {n}, its content is arbitrary
+func (p *Pool) Get(n int) []byte {
+	p.gets.Add(1)
+	i, ok := p.getClass(n)
+	if !ok {
+		p.news.Add(1)
+		return p.track(make([]byte, n))
+	}
+	if h, _ := p.classes[i].Get().(*[]byte); h != nil {
+		buf := (*h)[:n]
+		*h = nil
+		p.holders.Put(h)
+		return p.track(buf)
+	}
+	p.news.Add(1)
+	return p.track(make([]byte, n, 1<<(p.minShift+i)))
+}
+
+// Put returns {buf} to the pool, it must not be used after that
+func (p *Pool) Put(buf []byte) {
+	p.puts.Add(1)
+	if p.debug != nil {
+		p.debug.put(buf)
+	}
+	i, ok := p.putClass(cap(buf))
+	if !ok {
+		p.dropped.Add(1)
+		return
+	}
+	h, _ := p.holders.Get().(*[]byte)
+	if h == nil {
+		h = new([]byte)
+	}
+	*h = buf[:0]
+	p.classes[i].Put(h)
+}
+
+// GetBuffer returns empty buffer with capacity at least {n}
+func (p *Pool) GetBuffer(n int) *bytes.Buffer {
+	p.gets.Add(1)
+	i, ok := p.getClass(n)
+	if ok {
+		if b, _ := p.buffers[i].Get().(*bytes.Buffer); b != nil {
+			return p.trackBuffer(b)
+		}
+		n = 1 << (p.minShift + i)
+	}
+	p.news.Add(1)
+	return p.trackBuffer(bytes.NewBuffer(make([]byte, 0, n)))
+}
+
+// PutBuffer resets {b} and returns it to the pool,
+// it must not be used after that
+func (p *Pool) PutBuffer(b *bytes.Buffer) {
+	p.puts.Add(1)
+	if p.debug != nil {
+		p.debug.putBuffer(b)
+	}
+	i, ok := p.putClass(b.Cap())
+	if !ok {
+		p.dropped.Add(1)
+		return
+	}
+	b.Reset()
+	p.buffers[i].Put(b)
+}
+
+func (p *Pool) Stats() Stats {
+	return Stats{
+		Gets:    p.gets.Load(),
+		Puts:    p.puts.Load(),
+		News:    p.news.Load(),
+		Dropped: p.dropped.Load(),
+	}
+}
+
+func (p *Pool) track(buf []byte) []byte {
+	if p.debug != nil {
+		p.debug.get(buf)
+	}
+	return buf
+}
+
+func (p *Pool) trackBuffer(b *bytes.Buffer) *bytes.Buffer {
+	if p.debug != nil {
+		p.debug.getBuffer(b)
+	}
+	return b
+}
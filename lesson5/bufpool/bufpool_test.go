@@ -0,0 +1,219 @@
+package bufpool
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClasses(t *testing.T) {
+	p := New(Options{MinSize: 100, MaxSize: 1000})
+	cases := []struct {
+		n, cap int
+	}{
+		{0, 128},
+		{1, 128},
+		{128, 128},
+		{129, 256},
+		{1000, 1024},
+		{1024, 1024},
+		// too large, allocated exactly
+		{1025, 1025},
+	}
+	for _, c := range cases {
+		buf := p.Get(c.n)
+		if len(buf) != c.n || cap(buf) != c.cap {
+			t.Errorf("Get(%d): len %d cap %d, expect cap %d", c.n, len(buf), cap(buf), c.cap)
+		}
+	}
+}
+
+func TestReuse(t *testing.T) {
+	p := New(Options{})
+	buf := p.Get(1000)
+	buf[0] = 42
+	p.Put(buf)
+
+	// sync.Pool may drop items any time, e.g. under race detector,
+	// so only check that reused buffer is from the right class
+	for i := 0; i < 10; i++ {
+		got := p.Get(600)
+		if len(got) != 600 || cap(got) != 1024 {
+			t.Fatalf("got len %d cap %d", len(got), cap(got))
+		}
+		p.Put(got)
+	}
+
+	// 1024 fits requests up to 1024, but not larger
+	p.Put(make([]byte, 0, 1500))
+	if got := p.Get(1500); cap(got) < 1500 {
+		t.Fatalf("got cap %d for 1500 bytes", cap(got))
+	}
+}
+
+func TestMaxSizeCap(t *testing.T) {
+	p := New(Options{MaxSize: 4096})
+	p.Put(make([]byte, 1<<20))
+	p.PutBuffer(bytes.NewBuffer(make([]byte, 0, 1<<20)))
+	p.Put(make([]byte, 10)) // below MinSize
+
+	if st := p.Stats(); st.Dropped != 3 || st.Puts != 3 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	p := New(Options{})
+	b := p.GetBuffer(100)
+	if b.Len() != 0 || b.Cap() < 100 {
+		t.Fatalf("got len %d cap %d", b.Len(), b.Cap())
+	}
+	b.WriteString("hello")
+	p.PutBuffer(b)
+
+	for i := 0; i < 10; i++ {
+		b := p.GetBuffer(128)
+		if b.Len() != 0 || b.Cap() < 128 {
+			t.Fatalf("reused buffer: len %d cap %d", b.Len(), b.Cap())
+		}
+		p.PutBuffer(b)
+	}
+	// large buffers are still served
+	if b := p.GetBuffer(1 << 20); b.Cap() < 1<<20 {
+		t.Fatalf("got cap %d", b.Cap())
+	}
+}
+
+func leakOne(p *Pool) []byte {
+	return p.Get(100)
+}
+
+func TestDebug(t *testing.T) {
+	p := New(Options{Debug: true})
+
+	var kept [][]byte
+	for i := 0; i < 2; i++ {
+		kept = append(kept, leakOne(p))
+	}
+	b := p.GetBuffer(10)
+	returned := p.Get(200)
+	p.Put(returned[:10]) // reslice is still the same buffer
+
+	leaks := p.Outstanding(0)
+	if len(leaks) != 3 {
+		t.Fatalf("got %d outstanding, expect 3", len(leaks))
+	}
+	if !strings.Contains(leaks[0].Stack, "leakOne") {
+		t.Errorf("stack doesn't point to caller:\n%s", leaks[0].Stack)
+	}
+	if got := p.Outstanding(time.Hour); len(got) != 0 {
+		t.Errorf("nothing is that old, got %d", len(got))
+	}
+
+	var sb strings.Builder
+	p.WriteLeaks(&sb, 0)
+	if !strings.Contains(sb.String(), "2 buffers, 256 bytes not returned") {
+		t.Errorf("unexpected report:\n%s", sb.String())
+	}
+
+	for _, buf := range kept {
+		p.Put(buf)
+	}
+	p.PutBuffer(b)
+	if got := p.Outstanding(0); len(got) != 0 {
+		t.Errorf("got %d outstanding after put", len(got))
+	}
+
+	p.Put(kept[0]) // twice
+	p.Put(make([]byte, 100))
+	if n := p.UnknownPuts(); n != 2 {
+		t.Errorf("got %d unknown puts, expect 2", n)
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	p := New(Options{Debug: true})
+	wg := sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				n := (g*1000 + i) % 5000
+				buf := p.Get(n)
+				for j := range buf {
+					buf[j] = byte(g)
+				}
+				for j := range buf {
+					if buf[j] != byte(g) {
+						t.Errorf("buffer is shared")
+						return
+					}
+				}
+				p.Put(buf)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if n := len(p.Outstanding(0)); n != 0 {
+		t.Errorf("%d outstanding", n)
+	}
+	if n := p.UnknownPuts(); n != 0 {
+		t.Errorf("%d unknown puts", n)
+	}
+}
+
+var sizes = []int{64, 1 << 10, 16 << 10, 64 << 10}
+
+var sink []byte
+
+func BenchmarkAlloc(b *testing.B) {
+	for _, size := range sizes {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					buf := make([]byte, size)
+					buf[0] = 1
+					sink = buf
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkPool(b *testing.B) {
+	for _, size := range sizes {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			p := New(Options{})
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					buf := p.Get(size)
+					buf[0] = 1
+					p.Put(buf)
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkPoolBuffer(b *testing.B) {
+	for _, size := range sizes {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			p := New(Options{})
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					buf := p.GetBuffer(size)
+					buf.WriteByte(1)
+					p.PutBuffer(buf)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package bufpool
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// Leak describes a buffer taken from the pool and not returned yet
+type Leak struct {
+	Cap   int
+	Since time.Time
+	// Stack is the call stack of Get
+	Stack string
+}
+
+// tracker remembers outstanding buffers in debug mode.
+// It keeps them reachable, so a leaked buffer isn't collected
+// and its address can't be taken by another one
+type tracker struct {
+	mx      sync.Mutex
+	slices  map[unsafe.Pointer]Leak
+	buffers map[*bytes.Buffer]Leak
+	// unknownPuts counts puts of buffers not taken from the pool
+	// or put twice
+	unknownPuts int
+}
+
+func newTracker() *tracker {
+	return &tracker{
+		slices:  map[unsafe.Pointer]Leak{},
+		buffers: map[*bytes.Buffer]Leak{},
+	}
+}
+
+func newLeak(capacity int) Leak {
+	// skip runtime.Callers, newLeak, tracker.get, Pool.track and Pool.Get
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(5, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var sb bytes.Buffer
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	return Leak{Cap: capacity, Since: time.Now(), Stack: sb.String()}
+}
+
+// key is address of the backing array, which is the same for
+// every reslice of the buffer given by Get
+func key(buf []byte) unsafe.Pointer {
+	return unsafe.Pointer(unsafe.SliceData(buf[:cap(buf)]))
+}
+
+func (t *tracker) get(buf []byte) {
+	if cap(buf) == 0 {
+		return
+	}
+	leak := newLeak(cap(buf))
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	t.slices[key(buf)] = leak
+}
+
+func (t *tracker) put(buf []byte) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	if cap(buf) == 0 {
+		return
+	}
+	k := key(buf)
+	if _, ok := t.slices[k]; !ok {
+		t.unknownPuts++
+		return
+	}
+	delete(t.slices, k)
+}
+
+func (t *tracker) getBuffer(b *bytes.Buffer) {
+	leak := newLeak(b.Cap())
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	t.buffers[b] = leak
+}
+
+func (t *tracker) putBuffer(b *bytes.Buffer) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	if _, ok := t.buffers[b]; !ok {
+		t.unknownPuts++
+		return
+	}
+	delete(t.buffers, b)
+}
+
+// Outstanding returns buffers taken from the pool more than {age} ago
+// and not returned, the oldest first. It returns nil without Debug option
+func (p *Pool) Outstanding(age time.Duration) []Leak {
+	if p.debug == nil {
+		return nil
+	}
+	t := p.debug
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	deadline := time.Now().Add(-age)
+	var res []Leak
+	for _, l := range t.slices {
+		if !l.Since.After(deadline) {
+			res = append(res, l)
+		}
+	}
+	for _, l := range t.buffers {
+		if !l.Since.After(deadline) {
+			res = append(res, l)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Since.Before(res[j].Since) })
+	return res
+}
+
+// UnknownPuts counts buffers put twice or not taken from the pool,
+// it is always 0 without Debug option
+func (p *Pool) UnknownPuts() int {
+	if p.debug == nil {
+		return 0
+	}
+	p.debug.mx.Lock()
+	defer p.debug.mx.Unlock()
+
+	return p.debug.unknownPuts
+}
+
+// WriteLeaks writes outstanding buffers older than {age} grouped by Get stack
+func (p *Pool) WriteLeaks(w io.Writer, age time.Duration) error {
+	type group struct {
+		stack string
+		count int
+		bytes int
+	}
+	var groups []*group
+	byStack := map[string]*group{}
+	for _, l := range p.Outstanding(age) {
+		g, ok := byStack[l.Stack]
+		if !ok {
+			g = &group{stack: l.Stack}
+			byStack[l.Stack] = g
+			groups = append(groups, g)
+		}
+		g.count++
+		g.bytes += l.Cap
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].bytes > groups[j].bytes })
+
+	for _, g := range groups {
+		if _, err := fmt.Fprintf(w, "%d buffers, %d bytes not returned, taken at:\n%s\n", g.count, g.bytes, g.stack); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+// Package floatmap contains a few
+// Set datastucture implementations
+// using different sync primitives
+package floatmap
+
+import (
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"sync/atomic"
+)
+
+// BloomFilter is a probabilistic set: Has never misses added elements,
+// but may report an element, which wasn't added. It is lock-free
+// and takes a few bits per element regardless of element size
+type BloomFilter[T comparable] struct {
+	bits []atomic.Uint64
+	m    uint64 // bits count
+	k    int    // hashes count
+	seed maphash.Seed
+	adds atomic.Int64 // Add calls
+}
+
+// bloomParams returns bits and hashes count for {expected}
+// elements and false positive rate {fpRate}
+func bloomParams(expected int, fpRate float64) (m uint64, k int) {
+	if expected < 1 {
+		expected = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.01
+	}
+	n := float64(expected)
+	bits := math.Ceil(-n * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	k = int(math.Round(bits / n * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return uint64(bits), k
+}
+
+// NewBloomFilter creates filter for {expected} elements with
+// false positive rate {fpRate}, which grows if more elements are added
+func NewBloomFilter[T comparable](expected int, fpRate float64) *BloomFilter[T] {
+	m, k := bloomParams(expected, fpRate)
+	return &BloomFilter[T]{
+		bits: make([]atomic.Uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+		seed: maphash.MakeSeed(),
+	}
+}
+
+// positions calls {f} for each of k bit positions of {elem},
+// they are derived from one hash by double hashing
+func positions[T comparable](seed maphash.Seed, elem T, k int, m uint64, f func(pos uint64) bool) {
+	h1 := hashOf(seed, elem)
+	h2 := mix(h1^0x9e3779b97f4a7c15) | 1
+	for i := 0; i < k; i++ {
+		if !f((h1 + uint64(i)*h2) % m) {
+			return
+		}
+	}
+}
+
+func (bf *BloomFilter[T]) Add(elem T) {
+	positions(bf.seed, elem, bf.k, bf.m, func(pos uint64) bool {
+		word, mask := &bf.bits[pos/64], uint64(1)<<(pos%64)
+		for {
+			old := word.Load()
+			if old&mask != 0 || word.CompareAndSwap(old, old|mask) {
+				return true
+			}
+		}
+	})
+	bf.adds.Add(1)
+}
+
+// Has reports if {elem} may be in the set
+func (bf *BloomFilter[T]) Has(elem T) bool {
+	found := true
+	positions(bf.seed, elem, bf.k, bf.m, func(pos uint64) bool {
+		found = bf.bits[pos/64].Load()&(1<<(pos%64)) != 0
+		return found
+	})
+	return found
+}
+
+// Adds returns count of Add calls, including duplicates
+func (bf *BloomFilter[T]) Adds() int {
+	return int(bf.adds.Load())
+}
+
+// FPRate estimates current false positive rate by share of set bits
+func (bf *BloomFilter[T]) FPRate() float64 {
+	set := 0
+	for i := range bf.bits {
+		set += bits.OnesCount64(bf.bits[i].Load())
+	}
+	return math.Pow(float64(set)/float64(bf.m), float64(bf.k))
+}
@@ -0,0 +1,163 @@
+package floatmap
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// setImpls lists all Set implementations,
+// each of them is checked by TestSetConformance
+var setImpls = []struct {
+	name   string
+	newSet func(size int) Set[float32]
+}{
+	{"MutexSet", func(size int) Set[float32] { return NewMutexSet[float32](size) }},
+	{"RWMutexSet", func(size int) Set[float32] { return NewRWMutexSet[float32](size) }},
+	{"SyncMapSet", func(size int) Set[float32] { return NewSyncMapSet[float32](size) }},
+	{"ShardedSet", func(size int) Set[float32] { return NewShardedSet[float32](size, 0) }},
+	{"ShardedSet/1", func(size int) Set[float32] { return NewShardedSet[float32](size, 1) }},
+}
+
+func TestSetConformance(t *testing.T) {
+	for _, impl := range setImpls {
+		impl := impl
+		t.Run(impl.name, func(t *testing.T) {
+			conformanceTests(t, impl.newSet)
+		})
+	}
+}
+
+func conformanceTests(t *testing.T, newSet func(size int) Set[float32]) {
+	t.Run("base", func(t *testing.T) {
+		baseSetTests(t, newSet(setSize))
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		set := newSet(setSize)
+		set.AddAll(1, 2, 3)
+		set.Remove(2)
+		set.Remove(42) // missing value is ignored
+		if set.Has(2) {
+			t.Errorf("removed value is still in set")
+		}
+		if got := set.Len(); got != 2 {
+			t.Errorf("len after remove: got %d, expect 2", got)
+		}
+	})
+
+	t.Run("len", func(t *testing.T) {
+		set := newSet(setSize)
+		if got := set.Len(); got != 0 {
+			t.Errorf("len of empty set: got %d, expect 0", got)
+		}
+		set.Add(1)
+		set.Add(1)
+		set.AddAll(1, 2, 2, 3)
+		if got := set.Len(); got != 3 {
+			t.Errorf("len with duplicates: got %d, expect 3", got)
+		}
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		set := newSet(setSize)
+		set.AddAll(1, 2, 3)
+		set.Clear()
+		if got := set.Len(); got != 0 {
+			t.Errorf("len after clear: got %d, expect 0", got)
+		}
+		if set.Has(1) {
+			t.Errorf("value is still in set after clear")
+		}
+		// set is usable after clear
+		set.Add(4)
+		if !set.Has(4) || set.Len() != 1 {
+			t.Errorf("add after clear failed")
+		}
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		set := newSet(setSize)
+		set.AddAll(3, 1, 2)
+		got := set.Snapshot()
+		sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+		if fmt.Sprint(got) != "[1 2 3]" {
+			t.Errorf("snapshot: got %v, expect [1 2 3]", got)
+		}
+		// snapshot is a copy
+		set.Add(4)
+		if len(got) != 3 {
+			t.Errorf("snapshot changed after add")
+		}
+	})
+
+	t.Run("range", func(t *testing.T) {
+		set := newSet(setSize)
+		set.AddAll(1, 2, 3)
+		seen := map[float32]int{}
+		set.Range(func(elem float32) bool {
+			seen[elem]++
+			return true
+		})
+		if len(seen) != 3 || seen[1] != 1 || seen[2] != 1 || seen[3] != 1 {
+			t.Errorf("range visited %v, expect each of 1 2 3 once", seen)
+		}
+
+		calls := 0
+		set.Range(func(float32) bool {
+			calls++
+			return false
+		})
+		if calls != 1 {
+			t.Errorf("range didn't stop: %d calls", calls)
+		}
+	})
+
+	t.Run("range modify", func(t *testing.T) {
+		set := newSet(setSize)
+		set.AddAll(1, 2, 3)
+		// must not deadlock
+		set.Range(func(elem float32) bool {
+			set.Remove(elem)
+			return true
+		})
+		if got := set.Len(); got != 0 {
+			t.Errorf("len after removing in range: got %d, expect 0", got)
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		const workers, values = 8, 100
+		set := newSet(setSize)
+
+		wg := sync.WaitGroup{}
+		wg.Add(workers)
+		for w := 0; w < workers; w++ {
+			go func() {
+				defer wg.Done()
+				for i := 0; i < values; i++ {
+					set.Add(float32(i))
+					set.Has(float32(i))
+					if i%2 == 1 {
+						set.Remove(float32(i))
+					}
+					set.Len()
+				}
+				set.Snapshot()
+			}()
+		}
+		wg.Wait()
+
+		// odd values may survive, if another worker added them
+		// after the last remove, but even ones are never removed
+		for i := 0; i < values; i += 2 {
+			if !set.Has(float32(i)) {
+				t.Errorf("even value %d is lost", i)
+			}
+		}
+		if got, snap := set.Len(), len(set.Snapshot()); got != snap {
+			t.Errorf("len %d doesn't match snapshot len %d", got, snap)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+// Package floatmap contains a few
+// Set datastucture implementations
+// using different sync primitives
+package floatmap
+
+import (
+	"hash/maphash"
+	"math"
+	"sync/atomic"
+)
+
+// counterMax is the saturation value of CountingBloomFilter
+// counters, saturated counter is never decremented
+const counterMax = math.MaxUint8
+
+// CountingBloomFilter is BloomFilter with 8-bit counters instead of bits,
+// so it supports Remove. Removing an element, which wasn't added,
+// may remove others, so Remove only elements known to be added
+type CountingBloomFilter[T comparable] struct {
+	// 4 counters per word, updated by CAS
+	counters []atomic.Uint32
+	m        uint64
+	k        int
+	seed     maphash.Seed
+}
+
+func NewCountingBloomFilter[T comparable](expected int, fpRate float64) *CountingBloomFilter[T] {
+	m, k := bloomParams(expected, fpRate)
+	return &CountingBloomFilter[T]{
+		counters: make([]atomic.Uint32, (m+3)/4),
+		m:        m,
+		k:        k,
+		seed:     maphash.MakeSeed(),
+	}
+}
+
+// update changes counter at {pos} by {delta} if {ok} allows its value
+func (cf *CountingBloomFilter[T]) update(pos uint64, delta int, ok func(v uint32) bool) {
+	word, shift := &cf.counters[pos/4], (pos%4)*8
+	for {
+		old := word.Load()
+		v := old >> shift & 0xff
+		if !ok(v) {
+			return
+		}
+		next := old&^(0xff<<shift) | uint32(int(v)+delta)<<shift
+		if word.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+func (cf *CountingBloomFilter[T]) counter(pos uint64) uint32 {
+	return cf.counters[pos/4].Load() >> ((pos % 4) * 8) & 0xff
+}
+
+func (cf *CountingBloomFilter[T]) Add(elem T) {
+	positions(cf.seed, elem, cf.k, cf.m, func(pos uint64) bool {
+		cf.update(pos, 1, func(v uint32) bool { return v < counterMax })
+		return true
+	})
+}
+
+// Remove decrements counters of {elem}, if it may be in the set
+func (cf *CountingBloomFilter[T]) Remove(elem T) {
+	if !cf.Has(elem) {
+		return
+	}
+	positions(cf.seed, elem, cf.k, cf.m, func(pos uint64) bool {
+		cf.update(pos, -1, func(v uint32) bool { return v > 0 && v < counterMax })
+		return true
+	})
+}
+
+// Has reports if {elem} may be in the set
+func (cf *CountingBloomFilter[T]) Has(elem T) bool {
+	found := true
+	positions(cf.seed, elem, cf.k, cf.m, func(pos uint64) bool {
+		found = cf.counter(pos) > 0
+		return found
+	})
+	return found
+}
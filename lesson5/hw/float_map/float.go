@@ -0,0 +1,163 @@
+// Package floatmap contains a few
+// Set datastucture implementations
+// using different sync primitives
+package floatmap
+
+import (
+	"errors"
+	"math"
+	"unsafe"
+)
+
+// ErrNaN is returned on adding NaN to a set with NaNReject policy
+var ErrNaN = errors.New("floatmap: NaN is not allowed")
+
+type Float interface {
+	~float32 | ~float64
+}
+
+// NaNPolicy defines how a float set stores NaN.
+// Plain map[float]struct{} never finds NaN, as NaN != NaN,
+// so every added NaN becomes a new unreachable member
+type NaNPolicy int
+
+const (
+	// NaNCanonical treats all NaNs as one member
+	NaNCanonical NaNPolicy = iota
+	// NaNReject doesn't store NaN, Add returns ErrNaN
+	NaNReject
+)
+
+// FloatOptions are float semantics of FloatSet and SortedFloatSet,
+// zero value means single NaN member and +0 == -0 like in IEEE 754
+type FloatOptions struct {
+	NaN NaNPolicy
+	// DistinctZeros makes +0 and -0 different members
+	DistinctZeros bool
+}
+
+// canonical returns member for {x}, it is false for rejected NaN
+func (o FloatOptions) canonical(x float64) (float64, bool) {
+	switch {
+	case x != x:
+		return math.NaN(), o.NaN != NaNReject
+	case x == 0 && !o.DistinctZeros:
+		return 0, true
+	}
+	return x, true
+}
+
+// key maps {x} to uint64 so that keys order is the float order,
+// -0 < +0 and NaN is above +Inf. Distance between keys
+// of neighbour floats of type F is 1, so it counts ULPs of F
+func key[F Float](x F) uint64 {
+	if isFloat32[F]() {
+		b := math.Float32bits(float32(x))
+		if b>>31 == 1 {
+			return uint64(^b)
+		}
+		return uint64(b | 1<<31)
+	}
+	b := math.Float64bits(float64(x))
+	if b>>63 == 1 {
+		return ^b
+	}
+	return b | 1<<63
+}
+
+// fromKey is the inverse of key
+func fromKey[F Float](k uint64) F {
+	if isFloat32[F]() {
+		b := uint32(k)
+		if b>>31 == 1 {
+			return F(math.Float32frombits(b &^ (1 << 31)))
+		}
+		return F(math.Float32frombits(^b))
+	}
+	if k>>63 == 1 {
+		return F(math.Float64frombits(k &^ (1 << 63)))
+	}
+	return F(math.Float64frombits(^k))
+}
+
+// isFloat32 reports if F is 32-bit, it works for named types too
+func isFloat32[F Float]() bool {
+	var x F
+	return unsafe.Sizeof(x) == 4
+}
+
+// FloatSet is a set of floats with explicit NaN and ±0 semantics
+// on top of any Set implementation, which stores float keys
+type FloatSet[F Float] struct {
+	opts FloatOptions
+	keys Set[uint64]
+}
+
+// NewFloatSet creates float set stored in {keys}, e.g.
+//
+//	NewFloatSet[float32](NewShardedSet[uint64](size, 0), FloatOptions{})
+func NewFloatSet[F Float](keys Set[uint64], opts FloatOptions) *FloatSet[F] {
+	return &FloatSet[F]{opts: opts, keys: keys}
+}
+
+func (fs *FloatSet[F]) key(elem F) (uint64, bool) {
+	x, ok := fs.opts.canonical(float64(elem))
+	return key(F(x)), ok
+}
+
+func (fs *FloatSet[F]) Add(elem F) error {
+	k, ok := fs.key(elem)
+	if !ok {
+		return ErrNaN
+	}
+	fs.keys.Add(k)
+	return nil
+}
+
+// AddAll adds nothing if any of {elems} is rejected
+func (fs *FloatSet[F]) AddAll(elems ...F) error {
+	keys := make([]uint64, len(elems))
+	for i, elem := range elems {
+		k, ok := fs.key(elem)
+		if !ok {
+			return ErrNaN
+		}
+		keys[i] = k
+	}
+	fs.keys.AddAll(keys...)
+	return nil
+}
+
+func (fs *FloatSet[F]) Has(elem F) bool {
+	k, ok := fs.key(elem)
+	return ok && fs.keys.Has(k)
+}
+
+func (fs *FloatSet[F]) Remove(elem F) {
+	if k, ok := fs.key(elem); ok {
+		fs.keys.Remove(k)
+	}
+}
+
+func (fs *FloatSet[F]) Len() int {
+	return fs.keys.Len()
+}
+
+func (fs *FloatSet[F]) Range(f func(elem F) bool) {
+	fs.keys.Range(func(k uint64) bool {
+		return f(fromKey[F](k))
+	})
+}
+
+func (fs *FloatSet[F]) Clear() {
+	fs.keys.Clear()
+}
+
+func (fs *FloatSet[F]) Snapshot() []F {
+	keys := fs.keys.Snapshot()
+	values := make([]F, len(keys))
+	for i, k := range keys {
+		values[i] = fromKey[F](k)
+	}
+	return values
+}
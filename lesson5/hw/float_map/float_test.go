@@ -0,0 +1,201 @@
+package floatmap
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+var (
+	nan32     = float32(math.NaN())
+	negZero32 = float32(math.Copysign(0, -1))
+)
+
+type FloatSetIface interface {
+	Add(float32) error
+	Has(float32) bool
+	Remove(float32)
+	Len() int
+	Snapshot() []float32
+}
+
+// floatSetImpls are float sets with given options over every backend
+func floatSetImpls(opts FloatOptions) map[string]FloatSetIface {
+	return map[string]FloatSetIface{
+		"MutexSet":       NewFloatSet[float32](NewMutexSet[uint64](setSize), opts),
+		"RWMutexSet":     NewFloatSet[float32](NewRWMutexSet[uint64](setSize), opts),
+		"SyncMapSet":     NewFloatSet[float32](NewSyncMapSet[uint64](setSize), opts),
+		"ShardedSet":     NewFloatSet[float32](NewShardedSet[uint64](setSize, 0), opts),
+		"SortedFloatSet": NewSortedFloatSet[float32](setSize, opts, Tolerance{}),
+	}
+}
+
+func TestPlainMapNaN(t *testing.T) {
+	// the reason for FloatSet
+	set := NewMutexSet[float32](setSize)
+	set.Add(nan32)
+	set.Add(nan32)
+	if set.Has(nan32) || set.Len() != 2 {
+		t.Errorf("plain map is expected to lose NaNs")
+	}
+}
+
+func TestFloatSetNaNCanonical(t *testing.T) {
+	for name, set := range floatSetImpls(FloatOptions{}) {
+		// NaN with another payload
+		otherNaN := math.Float32frombits(0x7fc00001)
+		if err := set.Add(nan32); err != nil {
+			t.Errorf("%s: add NaN: %v", name, err)
+		}
+		set.Add(otherNaN)
+		if !set.Has(nan32) || !set.Has(otherNaN) {
+			t.Errorf("%s: NaN is not found", name)
+		}
+		if got := set.Len(); got != 1 {
+			t.Errorf("%s: NaNs should be one member, got len %d", name, got)
+		}
+		set.Remove(otherNaN)
+		if set.Len() != 0 {
+			t.Errorf("%s: NaN is not removed", name)
+		}
+	}
+}
+
+func TestFloatSetNaNReject(t *testing.T) {
+	for name, set := range floatSetImpls(FloatOptions{NaN: NaNReject}) {
+		if err := set.Add(nan32); !errors.Is(err, ErrNaN) {
+			t.Errorf("%s: add NaN: got %v, expect ErrNaN", name, err)
+		}
+		if set.Has(nan32) || set.Len() != 0 {
+			t.Errorf("%s: rejected NaN is stored", name)
+		}
+	}
+
+	set := NewFloatSet[float64](NewMutexSet[uint64](setSize), FloatOptions{NaN: NaNReject})
+	if err := set.AddAll(1, math.NaN(), 2); !errors.Is(err, ErrNaN) {
+		t.Errorf("add all with NaN: got %v, expect ErrNaN", err)
+	}
+	if set.Len() != 0 {
+		t.Errorf("add all with NaN should add nothing")
+	}
+}
+
+func TestFloatSetZeros(t *testing.T) {
+	for name, set := range floatSetImpls(FloatOptions{}) {
+		set.Add(negZero32)
+		if !set.Has(0) || set.Len() != 1 {
+			t.Errorf("%s: -0 should be equal to +0", name)
+		}
+		if got := set.Snapshot()[0]; math.Signbit(float64(got)) {
+			t.Errorf("%s: zero should be stored as +0", name)
+		}
+	}
+
+	for name, set := range floatSetImpls(FloatOptions{DistinctZeros: true}) {
+		set.Add(negZero32)
+		if set.Has(0) {
+			t.Errorf("%s: -0 should differ from +0", name)
+		}
+		set.Add(0)
+		if !set.Has(negZero32) || set.Len() != 2 {
+			t.Errorf("%s: both zeros should be members", name)
+		}
+	}
+}
+
+func TestFloatSetValues(t *testing.T) {
+	values := []float64{
+		math.Inf(-1), -math.MaxFloat64, -1, -math.SmallestNonzeroFloat64,
+		0, math.SmallestNonzeroFloat64, 1, math.MaxFloat64, math.Inf(1),
+	}
+	set := NewSortedFloatSet[float64](setSize, FloatOptions{}, Tolerance{})
+	// reversed, to check sorting
+	for i := len(values) - 1; i >= 0; i-- {
+		set.Add(values[i])
+	}
+	if got := set.Snapshot(); fmt.Sprint(got) != fmt.Sprint(values) {
+		t.Errorf("sorted values: got %v, expect %v", got, values)
+	}
+}
+
+func TestSortedFloatSetULP(t *testing.T) {
+	set := NewSortedFloatSet[float32](setSize, FloatOptions{}, Tolerance{ULP: 2})
+	set.Add(1)
+
+	next := float32(1)
+	for i := 1; i <= 3; i++ {
+		next = math.Nextafter32(next, 2)
+		expect := i <= 2
+		if got := set.Has(next); got != expect {
+			t.Errorf("1 + %d ULP: got %v, expect %v", i, got, expect)
+		}
+	}
+	if !set.Has(math.Nextafter32(1, 0)) {
+		t.Errorf("1 - 1 ULP should be found")
+	}
+
+	// ULPs are counted across zero
+	set.Add(0)
+	if !set.Has(-math.SmallestNonzeroFloat32) {
+		t.Errorf("smallest negative should be close to zero")
+	}
+}
+
+func TestSortedFloatSetAbs(t *testing.T) {
+	set := NewSortedFloatSet[float64](setSize, FloatOptions{}, Tolerance{Abs: 0.01})
+	set.AddAll(1, 2, math.NaN())
+
+	cases := []struct {
+		value  float64
+		expect bool
+	}{
+		{1.005, true},
+		{0.995, true},
+		{1.02, false},
+		{1.995, true},
+		{1.5, false},
+		{math.Inf(1), false},
+		{math.NaN(), true},
+	}
+	for _, c := range cases {
+		if got := set.Has(c.value); got != c.expect {
+			t.Errorf("has %v: got %v, expect %v", c.value, got, c.expect)
+		}
+	}
+
+	if got, _ := set.Nearest(1.7); got != 2 {
+		t.Errorf("nearest to 1.7: got %v, expect 2", got)
+	}
+	if got, _ := set.Nearest(-5); got != 1 {
+		t.Errorf("nearest to -5: got %v, expect 1", got)
+	}
+}
+
+func TestSortedFloatSetBetween(t *testing.T) {
+	set := NewSortedFloatSet[float32](setSize, FloatOptions{}, Tolerance{})
+	set.AddAll(5, -1, 3, 0, 10, nan32)
+
+	cases := []struct {
+		lo, hi float32
+		expect string
+	}{
+		{0, 5, "[0 3 5]"},
+		{negZero32, negZero32, "[0]"},
+		{-100, 100, "[-1 0 3 5 10]"},
+		{4, 4, "[]"},
+		{5, 0, "[]"},
+		{float32(math.Inf(1)), nan32, "[NaN]"},
+	}
+	for _, c := range cases {
+		got := set.Between(c.lo, c.hi)
+		if fmt.Sprint(got) != c.expect {
+			t.Errorf("between %v and %v: got %v, expect %v", c.lo, c.hi, got, c.expect)
+		}
+	}
+
+	set.Remove(3)
+	if got := set.Between(0, 5); fmt.Sprint(got) != "[0 5]" {
+		t.Errorf("between after remove: got %v", got)
+	}
+}
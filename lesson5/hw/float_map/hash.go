@@ -0,0 +1,115 @@
+// Package floatmap contains a few
+// Set datastucture implementations
+// using different sync primitives
+package floatmap
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+	"math"
+	"reflect"
+)
+
+// hashOf returns seeded hash of {elem}. Equal elements get equal
+// hashes, so +0 and -0 floats are hashed the same way, also
+// inside structs and arrays. NaN payloads are ignored
+func hashOf[T comparable](seed maphash.Seed, elem T) uint64 {
+	var x uint64
+	switch v := any(elem).(type) {
+	case float32:
+		x = floatBits(float64(v))
+	case float64:
+		x = floatBits(v)
+	case int:
+		x = uint64(v)
+	case int32:
+		x = uint64(v)
+	case int64:
+		x = uint64(v)
+	case uint:
+		x = uint64(v)
+	case uint32:
+		x = uint64(v)
+	case uint64:
+		x = v
+	case string:
+		return maphash.String(seed, v)
+	default:
+		// slow, but fine for rare key types
+		var h maphash.Hash
+		h.SetSeed(seed)
+		writeValue(&h, reflect.ValueOf(elem))
+		return h.Sum64()
+	}
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], x)
+	return maphash.Bytes(seed, buf[:])
+}
+
+// floatBits returns the same bits for floats which are equal
+func floatBits(f float64) uint64 {
+	switch {
+	case f == 0:
+		f = 0
+	case f != f:
+		f = math.NaN()
+	}
+	return math.Float64bits(f)
+}
+
+// writeValue writes {v} to {h} so that values equal
+// by == are written the same way
+func writeValue(h *maphash.Hash, v reflect.Value) {
+	var buf [8]byte
+	writeUint := func(x uint64) {
+		binary.LittleEndian.PutUint64(buf[:], x)
+		h.Write(buf[:])
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			writeUint(1)
+		} else {
+			writeUint(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeUint(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeUint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		writeUint(floatBits(v.Float()))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		writeUint(floatBits(real(c)))
+		writeUint(floatBits(imag(c)))
+	case reflect.String:
+		writeUint(uint64(v.Len()))
+		h.WriteString(v.String())
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			writeValue(h, v.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			writeValue(h, v.Field(i))
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			writeUint(0)
+		} else {
+			writeValue(h, v.Elem())
+		}
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		writeUint(uint64(v.Pointer()))
+	}
+}
+
+// mix is splitmix64 finalizer, it derives more hashes from one
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,42 @@
+package floatmap
+
+import (
+	"hash/maphash"
+	"math"
+	"testing"
+)
+
+func TestHashSeed(t *testing.T) {
+	seed1, seed2 := maphash.MakeSeed(), maphash.MakeSeed()
+	type pair struct{ A, B int }
+	for _, h := range [][2]uint64{
+		{hashOf(seed1, 42), hashOf(seed2, 42)},
+		{hashOf(seed1, 4.2), hashOf(seed2, 4.2)},
+		{hashOf(seed1, pair{4, 2}), hashOf(seed2, pair{4, 2})},
+	} {
+		if h[0] == h[1] {
+			t.Errorf("hash doesn't depend on seed: %x", h[0])
+		}
+	}
+	if hashOf(seed1, pair{4, 2}) == hashOf(seed1, pair{2, 4}) {
+		t.Errorf("struct fields aren't hashed in order")
+	}
+}
+
+func TestHashSignedZero(t *testing.T) {
+	seed := maphash.MakeSeed()
+	negZero := math.Copysign(0, -1)
+
+	// +0 and -0 are equal map keys, so they must get the same hash
+	if hashOf(seed, negZero) != hashOf(seed, 0.0) {
+		t.Errorf("-0 and +0 are hashed differently")
+	}
+
+	type point struct {
+		X, Y float64
+		Name string
+	}
+	if hashOf(seed, point{X: negZero, Y: 1, Name: "a"}) != hashOf(seed, point{X: 0, Y: 1, Name: "a"}) {
+		t.Errorf("-0 and +0 fields are hashed differently")
+	}
+}
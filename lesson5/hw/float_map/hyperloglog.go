@@ -0,0 +1,114 @@
+// Package floatmap contains a few
+// Set datastucture implementations
+// using different sync primitives
+package floatmap
+
+import (
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"sync/atomic"
+)
+
+// HyperLogLog estimates count of distinct elements in fixed memory.
+// It can't answer Has, so it shares only Add with the sets.
+// Standard error is 1.04/sqrt(2^precision)
+type HyperLogLog[T comparable] struct {
+	// 4 registers per word, updated by CAS
+	registers []atomic.Uint32
+	p         uint8
+	seed      maphash.Seed
+}
+
+// NewHyperLogLog creates estimator with 2^{precision} registers,
+// precision is clamped to [4, 18]. Estimators are mergeable
+// only if they share seed, see NewHyperLogLogLike
+func NewHyperLogLog[T comparable](precision uint8) *HyperLogLog[T] {
+	if precision < 4 {
+		precision = 4
+	}
+	if precision > 18 {
+		precision = 18
+	}
+	return &HyperLogLog[T]{
+		registers: make([]atomic.Uint32, (1<<precision)/4),
+		p:         precision,
+		seed:      maphash.MakeSeed(),
+	}
+}
+
+// NewHyperLogLogLike creates empty estimator, which can be merged with {h}
+func NewHyperLogLogLike[T comparable](h *HyperLogLog[T]) *HyperLogLog[T] {
+	res := NewHyperLogLog[T](h.p)
+	res.seed = h.seed
+	return res
+}
+
+func (h *HyperLogLog[T]) register(i uint64) uint32 {
+	return h.registers[i/4].Load() >> ((i % 4) * 8) & 0xff
+}
+
+// raise sets register {i} to {v} if it is lower
+func (h *HyperLogLog[T]) raise(i uint64, v uint32) {
+	word, shift := &h.registers[i/4], (i%4)*8
+	for {
+		old := word.Load()
+		if old>>shift&0xff >= v {
+			return
+		}
+		if word.CompareAndSwap(old, old&^(0xff<<shift)|v<<shift) {
+			return
+		}
+	}
+}
+
+func (h *HyperLogLog[T]) Add(elem T) {
+	x := hashOf(h.seed, elem)
+	// high bits select register, the rest gives rank
+	i := x >> (64 - h.p)
+	rank := bits.LeadingZeros64(x<<h.p|1<<(h.p-1)) + 1
+	h.raise(i, uint32(rank))
+}
+
+// Count returns estimated count of distinct added elements
+func (h *HyperLogLog[T]) Count() uint64 {
+	m := float64(uint64(1) << h.p)
+	sum, zeros := 0.0, 0
+	for i := uint64(0); i < 1<<h.p; i++ {
+		r := h.register(i)
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := hllAlpha(m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// linear counting is more precise for small counts
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+func hllAlpha(m float64) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	}
+	return 0.7213 / (1 + 1.079/m)
+}
+
+// Merge adds elements counted by {other} to {h}, it returns false
+// if estimators are incompatible: differ in precision or seed
+func (h *HyperLogLog[T]) Merge(other *HyperLogLog[T]) bool {
+	if h.p != other.p || h.seed != other.seed {
+		return false
+	}
+	for i := uint64(0); i < 1<<h.p; i++ {
+		h.raise(i, other.register(i))
+	}
+	return true
+}
@@ -5,27 +5,77 @@ package floatmap
 
 import "sync"
 
-type MutexSet struct {
+type MutexSet[T comparable] struct {
 	mx   sync.Mutex
-	data map[float32]struct{}
+	data map[T]struct{}
 }
 
-func NewMutexSet(size int) *MutexSet {
-	data := make(map[float32]struct{}, size)
-	return &MutexSet{data: data}
+func NewMutexSet[T comparable](size int) *MutexSet[T] {
+	data := make(map[T]struct{}, size)
+	return &MutexSet[T]{data: data}
 }
 
-func (ms *MutexSet) Add(elem float32) {
+func (ms *MutexSet[T]) Add(elem T) {
 	ms.mx.Lock()
 	defer ms.mx.Unlock()
 
 	ms.data[elem] = struct{}{}
 }
 
-func (ms *MutexSet) Has(elem float32) bool {
+func (ms *MutexSet[T]) AddAll(elems ...T) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	for _, elem := range elems {
+		ms.data[elem] = struct{}{}
+	}
+}
+
+func (ms *MutexSet[T]) Has(elem T) bool {
 	ms.mx.Lock()
 	defer ms.mx.Unlock()
 
 	_, ok := ms.data[elem]
 	return ok
 }
+
+func (ms *MutexSet[T]) Remove(elem T) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	delete(ms.data, elem)
+}
+
+func (ms *MutexSet[T]) Len() int {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	return len(ms.data)
+}
+
+// Range iterates over snapshot, so {f} is called without lock
+func (ms *MutexSet[T]) Range(f func(elem T) bool) {
+	for _, elem := range ms.Snapshot() {
+		if !f(elem) {
+			return
+		}
+	}
+}
+
+func (ms *MutexSet[T]) Clear() {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	ms.data = make(map[T]struct{})
+}
+
+func (ms *MutexSet[T]) Snapshot() []T {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	values := make([]T, 0, len(ms.data))
+	for val := range ms.data {
+		values = append(values, val)
+	}
+	return values
+}
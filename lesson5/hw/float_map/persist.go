@@ -0,0 +1,270 @@
+// Package floatmap contains a few
+// Set datastucture implementations
+// using different sync primitives
+package floatmap
+
+import (
+	"errors"
+	"io"
+
+	"geekbrains/examples/internal/setcodec"
+)
+
+// Sets are saved in setcodec format, with a versioned header and checksum.
+// Elements should be float32, float64, int, int64 or uint64.
+// Unmarshal and ReadFrom build new elements first and replace
+// old ones at once, so concurrent readers see either of them.
+// On error the set is left unchanged
+
+// errNoStripes is returned on loading into ShardedSet
+// which wasn't created by NewShardedSet
+var errNoStripes = errors.New("floatmap: ShardedSet should be created by NewShardedSet")
+
+// replacer is a set which replaces all its elements at once
+type replacer[T any] interface {
+	replace(values []T) error
+}
+
+func (ms *MutexSet[T]) MarshalBinary() ([]byte, error) {
+	return setcodec.Marshal(ms.Snapshot())
+}
+
+func (ms *MutexSet[T]) UnmarshalBinary(data []byte) error {
+	return setcodec.UnmarshalTo(data, ms.replace)
+}
+
+func (ms *MutexSet[T]) MarshalJSON() ([]byte, error) {
+	return setcodec.MarshalJSON(ms.Snapshot())
+}
+
+func (ms *MutexSet[T]) UnmarshalJSON(data []byte) error {
+	return setcodec.UnmarshalJSONTo(data, ms.replace)
+}
+
+func (ms *MutexSet[T]) WriteTo(w io.Writer) (int64, error) {
+	return setcodec.Write(w, ms.Snapshot())
+}
+
+func (ms *MutexSet[T]) ReadFrom(r io.Reader) (int64, error) {
+	return setcodec.ReadTo(r, ms.replace)
+}
+
+func (ms *RWMutexSet[T]) MarshalBinary() ([]byte, error) {
+	return setcodec.Marshal(ms.Snapshot())
+}
+
+func (ms *RWMutexSet[T]) UnmarshalBinary(data []byte) error {
+	return setcodec.UnmarshalTo(data, ms.replace)
+}
+
+func (ms *RWMutexSet[T]) MarshalJSON() ([]byte, error) {
+	return setcodec.MarshalJSON(ms.Snapshot())
+}
+
+func (ms *RWMutexSet[T]) UnmarshalJSON(data []byte) error {
+	return setcodec.UnmarshalJSONTo(data, ms.replace)
+}
+
+func (ms *RWMutexSet[T]) WriteTo(w io.Writer) (int64, error) {
+	return setcodec.Write(w, ms.Snapshot())
+}
+
+func (ms *RWMutexSet[T]) ReadFrom(r io.Reader) (int64, error) {
+	return setcodec.ReadTo(r, ms.replace)
+}
+
+func (ms *SyncMapSet[T]) MarshalBinary() ([]byte, error) {
+	return setcodec.Marshal(ms.Snapshot())
+}
+
+func (ms *SyncMapSet[T]) UnmarshalBinary(data []byte) error {
+	return setcodec.UnmarshalTo(data, ms.replace)
+}
+
+func (ms *SyncMapSet[T]) MarshalJSON() ([]byte, error) {
+	return setcodec.MarshalJSON(ms.Snapshot())
+}
+
+func (ms *SyncMapSet[T]) UnmarshalJSON(data []byte) error {
+	return setcodec.UnmarshalJSONTo(data, ms.replace)
+}
+
+func (ms *SyncMapSet[T]) WriteTo(w io.Writer) (int64, error) {
+	return setcodec.Write(w, ms.Snapshot())
+}
+
+func (ms *SyncMapSet[T]) ReadFrom(r io.Reader) (int64, error) {
+	return setcodec.ReadTo(r, ms.replace)
+}
+
+func (ss *ShardedSet[T]) MarshalBinary() ([]byte, error) {
+	return setcodec.Marshal(ss.Snapshot())
+}
+
+func (ss *ShardedSet[T]) UnmarshalBinary(data []byte) error {
+	return setcodec.UnmarshalTo(data, ss.replace)
+}
+
+func (ss *ShardedSet[T]) MarshalJSON() ([]byte, error) {
+	return setcodec.MarshalJSON(ss.Snapshot())
+}
+
+func (ss *ShardedSet[T]) UnmarshalJSON(data []byte) error {
+	return setcodec.UnmarshalJSONTo(data, ss.replace)
+}
+
+func (ss *ShardedSet[T]) WriteTo(w io.Writer) (int64, error) {
+	return setcodec.Write(w, ss.Snapshot())
+}
+
+func (ss *ShardedSet[T]) ReadFrom(r io.Reader) (int64, error) {
+	return setcodec.ReadTo(r, ss.replace)
+}
+
+func (ms *MutexSet[T]) replace(values []T) error {
+	data := newData(values)
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	ms.data = data
+	return nil
+}
+
+func (ms *RWMutexSet[T]) replace(values []T) error {
+	data := newData(values)
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	ms.data = data
+	return nil
+}
+
+func (ms *SyncMapSet[T]) replace(values []T) error {
+	s := &syncMapState{}
+	for _, elem := range values {
+		if _, loaded := s.data.LoadOrStore(elem, struct{}{}); !loaded {
+			s.size.Add(1)
+		}
+	}
+	ms.state.Store(s)
+	return nil
+}
+
+// replace locks all stripes, so readers of
+// any stripe wait until the whole set is loaded
+func (ss *ShardedSet[T]) replace(values []T) error {
+	if len(ss.stripes) == 0 {
+		return errNoStripes
+	}
+	data := make([]map[T]struct{}, len(ss.stripes))
+	for i := range data {
+		data[i] = make(map[T]struct{}, len(values)/len(data))
+	}
+	for _, elem := range values {
+		data[ss.hash(elem)&ss.mask][elem] = struct{}{}
+	}
+
+	for i := range ss.stripes {
+		ss.stripes[i].mx.Lock()
+	}
+	for i := range ss.stripes {
+		ss.stripes[i].data = data[i]
+	}
+	for i := range ss.stripes {
+		ss.stripes[i].mx.Unlock()
+	}
+	return nil
+}
+
+func newData[T comparable](values []T) map[T]struct{} {
+	data := make(map[T]struct{}, len(values))
+	for _, elem := range values {
+		data[elem] = struct{}{}
+	}
+	return data
+}
+
+//
+// Float sets apply their NaN policy to loaded values,
+// so a set with NaNReject fails to load NaN with ErrNaN
+//
+
+func (fs *FloatSet[F]) MarshalBinary() ([]byte, error) {
+	return setcodec.Marshal(fs.Snapshot())
+}
+
+func (fs *FloatSet[F]) UnmarshalBinary(data []byte) error {
+	return setcodec.UnmarshalTo(data, fs.replace)
+}
+
+func (fs *FloatSet[F]) MarshalJSON() ([]byte, error) {
+	return setcodec.MarshalJSON(fs.Snapshot())
+}
+
+func (fs *FloatSet[F]) UnmarshalJSON(data []byte) error {
+	return setcodec.UnmarshalJSONTo(data, fs.replace)
+}
+
+func (fs *FloatSet[F]) WriteTo(w io.Writer) (int64, error) {
+	return setcodec.Write(w, fs.Snapshot())
+}
+
+func (fs *FloatSet[F]) ReadFrom(r io.Reader) (int64, error) {
+	return setcodec.ReadTo(r, fs.replace)
+}
+
+func (ss *SortedFloatSet[F]) MarshalBinary() ([]byte, error) {
+	return setcodec.Marshal(ss.Snapshot())
+}
+
+func (ss *SortedFloatSet[F]) UnmarshalBinary(data []byte) error {
+	return setcodec.UnmarshalTo(data, ss.replace)
+}
+
+func (ss *SortedFloatSet[F]) MarshalJSON() ([]byte, error) {
+	return setcodec.MarshalJSON(ss.Snapshot())
+}
+
+func (ss *SortedFloatSet[F]) UnmarshalJSON(data []byte) error {
+	return setcodec.UnmarshalJSONTo(data, ss.replace)
+}
+
+func (ss *SortedFloatSet[F]) WriteTo(w io.Writer) (int64, error) {
+	return setcodec.Write(w, ss.Snapshot())
+}
+
+func (ss *SortedFloatSet[F]) ReadFrom(r io.Reader) (int64, error) {
+	return setcodec.ReadTo(r, ss.replace)
+}
+
+// replace is atomic when keys are stored in a set of this package,
+// other Set implementations are cleared and filled
+func (fs *FloatSet[F]) replace(values []F) error {
+	keys := make([]uint64, len(values))
+	for i, elem := range values {
+		k, ok := fs.key(elem)
+		if !ok {
+			return ErrNaN
+		}
+		keys[i] = k
+	}
+	if r, ok := fs.keys.(replacer[uint64]); ok {
+		return r.replace(keys)
+	}
+	fs.keys.Clear()
+	fs.keys.AddAll(keys...)
+	return nil
+}
+
+func (ss *SortedFloatSet[F]) replace(values []F) error {
+	loaded := NewSortedFloatSet[F](len(values), ss.opts, ss.tol)
+	if err := loaded.AddAll(values...); err != nil {
+		return err
+	}
+
+	ss.mx.Lock()
+	defer ss.mx.Unlock()
+
+	ss.keys = loaded.keys
+	return nil
+}
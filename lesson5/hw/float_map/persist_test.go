@@ -0,0 +1,174 @@
+package floatmap
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"testing"
+
+	"geekbrains/examples/internal/setcodec"
+)
+
+type persistentSet interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+	json.Marshaler
+	json.Unmarshaler
+	io.WriterTo
+	io.ReaderFrom
+}
+
+func sorted(values []float32) string {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	return fmt.Sprint(values)
+}
+
+func TestPersist(t *testing.T) {
+	for _, impl := range setImpls {
+		src := impl.newSet(setSize)
+		src.AddAll(1.5, -2, 0, 1e10)
+		expect := sorted(src.Snapshot())
+
+		save := src.(persistentSet)
+		bin, err := save.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: marshal: %v", impl.name, err)
+		}
+		js, err := save.MarshalJSON()
+		if err != nil {
+			t.Fatalf("%s: marshal json: %v", impl.name, err)
+		}
+		var stream bytes.Buffer
+		if _, err := save.WriteTo(&stream); err != nil {
+			t.Fatalf("%s: write: %v", impl.name, err)
+		}
+
+		loaders := map[string]func(s persistentSet) error{
+			"binary": func(s persistentSet) error { return s.UnmarshalBinary(bin) },
+			"json":   func(s persistentSet) error { return json.Unmarshal(js, s) },
+			"stream": func(s persistentSet) error {
+				_, err := s.ReadFrom(bytes.NewReader(stream.Bytes()))
+				return err
+			},
+		}
+		for name, load := range loaders {
+			dst := impl.newSet(setSize)
+			dst.Add(42) // replaced by loaded values
+			if err := load(dst.(persistentSet)); err != nil {
+				t.Fatalf("%s: load %s: %v", impl.name, name, err)
+			}
+			if got := sorted(dst.Snapshot()); got != expect {
+				t.Errorf("%s: load %s: got %s, expect %s", impl.name, name, got, expect)
+			}
+		}
+
+		// corrupt data doesn't change the set
+		dst := impl.newSet(setSize)
+		dst.Add(42)
+		err = dst.(persistentSet).UnmarshalBinary(bin[:len(bin)-1])
+		if !errors.Is(err, setcodec.ErrCorrupt) {
+			t.Errorf("%s: truncated: got %v, expect ErrCorrupt", impl.name, err)
+		}
+		if got := sorted(dst.Snapshot()); got != "[42]" {
+			t.Errorf("%s: set changed by failed load: %s", impl.name, got)
+		}
+	}
+}
+
+func TestPersistFloatSets(t *testing.T) {
+	nan := math.NaN()
+	src := NewFloatSet[float64](NewMutexSet[uint64](setSize), FloatOptions{})
+	src.AddAll(1, nan, math.Inf(-1))
+	data, err := src.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sortedSet := NewSortedFloatSet[float64](setSize, FloatOptions{}, Tolerance{})
+	if err := sortedSet.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(sortedSet.Snapshot()); got != "[-Inf 1 NaN]" {
+		t.Errorf("sorted set: got %s", got)
+	}
+
+	js, _ := sortedSet.MarshalJSON()
+	back := NewFloatSet[float64](NewRWMutexSet[uint64](setSize), FloatOptions{})
+	if err := json.Unmarshal(js, back); err != nil {
+		t.Fatal(err)
+	}
+	if !back.Has(nan) || back.Len() != 3 {
+		t.Errorf("float set from json: %v", back.Snapshot())
+	}
+
+	// loaded values follow the NaN policy
+	rejecting := NewFloatSet[float64](NewMutexSet[uint64](setSize), FloatOptions{NaN: NaNReject})
+	rejecting.Add(5)
+	if _, err := rejecting.ReadFrom(bytes.NewReader(data)); !errors.Is(err, ErrNaN) {
+		t.Errorf("load NaN into rejecting set: got %v, expect ErrNaN", err)
+	}
+	if !rejecting.Has(5) || rejecting.Len() != 1 {
+		t.Errorf("set changed by failed load")
+	}
+}
+
+func TestPersistAtomic(t *testing.T) {
+	for _, impl := range setImpls {
+		s := impl.newSet(setSize)
+		for i := 0; i < 100; i++ {
+			s.Add(float32(i))
+		}
+		data, err := s.(persistentSet).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 200; i++ {
+				if err := s.(persistentSet).UnmarshalBinary(data); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+		// loaded values are the same, so readers never miss them
+	loop:
+		for {
+			select {
+			case <-done:
+				break loop
+			default:
+			}
+			for i := 0; i < 100; i += 10 {
+				if !s.Has(float32(i)) {
+					t.Errorf("%s: %d is missing during load", impl.name, i)
+					<-done
+					break loop
+				}
+			}
+		}
+	}
+}
+
+func TestPersistZeroSets(t *testing.T) {
+	data, _ := NewMutexSet[float32](0).MarshalBinary()
+
+	var ms MutexSet[float32]
+	var sms SyncMapSet[float32]
+	for name, s := range map[string]persistentSet{"MutexSet": &ms, "SyncMapSet": &sms} {
+		if err := s.UnmarshalBinary(data); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	var ss ShardedSet[float32]
+	if err := ss.UnmarshalBinary(data); err == nil {
+		t.Errorf("zero ShardedSet: expect error")
+	}
+}
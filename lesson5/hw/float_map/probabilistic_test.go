@@ -0,0 +1,179 @@
+package floatmap
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+)
+
+var (
+	_ SetIface = &BloomFilter[float32]{}
+	_ SetIface = &CountingBloomFilter[float32]{}
+)
+
+func TestBloomFilter(t *testing.T) {
+	baseSetTests(t, NewBloomFilter[float32](setSize, 0.01))
+	testFPRate(t, NewBloomFilter[float32](10000, 0.01))
+
+	bf := NewBloomFilter[float32](1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		bf.Add(float32(i))
+	}
+	if got := bf.FPRate(); got > 0.02 {
+		t.Errorf("estimated false positive rate %v, expect about 0.01", got)
+	}
+	if bf.Adds() != 1000 {
+		t.Errorf("adds: got %d, expect 1000", bf.Adds())
+	}
+}
+
+func TestCountingBloomFilter(t *testing.T) {
+	baseSetTests(t, NewCountingBloomFilter[float32](setSize, 0.01))
+	testFPRate(t, NewCountingBloomFilter[float32](10000, 0.01))
+
+	cf := NewCountingBloomFilter[float32](1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		cf.Add(float32(i))
+	}
+	// added twice, so it stays after one remove
+	cf.Add(1)
+	for i := 0; i < 500; i++ {
+		cf.Remove(float32(i))
+	}
+	if !cf.Has(1) {
+		t.Errorf("element added twice is removed")
+	}
+	for i := 500; i < 1000; i++ {
+		if !cf.Has(float32(i)) {
+			t.Fatalf("not removed element %d is lost", i)
+		}
+	}
+	removed := 0
+	for i := 2; i < 500; i++ {
+		if !cf.Has(float32(i)) {
+			removed++
+		}
+	}
+	if removed < 490 {
+		t.Errorf("only %d of 498 removed elements are gone", removed)
+	}
+}
+
+// testFPRate adds 10000 elements and checks
+// there are no false negatives and about 1% false positives
+func testFPRate(t *testing.T, set SetIface) {
+	t.Helper()
+
+	const n = 10000
+	for i := 0; i < n; i++ {
+		set.Add(float32(i))
+	}
+	for i := 0; i < n; i++ {
+		if !set.Has(float32(i)) {
+			t.Fatalf("false negative for %d", i)
+		}
+	}
+	fp := 0
+	for i := n; i < 2*n; i++ {
+		if set.Has(float32(i)) {
+			fp++
+		}
+	}
+	if rate := float64(fp) / n; rate > 0.02 {
+		t.Errorf("false positive rate %v, expect about 0.01", rate)
+	}
+}
+
+func TestHyperLogLog(t *testing.T) {
+	for _, n := range []int{0, 10, 1000, 100000} {
+		h := NewHyperLogLog[float32](14)
+		for i := 0; i < n; i++ {
+			// duplicates don't count
+			h.Add(float32(i))
+			h.Add(float32(i))
+		}
+		// 3 standard errors
+		tolerance := 3 * 1.04 / math.Sqrt(1<<14) * float64(n)
+		if got := float64(h.Count()); math.Abs(got-float64(n)) > tolerance+1 {
+			t.Errorf("count of %d: got %v", n, got)
+		}
+	}
+}
+
+func TestHyperLogLogMerge(t *testing.T) {
+	a := NewHyperLogLog[int](12)
+	b := NewHyperLogLogLike(a)
+	for i := 0; i < 20000; i++ {
+		a.Add(i)
+		b.Add(i + 10000)
+	}
+	if !a.Merge(b) {
+		t.Fatal("merge of compatible estimators failed")
+	}
+	if got := float64(a.Count()); math.Abs(got-30000) > 30000*3*1.04/64 {
+		t.Errorf("merged count: got %v, expect about 30000", got)
+	}
+	if a.Merge(NewHyperLogLog[int](12)) {
+		t.Errorf("merge with another seed should fail")
+	}
+}
+
+func TestProbabilisticConcurrent(t *testing.T) {
+	bf := NewBloomFilter[float32](1000, 0.01)
+	cf := NewCountingBloomFilter[float32](1000, 0.01)
+	h := NewHyperLogLog[float32](10)
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				v := float32(w*1000 + i)
+				bf.Add(v)
+				cf.Add(v)
+				h.Add(v)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for i := 0; i < 8000; i++ {
+		if !bf.Has(float32(i)) || !cf.Has(float32(i)) {
+			t.Fatalf("concurrently added %d is lost", i)
+		}
+	}
+	if got := float64(h.Count()); math.Abs(got-8000) > 8000*3*1.04/32 {
+		t.Errorf("count: got %v, expect about 8000", got)
+	}
+}
+
+func BenchmarkBloomFilter(b *testing.B) {
+	for _, writePct := range benchWritersPctTable {
+		benchDescr := fmt.Sprintf("test set write/read with %d%% writers", writePct)
+		b.Run(benchDescr, func(b *testing.B) {
+			testSet := NewBloomFilter[float32](benchWorkers, 0.01)
+			baseSetBench(b, testSet, writePct)
+		})
+	}
+}
+
+func BenchmarkCountingBloomFilter(b *testing.B) {
+	for _, writePct := range benchWritersPctTable {
+		benchDescr := fmt.Sprintf("test set write/read with %d%% writers", writePct)
+		b.Run(benchDescr, func(b *testing.B) {
+			testSet := NewCountingBloomFilter[float32](benchWorkers, 0.01)
+			baseSetBench(b, testSet, writePct)
+		})
+	}
+}
+
+func BenchmarkHyperLogLog(b *testing.B) {
+	h := NewHyperLogLog[float32](14)
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			h.Add(float32(i))
+		}
+	})
+}
@@ -5,27 +5,77 @@ package floatmap
 
 import "sync"
 
-type RWMutexSet struct {
+type RWMutexSet[T comparable] struct {
 	mx   sync.RWMutex
-	data map[float32]struct{}
+	data map[T]struct{}
 }
 
-func NewRWMutexSet(size int) *RWMutexSet {
-	data := make(map[float32]struct{}, size)
-	return &RWMutexSet{data: data}
+func NewRWMutexSet[T comparable](size int) *RWMutexSet[T] {
+	data := make(map[T]struct{}, size)
+	return &RWMutexSet[T]{data: data}
 }
 
-func (ms *RWMutexSet) Add(elem float32) {
+func (ms *RWMutexSet[T]) Add(elem T) {
 	ms.mx.Lock()
 	defer ms.mx.Unlock()
 
 	ms.data[elem] = struct{}{}
 }
 
-func (ms *RWMutexSet) Has(elem float32) bool {
+func (ms *RWMutexSet[T]) AddAll(elems ...T) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	for _, elem := range elems {
+		ms.data[elem] = struct{}{}
+	}
+}
+
+func (ms *RWMutexSet[T]) Has(elem T) bool {
 	ms.mx.RLock()
 	defer ms.mx.RUnlock()
 
 	_, ok := ms.data[elem]
 	return ok
 }
+
+func (ms *RWMutexSet[T]) Remove(elem T) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	delete(ms.data, elem)
+}
+
+func (ms *RWMutexSet[T]) Len() int {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+
+	return len(ms.data)
+}
+
+// Range iterates over snapshot, so {f} is called without lock
+func (ms *RWMutexSet[T]) Range(f func(elem T) bool) {
+	for _, elem := range ms.Snapshot() {
+		if !f(elem) {
+			return
+		}
+	}
+}
+
+func (ms *RWMutexSet[T]) Clear() {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	ms.data = make(map[T]struct{})
+}
+
+func (ms *RWMutexSet[T]) Snapshot() []T {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+
+	values := make([]T, 0, len(ms.data))
+	for val := range ms.data {
+		values = append(values, val)
+	}
+	return values
+}
@@ -0,0 +1,27 @@
+// Package floatmap contains a few
+// Set datastucture implementations
+// using different sync primitives
+package floatmap
+
+// Set is a set of comparable values safe for concurrent use
+type Set[T comparable] interface {
+	Add(elem T)
+	// AddAll adds all {elems} at once
+	AddAll(elems ...T)
+	Has(elem T) bool
+	Remove(elem T)
+	Len() int
+	// Range calls {f} for each element until it returns false,
+	// {f} may modify the set
+	Range(f func(elem T) bool)
+	Clear()
+	// Snapshot returns elements in arbitrary order
+	Snapshot() []T
+}
+
+var (
+	_ Set[float32] = &MutexSet[float32]{}
+	_ Set[float32] = &RWMutexSet[float32]{}
+	_ Set[float32] = &SyncMapSet[float32]{}
+	_ Set[float32] = &ShardedSet[float32]{}
+)
@@ -2,6 +2,7 @@ package floatmap
 
 import (
 	"fmt"
+	"math"
 	"sync"
 	"testing"
 )
@@ -37,20 +38,35 @@ func baseSetTests(t *testing.T, set SetIface) {
 }
 
 func TestMutexSet(t *testing.T) {
-	testSet := NewMutexSet(setSize)
+	testSet := NewMutexSet[float32](setSize)
 	baseSetTests(t, testSet)
 }
 
 func TestRwMutexSet(t *testing.T) {
-	testSet := NewRWMutexSet(setSize)
+	testSet := NewRWMutexSet[float32](setSize)
 	baseSetTests(t, testSet)
 }
 
 func TestSyncMapSet(t *testing.T) {
-	testSet := NewSyncMapSet(setSize)
+	testSet := NewSyncMapSet[float32](setSize)
 	baseSetTests(t, testSet)
 }
 
+func TestShardedSet(t *testing.T) {
+	testSet := NewShardedSet[float32](setSize, 0)
+	baseSetTests(t, testSet)
+
+	if got := NewShardedSet[float32](setSize, 5).Stripes(); got != 8 {
+		t.Errorf("stripes should be rounded to power of two: got %d, expect 8", got)
+	}
+
+	// +0 and -0 are equal map keys, so they must get the same stripe
+	testSet.Add(float32(math.Copysign(0, -1)))
+	if !testSet.Has(0) {
+		t.Errorf("-0 and +0 are hashed into different stripes")
+	}
+}
+
 //
 // Benchmarks
 //
@@ -88,7 +104,7 @@ func BenchmarkMutexSet(b *testing.B) {
 	for _, writePct := range benchWritersPctTable {
 		benchDescr := fmt.Sprintf("test set write/read with %d%% writers", writePct)
 		b.Run(benchDescr, func(b *testing.B) {
-			testSet := NewMutexSet(setSize)
+			testSet := NewMutexSet[float32](setSize)
 			baseSetBench(b, testSet, writePct)
 		})
 	}
@@ -98,7 +114,7 @@ func BenchmarkRWMutexSet(b *testing.B) {
 	for _, writePct := range benchWritersPctTable {
 		benchDescr := fmt.Sprintf("test set write/read with %d%% writers", writePct)
 		b.Run(benchDescr, func(b *testing.B) {
-			testSet := NewRWMutexSet(setSize)
+			testSet := NewRWMutexSet[float32](setSize)
 			baseSetBench(b, testSet, writePct)
 		})
 	}
@@ -108,7 +124,17 @@ func BenchmarkSyncMapSet(b *testing.B) {
 	for _, writePct := range benchWritersPctTable {
 		benchDescr := fmt.Sprintf("test set write/read with %d%% writers", writePct)
 		b.Run(benchDescr, func(b *testing.B) {
-			testSet := NewSyncMapSet(setSize)
+			testSet := NewSyncMapSet[float32](setSize)
+			baseSetBench(b, testSet, writePct)
+		})
+	}
+}
+
+func BenchmarkShardedSet(b *testing.B) {
+	for _, writePct := range benchWritersPctTable {
+		benchDescr := fmt.Sprintf("test set write/read with %d%% writers", writePct)
+		b.Run(benchDescr, func(b *testing.B) {
+			testSet := NewShardedSet[float32](setSize, 0)
 			baseSetBench(b, testSet, writePct)
 		})
 	}
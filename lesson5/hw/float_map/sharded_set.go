@@ -0,0 +1,137 @@
+// Package floatmap contains a few
+// Set datastucture implementations
+// using different sync primitives
+package floatmap
+
+import (
+	"hash/maphash"
+	"runtime"
+	"sync"
+)
+
+// ShardedSet splits elements into stripes by hash,
+// each stripe has its own lock, so writers of
+// different stripes don't wait for each other
+type ShardedSet[T comparable] struct {
+	stripes []stripe[T]
+	mask    uint64
+	seed    maphash.Seed
+}
+
+type stripe[T comparable] struct {
+	mx   sync.RWMutex
+	data map[T]struct{}
+	// keep neighbour stripes in different cache lines
+	_ [64]byte
+}
+
+// NewShardedSet creates set with {stripes} stripes rounded up
+// to power of two, {stripes} <= 0 means 4 stripes per GOMAXPROCS
+func NewShardedSet[T comparable](size, stripes int) *ShardedSet[T] {
+	if stripes <= 0 {
+		stripes = 4 * runtime.GOMAXPROCS(0)
+	}
+	n := 1
+	for n < stripes {
+		n <<= 1
+	}
+
+	ss := &ShardedSet[T]{
+		stripes: make([]stripe[T], n),
+		mask:    uint64(n - 1),
+		seed:    maphash.MakeSeed(),
+	}
+	for i := range ss.stripes {
+		ss.stripes[i].data = make(map[T]struct{}, size/n)
+	}
+	return ss
+}
+
+// Stripes returns number of stripes
+func (ss *ShardedSet[T]) Stripes() int {
+	return len(ss.stripes)
+}
+
+func (ss *ShardedSet[T]) stripe(elem T) *stripe[T] {
+	return &ss.stripes[ss.hash(elem)&ss.mask]
+}
+
+func (ss *ShardedSet[T]) hash(elem T) uint64 {
+	return hashOf(ss.seed, elem)
+}
+
+func (ss *ShardedSet[T]) Add(elem T) {
+	s := ss.stripe(elem)
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.data[elem] = struct{}{}
+}
+
+func (ss *ShardedSet[T]) AddAll(elems ...T) {
+	for _, elem := range elems {
+		ss.Add(elem)
+	}
+}
+
+func (ss *ShardedSet[T]) Has(elem T) bool {
+	s := ss.stripe(elem)
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	_, ok := s.data[elem]
+	return ok
+}
+
+func (ss *ShardedSet[T]) Remove(elem T) {
+	s := ss.stripe(elem)
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	delete(s.data, elem)
+}
+
+// Len sums stripes one by one, so under concurrent
+// writes it isn't a point-in-time value
+func (ss *ShardedSet[T]) Len() int {
+	n := 0
+	for i := range ss.stripes {
+		s := &ss.stripes[i]
+		s.mx.RLock()
+		n += len(s.data)
+		s.mx.RUnlock()
+	}
+	return n
+}
+
+// Range iterates over snapshot, so {f} is called without lock
+func (ss *ShardedSet[T]) Range(f func(elem T) bool) {
+	for _, elem := range ss.Snapshot() {
+		if !f(elem) {
+			return
+		}
+	}
+}
+
+func (ss *ShardedSet[T]) Clear() {
+	for i := range ss.stripes {
+		s := &ss.stripes[i]
+		s.mx.Lock()
+		s.data = make(map[T]struct{})
+		s.mx.Unlock()
+	}
+}
+
+// Snapshot copies stripes one by one, like Len
+func (ss *ShardedSet[T]) Snapshot() []T {
+	values := make([]T, 0, ss.Len())
+	for i := range ss.stripes {
+		s := &ss.stripes[i]
+		s.mx.RLock()
+		for val := range s.data {
+			values = append(values, val)
+		}
+		s.mx.RUnlock()
+	}
+	return values
+}
@@ -0,0 +1,232 @@
+// Package floatmap contains a few
+// Set datastucture implementations
+// using different sync primitives
+package floatmap
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Tolerance defines approximate membership of SortedFloatSet,
+// element is found if it is close enough by any of the limits.
+// Zero value means exact membership
+type Tolerance struct {
+	// Abs is max absolute difference
+	Abs float64
+	// ULP is max count of representable values of F in between
+	ULP uint64
+}
+
+// SortedFloatSet keeps floats sorted, so it supports
+// approximate membership and range queries.
+// Add and Remove are O(n), lookups are O(log n)
+type SortedFloatSet[F Float] struct {
+	mx   sync.RWMutex
+	opts FloatOptions
+	tol  Tolerance
+	// sorted keys, see key
+	keys []uint64
+}
+
+func NewSortedFloatSet[F Float](size int, opts FloatOptions, tol Tolerance) *SortedFloatSet[F] {
+	return &SortedFloatSet[F]{
+		opts: opts,
+		tol:  tol,
+		keys: make([]uint64, 0, size),
+	}
+}
+
+func (ss *SortedFloatSet[F]) key(elem F) (uint64, bool) {
+	x, ok := ss.opts.canonical(float64(elem))
+	return key(F(x)), ok
+}
+
+// search returns position of {k} or where it should be, ss.mx should be held
+func (ss *SortedFloatSet[F]) search(k uint64) (int, bool) {
+	i := sort.Search(len(ss.keys), func(i int) bool { return ss.keys[i] >= k })
+	return i, i < len(ss.keys) && ss.keys[i] == k
+}
+
+func (ss *SortedFloatSet[F]) Add(elem F) error {
+	k, ok := ss.key(elem)
+	if !ok {
+		return ErrNaN
+	}
+
+	ss.mx.Lock()
+	defer ss.mx.Unlock()
+
+	ss.insert(k)
+	return nil
+}
+
+// AddAll adds nothing if any of {elems} is rejected
+func (ss *SortedFloatSet[F]) AddAll(elems ...F) error {
+	keys := make([]uint64, len(elems))
+	for i, elem := range elems {
+		k, ok := ss.key(elem)
+		if !ok {
+			return ErrNaN
+		}
+		keys[i] = k
+	}
+
+	ss.mx.Lock()
+	defer ss.mx.Unlock()
+
+	for _, k := range keys {
+		ss.insert(k)
+	}
+	return nil
+}
+
+// insert adds {k} keeping keys sorted, ss.mx should be held
+func (ss *SortedFloatSet[F]) insert(k uint64) {
+	i, found := ss.search(k)
+	if found {
+		return
+	}
+	ss.keys = append(ss.keys, 0)
+	copy(ss.keys[i+1:], ss.keys[i:])
+	ss.keys[i] = k
+}
+
+// Has reports if set contains element within tolerance from {elem}.
+// NaN is never close to numbers, it is found only if it is a member
+func (ss *SortedFloatSet[F]) Has(elem F) bool {
+	k, ok := ss.key(elem)
+	if !ok {
+		return false
+	}
+
+	ss.mx.RLock()
+	defer ss.mx.RUnlock()
+
+	i, found := ss.search(k)
+	if found {
+		return true
+	}
+	// the closest elements are the neighbours in sorted order
+	return i > 0 && ss.close(k, ss.keys[i-1]) ||
+		i < len(ss.keys) && ss.close(k, ss.keys[i])
+}
+
+// Nearest returns member closest to {elem} in sorted order,
+// it is false if set is empty or {elem} is rejected NaN
+func (ss *SortedFloatSet[F]) Nearest(elem F) (F, bool) {
+	k, ok := ss.key(elem)
+	if !ok {
+		return 0, false
+	}
+
+	ss.mx.RLock()
+	defer ss.mx.RUnlock()
+
+	if len(ss.keys) == 0 {
+		return 0, false
+	}
+	i, _ := ss.search(k)
+	switch {
+	case i == len(ss.keys):
+		i--
+	case i > 0 && k-ss.keys[i-1] < ss.keys[i]-k:
+		i--
+	}
+	return fromKey[F](ss.keys[i]), true
+}
+
+// close reports if {a} and {b} are equal within tolerance
+func (ss *SortedFloatSet[F]) close(a, b uint64) bool {
+	if a > b {
+		a, b = b, a
+	}
+	if b-a <= ss.tol.ULP {
+		return true
+	}
+	if ss.tol.Abs == 0 {
+		// exact, even for distinct zeros
+		return false
+	}
+	x, y := float64(fromKey[F](a)), float64(fromKey[F](b))
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return false
+	}
+	return y-x <= ss.tol.Abs
+}
+
+// Between returns sorted members in [lo, hi]
+func (ss *SortedFloatSet[F]) Between(lo, hi F) []F {
+	loKey, ok := ss.key(lo)
+	if !ok {
+		return nil
+	}
+	hiKey, ok := ss.key(hi)
+	if !ok {
+		return nil
+	}
+
+	ss.mx.RLock()
+	defer ss.mx.RUnlock()
+
+	from, _ := ss.search(loKey)
+	var values []F
+	for _, k := range ss.keys[from:] {
+		if k > hiKey {
+			break
+		}
+		values = append(values, fromKey[F](k))
+	}
+	return values
+}
+
+// Remove removes exactly {elem}, tolerance isn't applied
+func (ss *SortedFloatSet[F]) Remove(elem F) {
+	k, ok := ss.key(elem)
+	if !ok {
+		return
+	}
+
+	ss.mx.Lock()
+	defer ss.mx.Unlock()
+
+	if i, found := ss.search(k); found {
+		ss.keys = append(ss.keys[:i], ss.keys[i+1:]...)
+	}
+}
+
+func (ss *SortedFloatSet[F]) Len() int {
+	ss.mx.RLock()
+	defer ss.mx.RUnlock()
+
+	return len(ss.keys)
+}
+
+// Range iterates over sorted snapshot, so {f} is called without lock
+func (ss *SortedFloatSet[F]) Range(f func(elem F) bool) {
+	for _, elem := range ss.Snapshot() {
+		if !f(elem) {
+			return
+		}
+	}
+}
+
+func (ss *SortedFloatSet[F]) Clear() {
+	ss.mx.Lock()
+	defer ss.mx.Unlock()
+
+	ss.keys = ss.keys[:0]
+}
+
+// Snapshot returns sorted elements
+func (ss *SortedFloatSet[F]) Snapshot() []F {
+	ss.mx.RLock()
+	defer ss.mx.RUnlock()
+
+	values := make([]F, len(ss.keys))
+	for i, k := range ss.keys {
+		values[i] = fromKey[F](k)
+	}
+	return values
+}
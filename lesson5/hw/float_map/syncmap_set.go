@@ -3,21 +3,84 @@
 // using different sync primitives
 package floatmap
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+)
 
-type SyncMapSet struct {
+type SyncMapSet[T comparable] struct {
+	// state is swapped at once on load
+	state atomic.Pointer[syncMapState]
+}
+
+type syncMapState struct {
 	data sync.Map
+	// sync.Map doesn't count its elements
+	size atomic.Int64
+}
+
+// NewSyncMapSet creates empty set, sync.Map can't be
+// preallocated, so {size} is accepted only to match other sets
+func NewSyncMapSet[T comparable](size int) *SyncMapSet[T] {
+	ms := &SyncMapSet[T]{}
+	ms.state.Store(&syncMapState{})
+	return ms
 }
 
-func NewSyncMapSet(size int) *SyncMapSet {
-	return &SyncMapSet{}
+// current returns elements, zero value set creates them on first use
+func (ms *SyncMapSet[T]) current() *syncMapState {
+	if s := ms.state.Load(); s != nil {
+		return s
+	}
+	ms.state.CompareAndSwap(nil, &syncMapState{})
+	return ms.state.Load()
 }
 
-func (ms *SyncMapSet) Add(elem float32) {
-	ms.data.Store(elem, struct{}{})
+func (ms *SyncMapSet[T]) Add(elem T) {
+	s := ms.current()
+	if _, loaded := s.data.LoadOrStore(elem, struct{}{}); !loaded {
+		s.size.Add(1)
+	}
 }
 
-func (ms *SyncMapSet) Has(elem float32) bool {
-	_, ok := ms.data.Load(elem)
+func (ms *SyncMapSet[T]) AddAll(elems ...T) {
+	for _, elem := range elems {
+		ms.Add(elem)
+	}
+}
+
+func (ms *SyncMapSet[T]) Has(elem T) bool {
+	_, ok := ms.current().data.Load(elem)
 	return ok
 }
+
+func (ms *SyncMapSet[T]) Remove(elem T) {
+	s := ms.current()
+	if _, loaded := s.data.LoadAndDelete(elem); loaded {
+		s.size.Add(-1)
+	}
+}
+
+func (ms *SyncMapSet[T]) Len() int {
+	return int(ms.current().size.Load())
+}
+
+func (ms *SyncMapSet[T]) Range(f func(elem T) bool) {
+	ms.current().data.Range(func(key, _ interface{}) bool {
+		return f(key.(T))
+	})
+}
+
+// Clear replaces elements with empty ones at once
+func (ms *SyncMapSet[T]) Clear() {
+	ms.state.Store(&syncMapState{})
+}
+
+func (ms *SyncMapSet[T]) Snapshot() []T {
+	values := make([]T, 0, ms.Len())
+	ms.Range(func(elem T) bool {
+		values = append(values, elem)
+		return true
+	})
+	return values
+}
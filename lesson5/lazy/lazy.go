@@ -0,0 +1,188 @@
+// Package lazy initializes a value on first use like sync.Once,
+// but retries failed initialization and lets callers give up on their ctx
+//
+//	conn := lazy.New(dial, lazy.Options{Backoff: 100 * time.Millisecond})
+//	c, gen, err := conn.GetGen(ctx)
+//	...
+//	if isBroken(err) {
+//		conn.Reset(gen)
+//	}
+package lazy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// InitFunc creates the value. ctx is not canceled by callers
+// giving up, because the attempt is shared by all of them
+type InitFunc[T any] func(ctx context.Context) (T, error)
+
+type Options struct {
+	// Backoff is the delay before retry after the first failure,
+	// it is doubled on each next failure up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Timeout limits one attempt, 0 means no limit
+	Timeout time.Duration
+}
+
+// Gen identifies an initialized value, it grows with each initialization
+type Gen uint64
+
+// attempt is an initialization in flight, done is closed when it finished
+type attempt[T any] struct {
+	done  chan struct{}
+	value T
+	gen   Gen
+	err   error
+}
+
+type Lazy[T any] struct {
+	init InitFunc[T]
+	opts Options
+
+	mx      sync.Mutex
+	ready   bool
+	value   T
+	gen     Gen
+	running *attempt[T]
+	// failures counts consecutive failed attempts
+	failures int
+	lastErr  error
+	retryAt  time.Time
+}
+
+func New[T any](init InitFunc[T], opts Options) *Lazy[T] {
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = max(opts.Backoff, time.Minute)
+	}
+	return &Lazy[T]{init: init, opts: opts}
+}
+
+// Get returns the value, initializing it if needed.
+// Concurrent callers share one attempt. After a failure the next
+// attempt starts by a call made after backoff, earlier callers wait for it.
+// Each caller waits no longer than its ctx allows
+func (l *Lazy[T]) Get(ctx context.Context) (T, error) {
+	v, _, err := l.GetGen(ctx)
+	return v, err
+}
+
+// GetGen is Get, which also returns generation of the value for Reset
+func (l *Lazy[T]) GetGen(ctx context.Context) (T, Gen, error) {
+	var zero T
+	for {
+		l.mx.Lock()
+		if l.ready {
+			v, gen := l.value, l.gen
+			l.mx.Unlock()
+			return v, gen, nil
+		}
+		if l.running == nil {
+			if wait := time.Until(l.retryAt); wait > 0 {
+				lastErr := l.lastErr
+				l.mx.Unlock()
+				// don't wait for a retry the caller won't see
+				if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+					return zero, 0, fmt.Errorf("%w, last init error: %v", context.DeadlineExceeded, lastErr)
+				}
+				if err := sleep(ctx, wait); err != nil {
+					return zero, 0, fmt.Errorf("%w, last init error: %v", err, lastErr)
+				}
+				continue
+			}
+			l.start(ctx)
+		}
+		a := l.running
+		l.mx.Unlock()
+
+		select {
+		case <-a.done:
+			return a.value, a.gen, a.err
+		case <-ctx.Done():
+			return zero, 0, ctx.Err()
+		}
+	}
+}
+
+// start runs new attempt aside, l.mx should be held
+func (l *Lazy[T]) start(ctx context.Context) {
+	a := &attempt[T]{done: make(chan struct{})}
+	l.running = a
+
+	// keep values of the first caller, but not its cancelation
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if l.opts.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, l.opts.Timeout)
+			defer cancel()
+		}
+		a.value, a.err = l.init(ctx)
+
+		l.mx.Lock()
+		l.running = nil
+		if a.err == nil {
+			l.gen++
+			a.gen = l.gen
+			l.ready, l.value = true, a.value
+			l.failures, l.lastErr, l.retryAt = 0, nil, time.Time{}
+		} else {
+			l.failures++
+			l.lastErr = a.err
+			l.retryAt = time.Now().Add(l.backoff())
+		}
+		l.mx.Unlock()
+		close(a.done)
+	}()
+}
+
+// backoff returns delay after l.failures failures, l.mx should be held
+func (l *Lazy[T]) backoff() time.Duration {
+	d := l.opts.Backoff
+	for i := 1; i < l.failures && d < l.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, l.opts.MaxBackoff)
+}
+
+// Reset forgets the value of generation {gen}, so the next Get
+// initializes it again. It is used when the value turns out broken,
+// e.g. connection is lost. A value initialized after {gen} is kept,
+// so callers holding the same broken value reinitialize it once.
+// Attempt in flight is not affected. It reports if the value is forgotten
+func (l *Lazy[T]) Reset(gen Gen) bool {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	if !l.ready || l.gen != gen {
+		return false
+	}
+	var zero T
+	l.ready, l.value = false, zero
+	return true
+}
+
+// Peek returns the value if it is initialized, without initializing it
+func (l *Lazy[T]) Peek() (T, bool) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	return l.value, l.ready
+}
+
+// sleep waits {d} or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
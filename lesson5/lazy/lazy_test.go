@@ -0,0 +1,209 @@
+package lazy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errDial = errors.New("dial failed")
+
+// flaky fails first {fails} calls
+func flaky(fails int32, calls *int32) InitFunc[string] {
+	return func(ctx context.Context) (string, error) {
+		if n := atomic.AddInt32(calls, 1); n <= fails {
+			return "", errDial
+		}
+		return "connected", nil
+	}
+}
+
+func TestRetryAfterFailure(t *testing.T) {
+	var calls int32
+	l := New(flaky(2, &calls), Options{})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := l.Get(ctx); err != errDial {
+			t.Fatalf("attempt %d: expected errDial, got %v", i, err)
+		}
+	}
+	v, err := l.Get(ctx)
+	if err != nil || v != "connected" {
+		t.Fatalf("got %q, %v", v, err)
+	}
+	l.Get(ctx)
+	if calls != 3 {
+		t.Errorf("init called %d times, expect 3", calls)
+	}
+}
+
+func TestSingleAttempt(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	l := New(func(ctx context.Context) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return 42, nil
+	}, Options{})
+
+	const callers = 20
+	wg := sync.WaitGroup{}
+	results := make([]int, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = l.Get(context.Background())
+		}(i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("init called %d times, expect 1", calls)
+	}
+	for i, v := range results {
+		if v != 42 {
+			t.Errorf("caller %d got %d", i, v)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	var calls int32
+	l := New(flaky(2, &calls), Options{Backoff: 30 * time.Millisecond})
+
+	start := time.Now()
+	l.Get(context.Background())
+	l.Get(context.Background()) // waits 30ms
+	l.Get(context.Background()) // waits 60ms
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Errorf("3 attempts took %v, backoff ignored", d)
+	}
+	if v, ok := l.Peek(); !ok || v != "connected" {
+		t.Errorf("peek got %q %v", v, ok)
+	}
+}
+
+func TestCallerDeadline(t *testing.T) {
+	var calls int32
+	l := New(flaky(1, &calls), Options{Backoff: time.Hour})
+	l.Get(context.Background())
+
+	// retry is an hour away, short deadline fails at once
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := l.Get(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), errDial.Error()) {
+		t.Errorf("unexpected error %v", err)
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("waited %v for nothing", d)
+	}
+}
+
+func TestCallerGivesUp(t *testing.T) {
+	release := make(chan struct{})
+	l := New(func(ctx context.Context) (string, error) {
+		<-release
+		// attempt is not canceled by the caller
+		return "ok", ctx.Err()
+	}, Options{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.Get(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline, got %v", err)
+	}
+
+	close(release)
+	v, err := l.Get(context.Background())
+	if err != nil || v != "ok" {
+		t.Fatalf("got %q, %v", v, err)
+	}
+}
+
+func TestAttemptTimeout(t *testing.T) {
+	l := New(func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}, Options{Timeout: 10 * time.Millisecond})
+
+	if _, err := l.Get(context.Background()); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline, got %v", err)
+	}
+}
+
+func TestReset(t *testing.T) {
+	var calls int32
+	l := New(func(ctx context.Context) (int32, error) {
+		return atomic.AddInt32(&calls, 1), nil
+	}, Options{})
+
+	v, gen, _ := l.GetGen(context.Background())
+	if v != 1 {
+		t.Fatalf("got %d", v)
+	}
+	if !l.Reset(gen) {
+		t.Error("current value is not reset")
+	}
+	if _, ok := l.Peek(); ok {
+		t.Error("value is kept after reset")
+	}
+	if v, _ := l.Get(context.Background()); v != 2 {
+		t.Fatalf("got %d after reset, expect new value", v)
+	}
+}
+
+func TestStaleReset(t *testing.T) {
+	var calls int32
+	l := New(func(ctx context.Context) (int32, error) {
+		return atomic.AddInt32(&calls, 1), nil
+	}, Options{})
+
+	// both callers got the same broken value
+	ctx := context.Background()
+	_, gen1, _ := l.GetGen(ctx)
+	_, gen2, _ := l.GetGen(ctx)
+	if gen1 != gen2 {
+		t.Fatalf("one value has generations %d and %d", gen1, gen2)
+	}
+
+	// the first one reinitializes it
+	l.Reset(gen1)
+	v, gen, _ := l.GetGen(ctx)
+	if v != 2 || gen == gen1 {
+		t.Fatalf("got %d of generation %d after reset", v, gen)
+	}
+
+	// the second one resets too late, the new value stays
+	if l.Reset(gen2) {
+		t.Error("stale reset forgot the new value")
+	}
+	if v, ok := l.Peek(); !ok || v != 2 {
+		t.Errorf("got %d, %v after stale reset", v, ok)
+	}
+
+	// concurrent resets of one generation initialize once
+	_, gen, _ = l.GetGen(ctx)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Reset(gen)
+			l.Get(ctx)
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("got %d inits, expect 3", n)
+	}
+}
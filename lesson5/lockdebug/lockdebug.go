@@ -0,0 +1,126 @@
+// Package lockdebug contains drop-in replacements for sync.Mutex
+// and sync.RWMutex which help to find deadlocks.
+//
+// Instrumentation is enabled by "lockdebug" build tag
+// or by LOCKDEBUG=1 environment variable:
+//
+//	go run -tags lockdebug ./lesson5/mutex
+//	LOCKDEBUG=1 LOCKDEBUG_HOLD=10ms go run ./lesson5/mutex_map
+//
+// When enabled, it
+//   - reports lock order inversions: goroutine takes B while holding A,
+//     but somewhere else A was taken while holding B
+//   - reports locks held longer than the threshold (LOCKDEBUG_HOLD, 100ms by default)
+//   - dumps current holders with acquisition stacks by Dump
+//
+// When disabled, each call costs one bool check on top of sync locks
+package lockdebug
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultHoldThreshold = 100 * time.Millisecond
+
+// enabled is set once at init, so it's safe to read without sync
+var enabled = forceEnabled || os.Getenv("LOCKDEBUG") == "1"
+
+func init() {
+	if d, err := time.ParseDuration(os.Getenv("LOCKDEBUG_HOLD")); err == nil {
+		SetHoldThreshold(d)
+	}
+}
+
+// Enabled reports if instrumentation is active
+func Enabled() bool {
+	return enabled
+}
+
+// Mutex is sync.Mutex with optional instrumentation,
+// zero value is unlocked mutex
+type Mutex struct {
+	mu sync.Mutex
+}
+
+func (m *Mutex) Lock() {
+	if !enabled {
+		m.mu.Lock()
+		return
+	}
+	held := registry.beforeLock(m, modeWrite)
+	m.mu.Lock()
+	registry.afterLock(held)
+}
+
+func (m *Mutex) TryLock() bool {
+	if !enabled {
+		return m.mu.TryLock()
+	}
+	if !m.mu.TryLock() {
+		return false
+	}
+	// TryLock can't deadlock, so only remember the holder
+	registry.afterLock(registry.newHeld(m, modeWrite))
+	return true
+}
+
+func (m *Mutex) Unlock() {
+	if enabled {
+		registry.unlock(m, modeWrite)
+	}
+	m.mu.Unlock()
+}
+
+// RWMutex is sync.RWMutex with optional instrumentation,
+// zero value is unlocked mutex
+type RWMutex struct {
+	mu sync.RWMutex
+}
+
+func (m *RWMutex) Lock() {
+	if !enabled {
+		m.mu.Lock()
+		return
+	}
+	held := registry.beforeLock(m, modeWrite)
+	m.mu.Lock()
+	registry.afterLock(held)
+}
+
+func (m *RWMutex) Unlock() {
+	if enabled {
+		registry.unlock(m, modeWrite)
+	}
+	m.mu.Unlock()
+}
+
+func (m *RWMutex) RLock() {
+	if !enabled {
+		m.mu.RLock()
+		return
+	}
+	held := registry.beforeLock(m, modeRead)
+	m.mu.RLock()
+	registry.afterLock(held)
+}
+
+func (m *RWMutex) RUnlock() {
+	if enabled {
+		registry.unlock(m, modeRead)
+	}
+	m.mu.RUnlock()
+}
+
+// RLocker returns a Locker using RLock and RUnlock
+func (m *RWMutex) RLocker() sync.Locker {
+	return rlocker{m}
+}
+
+type rlocker struct {
+	m *RWMutex
+}
+
+func (r rlocker) Lock()   { r.m.RLock() }
+func (r rlocker) Unlock() { r.m.RUnlock() }
@@ -0,0 +1,243 @@
+package lockdebug
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// enable turns instrumentation on with clean registry,
+// returns reports collected during the test
+func enable(t *testing.T) *[]Report {
+	prevEnabled, prevRegistry := enabled, registry
+	t.Cleanup(func() {
+		enabled, registry = prevEnabled, prevRegistry
+	})
+
+	enabled = true
+	registry = newRegistry()
+	reports := &[]Report{}
+	var mu sync.Mutex
+	SetReporter(func(r Report) {
+		mu.Lock()
+		defer mu.Unlock()
+		*reports = append(*reports, r)
+	})
+	return reports
+}
+
+func kinds(reports []Report) string {
+	var list []string
+	for _, r := range reports {
+		list = append(list, r.Kind)
+	}
+	return strings.Join(list, ",")
+}
+
+func TestLockOrderInversion(t *testing.T) {
+	reports := enable(t)
+	a, b := &Mutex{}, &RWMutex{}
+
+	// goroutines don't overlap in time, so no real deadlock happens,
+	// but the inversion is found
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.Lock()
+		b.Lock()
+		b.Unlock()
+		a.Unlock()
+	}()
+	wg.Wait()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		b.RLock()
+		a.Lock()
+		a.Unlock()
+		b.RUnlock()
+	}()
+	wg.Wait()
+
+	if got := kinds(*reports); got != "lock order inversion" {
+		t.Fatalf("got reports %q", got)
+	}
+	text := (*reports)[0].Text
+	if !strings.Contains(text, lockName(a)) || !strings.Contains(text, lockName(b)) {
+		t.Errorf("report doesn't name locks:\n%s", text)
+	}
+}
+
+func TestTransitiveInversion(t *testing.T) {
+	reports := enable(t)
+	a, b, c := &Mutex{}, &Mutex{}, &Mutex{}
+
+	// a -> b, b -> c, then c -> a closes the cycle
+	a.Lock()
+	b.Lock()
+	b.Unlock()
+	a.Unlock()
+
+	b.Lock()
+	c.Lock()
+	c.Unlock()
+	b.Unlock()
+
+	c.Lock()
+	a.Lock()
+	a.Unlock()
+	c.Unlock()
+
+	if got := kinds(*reports); got != "lock order inversion" {
+		t.Errorf("got reports %q", got)
+	}
+}
+
+func TestConsistentOrder(t *testing.T) {
+	reports := enable(t)
+	a, b := &Mutex{}, &Mutex{}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.Lock()
+			b.Lock()
+			b.Unlock()
+			a.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(*reports) != 0 {
+		t.Errorf("unexpected reports %q", kinds(*reports))
+	}
+}
+
+func TestRecursiveLock(t *testing.T) {
+	reports := enable(t)
+	m := &RWMutex{}
+
+	m.RLock()
+	// doesn't block without a waiting writer, but it's a bug
+	m.RLock()
+	m.RUnlock()
+	m.RUnlock()
+
+	if got := kinds(*reports); got != "recursive lock" {
+		t.Errorf("got reports %q", got)
+	}
+}
+
+func TestLongHold(t *testing.T) {
+	reports := enable(t)
+	SetHoldThreshold(5 * time.Millisecond)
+	m := &Mutex{}
+
+	m.Lock()
+	m.Unlock()
+	m.Lock()
+	time.Sleep(10 * time.Millisecond)
+	m.Unlock()
+
+	if got := kinds(*reports); got != "long hold" {
+		t.Errorf("got reports %q", got)
+	}
+}
+
+func TestDump(t *testing.T) {
+	enable(t)
+	m := &Mutex{}
+	rw := &RWMutex{}
+
+	m.Lock()
+	rw.RLock()
+	b := &strings.Builder{}
+	Dump(b)
+	rw.RUnlock()
+	m.Unlock()
+
+	dump := b.String()
+	if !strings.HasPrefix(dump, "2 locks held") ||
+		!strings.Contains(dump, lockName(m)+" (write)") ||
+		!strings.Contains(dump, lockName(rw)+" (read)") {
+		t.Errorf("unexpected dump:\n%s", dump)
+	}
+
+	b.Reset()
+	Dump(b)
+	if !strings.HasPrefix(b.String(), "0 locks held") {
+		t.Errorf("unexpected dump after unlock:\n%s", b.String())
+	}
+}
+
+func TestReporterUsesLocks(t *testing.T) {
+	enable(t)
+	SetHoldThreshold(time.Millisecond)
+	m := &Mutex{}
+	reporterMu := &Mutex{}
+	var dumps []string
+	SetReporter(func(r Report) {
+		reporterMu.Lock()
+		defer reporterMu.Unlock()
+		b := &strings.Builder{}
+		Dump(b)
+		dumps = append(dumps, b.String())
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Lock()
+		time.Sleep(5 * time.Millisecond)
+		m.Unlock()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reporter deadlocked")
+	}
+	reporterMu.Lock()
+	defer reporterMu.Unlock()
+	if len(dumps) != 1 || !strings.HasPrefix(dumps[0], "1 locks held") {
+		t.Errorf("unexpected dumps %q", dumps)
+	}
+}
+
+func TestUnlockFromOtherGoroutine(t *testing.T) {
+	enable(t)
+	m := &Mutex{}
+	m.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Unlock()
+	}()
+	<-done
+
+	b := &strings.Builder{}
+	Dump(b)
+	if !strings.HasPrefix(b.String(), "0 locks held") {
+		t.Errorf("lock is still registered:\n%s", b.String())
+	}
+}
+
+func BenchmarkMutexDisabled(b *testing.B) {
+	m := &Mutex{}
+	for i := 0; i < b.N; i++ {
+		m.Lock()
+		m.Unlock()
+	}
+}
+
+func BenchmarkSyncMutex(b *testing.B) {
+	m := &sync.Mutex{}
+	for i := 0; i < b.N; i++ {
+		m.Lock()
+		m.Unlock()
+	}
+}
@@ -0,0 +1,290 @@
+package lockdebug
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type mode int
+
+const (
+	modeWrite mode = iota
+	modeRead
+)
+
+func (m mode) String() string {
+	if m == modeRead {
+		return "read"
+	}
+	return "write"
+}
+
+// Report is a found problem
+type Report struct {
+	// Kind is one of "lock order inversion", "recursive lock", "long hold"
+	Kind string
+	Text string
+}
+
+// held is a lock taken by goroutine
+type held struct {
+	lock  interface{}
+	mode  mode
+	gid   uint64
+	since time.Time
+	stack []uintptr
+}
+
+// edge means {to} was locked while {from} was held
+type edge struct {
+	fromStack, toStack []uintptr
+	gid                uint64
+}
+
+type lockRegistry struct {
+	mu      sync.Mutex
+	holders map[uint64][]*held
+	// after keeps lock order graph, it holds references to all
+	// locks ever taken together, so use it only for debugging
+	after         map[interface{}]map[interface{}]*edge
+	reported      map[[2]interface{}]bool
+	holdThreshold time.Duration
+	reporter      func(Report)
+}
+
+var registry = newRegistry()
+
+func newRegistry() *lockRegistry {
+	return &lockRegistry{
+		holders:       map[uint64][]*held{},
+		after:         map[interface{}]map[interface{}]*edge{},
+		reported:      map[[2]interface{}]bool{},
+		holdThreshold: defaultHoldThreshold,
+		reporter: func(r Report) {
+			fmt.Fprintf(os.Stderr, "lockdebug: %s\n%s\n", r.Kind, r.Text)
+		},
+	}
+}
+
+// SetHoldThreshold sets duration after which lock hold is reported on unlock
+func SetHoldThreshold(d time.Duration) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.holdThreshold = d
+}
+
+// SetReporter replaces default reporter which writes to stderr.
+// Reporter is called without internal locks held, possibly
+// from several goroutines at once
+func SetReporter(reporter func(Report)) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.reporter = reporter
+}
+
+func (r *lockRegistry) newHeld(lock interface{}, m mode) *held {
+	return &held{lock: lock, mode: m, gid: goroutineID(), stack: callers()}
+}
+
+// beforeLock checks lock order against locks held by current goroutine
+// and returns holder record to register after lock is acquired
+func (r *lockRegistry) beforeLock(lock interface{}, m mode) *held {
+	h := r.newHeld(lock, m)
+
+	var reports []Report
+	defer func() { r.deliver(reports) }()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, other := range r.holders[h.gid] {
+		if other.lock == lock {
+			reports = append(reports, newReport("recursive lock", fmt.Sprintf(
+				"goroutine %d locks %s (%s) which it already holds (%s)\n%s\nlocked at:\n%s",
+				h.gid, lockName(lock), m, other.mode, formatStack(h.stack), formatStack(other.stack))))
+			continue
+		}
+		// previously someone took other.lock while holding lock
+		if prev := r.path(lock, other.lock); prev != nil {
+			key := [2]interface{}{other.lock, lock}
+			if !r.reported[key] {
+				r.reported[key] = true
+				reports = append(reports, newReport("lock order inversion", fmt.Sprintf(
+					"goroutine %d locks %s while holding %s\n%s\n%s was locked at:\n%s\n"+
+						"earlier goroutine %d locked %s while holding %s\n%s\n%s was locked at:\n%s",
+					h.gid, lockName(lock), lockName(other.lock), formatStack(h.stack),
+					lockName(other.lock), formatStack(other.stack),
+					prev.gid, lockName(other.lock), lockName(lock), formatStack(prev.toStack),
+					lockName(lock), formatStack(prev.fromStack))))
+			}
+		}
+		if r.after[other.lock] == nil {
+			r.after[other.lock] = map[interface{}]*edge{}
+		}
+		if r.after[other.lock][lock] == nil {
+			r.after[other.lock][lock] = &edge{fromStack: other.stack, toStack: h.stack, gid: h.gid}
+		}
+	}
+	return h
+}
+
+// path returns the last edge of a path {from} -> ... -> {to}
+// in lock order graph, nil if there is no path
+func (r *lockRegistry) path(from, to interface{}) *edge {
+	visited := map[interface{}]bool{from: true}
+	queue := []interface{}{from}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for next, e := range r.after[cur] {
+			if next == to {
+				return e
+			}
+			if !visited[next] {
+				visited[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *lockRegistry) afterLock(h *held) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h.since = time.Now()
+	r.holders[h.gid] = append(r.holders[h.gid], h)
+}
+
+func (r *lockRegistry) unlock(lock interface{}, m mode) {
+	gid := goroutineID()
+
+	var reports []Report
+	defer func() { r.deliver(reports) }()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h := r.remove(gid, lock, m)
+	if h == nil {
+		// Mutex may be unlocked by another goroutine
+		for other := range r.holders {
+			if h = r.remove(other, lock, m); h != nil {
+				break
+			}
+		}
+	}
+	if h == nil {
+		return
+	}
+	if d := time.Since(h.since); r.holdThreshold > 0 && d > r.holdThreshold {
+		reports = append(reports, newReport("long hold", fmt.Sprintf(
+			"goroutine %d held %s (%s) for %v, threshold %v\nlocked at:\n%s",
+			h.gid, lockName(lock), m, d, r.holdThreshold, formatStack(h.stack))))
+	}
+}
+
+// remove deletes the latest record of {lock} held by goroutine {gid}
+func (r *lockRegistry) remove(gid uint64, lock interface{}, m mode) *held {
+	list := r.holders[gid]
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i].lock == lock && list[i].mode == m {
+			h := list[i]
+			list = append(list[:i], list[i+1:]...)
+			if len(list) == 0 {
+				delete(r.holders, gid)
+			} else {
+				r.holders[gid] = list
+			}
+			return h
+		}
+	}
+	return nil
+}
+
+func newReport(kind, text string) Report {
+	return Report{Kind: kind, Text: text}
+}
+
+// deliver passes {reports} to reporter, r.mu should not be held
+// so reporter may use Dump or lockdebug mutexes itself
+func (r *lockRegistry) deliver(reports []Report) {
+	if len(reports) == 0 {
+		return
+	}
+	r.mu.Lock()
+	reporter := r.reporter
+	r.mu.Unlock()
+	if reporter == nil {
+		return
+	}
+	for _, report := range reports {
+		reporter(report)
+	}
+}
+
+// Dump writes all currently held locks with their acquisition stacks
+func Dump(w io.Writer) {
+	registry.mu.Lock()
+	var all []*held
+	for _, list := range registry.holders {
+		all = append(all, list...)
+	}
+	registry.mu.Unlock()
+
+	sort.Slice(all, func(i, j int) bool { return all[i].since.Before(all[j].since) })
+	fmt.Fprintf(w, "%d locks held\n", len(all))
+	for _, h := range all {
+		fmt.Fprintf(w, "\n%s (%s) held by goroutine %d for %v\n%s\n",
+			lockName(h.lock), h.mode, h.gid, time.Since(h.since).Round(time.Microsecond), formatStack(h.stack))
+	}
+}
+
+func lockName(lock interface{}) string {
+	return fmt.Sprintf("%T(%p)", lock, lock)
+}
+
+var pkgPath = reflect.TypeOf(Mutex{}).PkgPath()
+
+func callers() []uintptr {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	return pcs[:n]
+}
+
+// formatStack formats frames outside of this package
+func formatStack(pcs []uintptr) string {
+	var b strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, pkgPath+".") {
+			fmt.Fprintf(&b, "\t%s\n\t\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		}
+		if !more {
+			break
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// goroutineID parses current goroutine id from the stack header
+// "goroutine 123 [running]:"
+func goroutineID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	header := bytes.TrimPrefix(buf[:n], []byte("goroutine "))
+	if i := bytes.IndexByte(header, ' '); i > 0 {
+		header = header[:i]
+	}
+	id, _ := strconv.ParseUint(string(header), 10, 64)
+	return id
+}
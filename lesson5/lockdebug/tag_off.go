@@ -0,0 +1,5 @@
+//go:build !lockdebug
+
+package lockdebug
+
+const forceEnabled = false
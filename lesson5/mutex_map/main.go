@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"math/rand"
 	"sync"
+
+	"geekbrains/examples/lesson5/lockdebug"
 )
 
 var (
 	globalMap   = map[int]int{}
-	globalMapMu = sync.Mutex{}
+	globalMapMu = lockdebug.Mutex{}
 )
 
 func main() {
@@ -0,0 +1,425 @@
+// Package respool keeps a pool of reusable resources, e.g. connections
+//
+//	p := respool.New(dial, respool.Options[*Conn]{MaxOpen: 10, IdleTimeout: time.Minute})
+//	defer p.Close()
+//	r, err := p.Acquire(ctx)
+//	...
+//	r.Release()
+package respool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned on acquire from closed pool
+var ErrClosed = errors.New("resource pool is closed")
+
+// Factory creates a new resource
+type Factory[T any] func(ctx context.Context) (T, error)
+
+type Options[T any] struct {
+	// Validate checks idle resource on borrow,
+	// failed resource is closed and another one is taken
+	Validate func(ctx context.Context, v T) error
+	// Close releases a resource dropped by the pool
+	Close func(v T) error
+
+	// MinIdle resources are kept open in background
+	MinIdle int
+	// MaxOpen limits resources open at once, 0 means no limit.
+	// Acquire waits while all of them are in use
+	MaxOpen int
+	// MaxLifetime and IdleTimeout expire resources, 0 means never
+	MaxLifetime time.Duration
+	IdleTimeout time.Duration
+	// MaintainInterval is period of expiration and MinIdle checks, default is 1s
+	MaintainInterval time.Duration
+}
+
+// Stats is a snapshot of pool counters
+type Stats struct {
+	// Open counts idle, used and being created resources
+	Open     int
+	Idle     int
+	InUse    int
+	Creating int
+	// Waits counts Acquire calls waited for a free resource
+	Waits        int64
+	WaitDuration time.Duration
+	Created      int64
+	// Destroyed counts closed resources: expired, failed validation,
+	// discarded and closed with the pool
+	Destroyed          int64
+	ValidationFailures int64
+}
+
+// Resource is a borrowed value, it must be returned by Release or Discard.
+// Each Acquire returns a new Resource, so a holder can't return
+// the value again after it is passed to someone else
+type Resource[T any] struct {
+	item     *item[T]
+	pool     *Pool[T]
+	released bool
+}
+
+// item is a pooled value, it outlives borrows
+type item[T any] struct {
+	value    T
+	created  time.Time
+	returned time.Time
+}
+
+func (r *Resource[T]) Value() T {
+	return r.item.value
+}
+
+// Release returns resource to the pool
+func (r *Resource[T]) Release() {
+	r.pool.put(r, false)
+}
+
+// Discard closes broken resource instead of returning it to the pool
+func (r *Resource[T]) Discard() {
+	r.pool.put(r, true)
+}
+
+type Pool[T any] struct {
+	factory Factory[T]
+	opts    Options[T]
+
+	mx sync.Mutex
+	// idle is a stack, the most recently used resource goes first
+	idle []*item[T]
+	open int
+	// creating counts slots in open taken by factory calls
+	creating int
+	// waiters get an item or nil, which means they may create one
+	waiters []chan *item[T]
+	closed  bool
+	stats   Stats
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates pool and starts its background maintenance
+func New[T any](factory Factory[T], opts Options[T]) *Pool[T] {
+	if opts.MaintainInterval <= 0 {
+		opts.MaintainInterval = time.Second
+	}
+	if opts.MaxOpen > 0 {
+		opts.MinIdle = min(opts.MinIdle, opts.MaxOpen)
+	}
+	p := &Pool[T]{
+		factory: factory,
+		opts:    opts,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go p.maintain()
+	return p
+}
+
+// Acquire borrows an idle resource or creates a new one.
+// It waits while MaxOpen resources are in use until ctx is done
+func (p *Pool[T]) Acquire(ctx context.Context) (*Resource[T], error) {
+	waited := false
+	for {
+		p.mx.Lock()
+		if p.closed {
+			p.mx.Unlock()
+			return nil, ErrClosed
+		}
+
+		if n := len(p.idle); n > 0 {
+			it := p.idle[n-1]
+			p.idle = p.idle[:n-1]
+			p.mx.Unlock()
+
+			if p.check(ctx, it) {
+				return p.lend(it), nil
+			}
+			continue
+		}
+
+		if p.opts.MaxOpen <= 0 || p.open < p.opts.MaxOpen {
+			p.open++
+			p.creating++
+			p.mx.Unlock()
+			return p.create(ctx)
+		}
+
+		if !waited {
+			waited = true
+			p.stats.Waits++
+		}
+		it, err := p.wait(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if it != nil && p.check(ctx, it) {
+			return p.lend(it), nil
+		}
+	}
+}
+
+// wait queues caller until a resource is handed over or a slot is freed,
+// p.mx should be held, it is released
+func (p *Pool[T]) wait(ctx context.Context) (*item[T], error) {
+	req := make(chan *item[T], 1)
+	p.waiters = append(p.waiters, req)
+	p.mx.Unlock()
+
+	start := time.Now()
+	defer func() {
+		p.mx.Lock()
+		p.stats.WaitDuration += time.Since(start)
+		p.mx.Unlock()
+	}()
+
+	select {
+	case it := <-req:
+		return it, nil
+	case <-ctx.Done():
+	}
+
+	p.mx.Lock()
+	for i, w := range p.waiters {
+		if w == req {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			p.mx.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+	p.mx.Unlock()
+
+	// handed over at the same moment, pass it on
+	if it := <-req; it != nil {
+		p.lend(it).Release()
+	} else {
+		p.wakeOne()
+	}
+	return nil, ctx.Err()
+}
+
+// create opens new resource, the slot in p.open is already taken
+// and counted in p.creating
+func (p *Pool[T]) create(ctx context.Context) (*Resource[T], error) {
+	v, err := p.factory(ctx)
+	if err != nil {
+		p.mx.Lock()
+		p.open--
+		p.creating--
+		p.mx.Unlock()
+		p.wakeOne()
+		return nil, err
+	}
+	now := time.Now()
+
+	p.mx.Lock()
+	p.creating--
+	p.stats.Created++
+	p.mx.Unlock()
+	return p.lend(&item[T]{value: v, created: now, returned: now}), nil
+}
+
+// lend wraps {it} into a new borrow
+func (p *Pool[T]) lend(it *item[T]) *Resource[T] {
+	return &Resource[T]{item: it, pool: p}
+}
+
+// check validates borrowed resource and destroys it on failure
+func (p *Pool[T]) check(ctx context.Context, it *item[T]) bool {
+	if p.expired(it, time.Now()) {
+		p.destroy(it)
+		return false
+	}
+	if p.opts.Validate == nil {
+		return true
+	}
+	if err := p.opts.Validate(ctx, it.value); err != nil {
+		p.mx.Lock()
+		p.stats.ValidationFailures++
+		p.mx.Unlock()
+		p.destroy(it)
+		return false
+	}
+	return true
+}
+
+func (p *Pool[T]) expired(it *item[T], now time.Time) bool {
+	if p.opts.MaxLifetime > 0 && now.Sub(it.created) >= p.opts.MaxLifetime {
+		return true
+	}
+	return p.opts.IdleTimeout > 0 && now.Sub(it.returned) >= p.opts.IdleTimeout
+}
+
+// destroy closes resource and frees its slot for a waiter
+func (p *Pool[T]) destroy(it *item[T]) {
+	p.mx.Lock()
+	p.open--
+	p.stats.Destroyed++
+	p.mx.Unlock()
+
+	p.closeValue(it)
+	p.wakeOne()
+}
+
+func (p *Pool[T]) closeValue(it *item[T]) {
+	if p.opts.Close != nil {
+		p.opts.Close(it.value)
+	}
+}
+
+// wakeOne lets the first waiter create a resource in the free slot
+func (p *Pool[T]) wakeOne() {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if len(p.waiters) > 0 {
+		req := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		req <- nil
+	}
+}
+
+func (p *Pool[T]) put(r *Resource[T], discard bool) {
+	p.mx.Lock()
+	if r.released {
+		p.mx.Unlock()
+		panic("respool: resource released twice")
+	}
+	r.released = true
+	it := r.item
+	it.returned = time.Now()
+	if discard || p.closed || p.expired(it, it.returned) {
+		p.mx.Unlock()
+		p.destroy(it)
+		return
+	}
+	if len(p.waiters) > 0 {
+		// hand over directly, it stays in use
+		req := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		p.mx.Unlock()
+		req <- it
+		return
+	}
+	p.idle = append(p.idle, it)
+	p.mx.Unlock()
+}
+
+// maintain drops expired idle resources and keeps MinIdle open
+func (p *Pool[T]) maintain() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.opts.MaintainInterval)
+	defer ticker.Stop()
+	for {
+		p.dropExpired()
+		p.fillIdle()
+
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Pool[T]) dropExpired() {
+	now := time.Now()
+
+	p.mx.Lock()
+	var expired []*item[T]
+	kept := p.idle[:0]
+	for _, it := range p.idle {
+		if p.expired(it, now) {
+			expired = append(expired, it)
+		} else {
+			kept = append(kept, it)
+		}
+	}
+	clear(p.idle[len(kept):])
+	p.idle = kept
+	p.open -= len(expired)
+	p.stats.Destroyed += int64(len(expired))
+	p.mx.Unlock()
+
+	for _, it := range expired {
+		p.closeValue(it)
+	}
+}
+
+func (p *Pool[T]) fillIdle() {
+	for {
+		p.mx.Lock()
+		if p.closed || len(p.idle) >= p.opts.MinIdle ||
+			(p.opts.MaxOpen > 0 && p.open >= p.opts.MaxOpen) {
+			p.mx.Unlock()
+			return
+		}
+		p.open++
+		p.creating++
+		p.mx.Unlock()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			// creation is aborted by Close
+			select {
+			case <-p.stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		r, err := p.create(ctx)
+		cancel()
+		if err != nil {
+			return
+		}
+		r.Release()
+	}
+}
+
+// Stats returns current counters
+func (p *Pool[T]) Stats() Stats {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	st := p.stats
+	st.Open = p.open
+	st.Idle = len(p.idle)
+	st.Creating = p.creating
+	st.InUse = p.open - len(p.idle) - p.creating
+	return st
+}
+
+// Close closes idle resources and fails waiting Acquire calls.
+// Resources in use are closed on return
+func (p *Pool[T]) Close() {
+	p.mx.Lock()
+	if p.closed {
+		p.mx.Unlock()
+		return
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.open -= len(idle)
+	p.stats.Destroyed += int64(len(idle))
+	waiters := p.waiters
+	p.waiters = nil
+	p.mx.Unlock()
+
+	close(p.stop)
+	for _, it := range idle {
+		p.closeValue(it)
+	}
+	for _, req := range waiters {
+		req <- nil
+	}
+	<-p.done
+}
@@ -0,0 +1,422 @@
+package respool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeConn is an in-memory resource
+type fakeConn struct {
+	id     int
+	broken atomic.Bool
+	closed atomic.Bool
+}
+
+type fakeServer struct {
+	mx    sync.Mutex
+	conns []*fakeConn
+	fail  error
+}
+
+func (s *fakeServer) dial(ctx context.Context) (*fakeConn, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.fail != nil {
+		return nil, s.fail
+	}
+	c := &fakeConn{id: len(s.conns) + 1}
+	s.conns = append(s.conns, c)
+	return c, nil
+}
+
+// live counts not closed connections
+func (s *fakeServer) live() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	n := 0
+	for _, c := range s.conns {
+		if !c.closed.Load() {
+			n++
+		}
+	}
+	return n
+}
+
+func newPool(s *fakeServer, opts Options[*fakeConn]) *Pool[*fakeConn] {
+	opts.Validate = func(_ context.Context, c *fakeConn) error {
+		if c.broken.Load() {
+			return errors.New("broken")
+		}
+		return nil
+	}
+	opts.Close = func(c *fakeConn) error {
+		if c.closed.Swap(true) {
+			panic("closed twice")
+		}
+		return nil
+	}
+	return New(s.dial, opts)
+}
+
+func TestReuse(t *testing.T) {
+	s := &fakeServer{}
+	p := newPool(s, Options[*fakeConn]{})
+	defer p.Close()
+
+	ctx := context.Background()
+	r, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Release()
+	r2, _ := p.Acquire(ctx)
+	if r2.Value() != r.Value() {
+		t.Error("idle resource is not reused")
+	}
+	r2.Release()
+
+	st := p.Stats()
+	if st.Created != 1 || st.Open != 1 || st.Idle != 1 || st.InUse != 0 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestValidateOnBorrow(t *testing.T) {
+	s := &fakeServer{}
+	p := newPool(s, Options[*fakeConn]{})
+	defer p.Close()
+
+	r, _ := p.Acquire(context.Background())
+	first := r.Value()
+	r.Release()
+	first.broken.Store(true)
+
+	r, _ = p.Acquire(context.Background())
+	if r.Value() == first {
+		t.Fatal("got broken resource")
+	}
+	if !first.closed.Load() {
+		t.Error("broken resource is not closed")
+	}
+	r.Release()
+	if st := p.Stats(); st.ValidationFailures != 1 || st.Destroyed != 1 || st.Open != 1 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestMaxOpenWait(t *testing.T) {
+	s := &fakeServer{}
+	p := newPool(s, Options[*fakeConn]{MaxOpen: 2})
+	defer p.Close()
+
+	ctx := context.Background()
+	r1, _ := p.Acquire(ctx)
+	r2, _ := p.Acquire(ctx)
+
+	got := make(chan *Resource[*fakeConn])
+	go func() {
+		r, err := p.Acquire(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		got <- r
+	}()
+
+	select {
+	case <-got:
+		t.Fatal("acquired over MaxOpen")
+	case <-time.After(20 * time.Millisecond):
+	}
+	r1.Release()
+	r3 := <-got
+	if r3.Value() != r1.Value() {
+		t.Error("released resource is not handed over")
+	}
+	r2.Release()
+	r3.Release()
+
+	st := p.Stats()
+	if st.Waits != 1 || st.WaitDuration < 20*time.Millisecond || st.Created != 2 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestReleaseTwice(t *testing.T) {
+	s := &fakeServer{}
+	p := newPool(s, Options[*fakeConn]{MaxOpen: 1})
+	defer p.Close()
+
+	releasePanics := func(name string, r *Resource[*fakeConn]) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%s: second release doesn't panic", name)
+			}
+		}()
+		r.Release()
+	}
+
+	ctx := context.Background()
+	r1, _ := p.Acquire(ctx)
+	got := make(chan *Resource[*fakeConn])
+	go func() {
+		r, _ := p.Acquire(ctx)
+		got <- r
+	}()
+	waitFor(t, func() bool { return p.Stats().Waits == 1 })
+	r1.Release()
+	r2 := <-got
+	// r2 holds the handed over value, r1 is stale
+	releasePanics("handed over", r1)
+	r2.Release()
+
+	r3, _ := p.Acquire(ctx)
+	releasePanics("reused idle", r2)
+	r3.Release()
+	if st := p.Stats(); st.Idle != 1 || st.InUse != 0 || st.Created != 1 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestAcquireCtx(t *testing.T) {
+	s := &fakeServer{}
+	p := newPool(s, Options[*fakeConn]{MaxOpen: 1})
+	defer p.Close()
+
+	r, _ := p.Acquire(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Acquire(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline, got %v", err)
+	}
+
+	// discarded resource frees the slot
+	r.Discard()
+	r, err := p.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Value().id != 2 {
+		t.Errorf("expected new resource, got %d", r.Value().id)
+	}
+	r.Release()
+}
+
+func TestFactoryError(t *testing.T) {
+	errDown := errors.New("down")
+	s := &fakeServer{fail: errDown}
+	p := newPool(s, Options[*fakeConn]{MaxOpen: 1})
+	defer p.Close()
+
+	if _, err := p.Acquire(context.Background()); err != errDown {
+		t.Fatalf("expected errDown, got %v", err)
+	}
+	if st := p.Stats(); st.Open != 0 {
+		t.Errorf("failed create holds a slot: %+v", st)
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	s := &fakeServer{}
+	p := newPool(s, Options[*fakeConn]{
+		MaxLifetime:      50 * time.Millisecond,
+		IdleTimeout:      20 * time.Millisecond,
+		MaintainInterval: 5 * time.Millisecond,
+	})
+	defer p.Close()
+
+	r, _ := p.Acquire(context.Background())
+	r.Release()
+	time.Sleep(40 * time.Millisecond)
+	if st := p.Stats(); st.Open != 0 || s.live() != 0 {
+		t.Errorf("idle resource is not expired: %+v", st)
+	}
+
+	// lifetime is checked on release
+	r, _ = p.Acquire(context.Background())
+	c := r.Value()
+	time.Sleep(60 * time.Millisecond)
+	r.Release()
+	if !c.closed.Load() {
+		t.Error("resource outlived MaxLifetime")
+	}
+}
+
+func TestMinIdle(t *testing.T) {
+	s := &fakeServer{}
+	p := newPool(s, Options[*fakeConn]{
+		MinIdle:          3,
+		MaxOpen:          4,
+		MaintainInterval: 5 * time.Millisecond,
+	})
+
+	waitFor(t, func() bool { return p.Stats().Idle == 3 })
+
+	ctx := context.Background()
+	r1, _ := p.Acquire(ctx)
+	r2, _ := p.Acquire(ctx)
+	// only one more fits into MaxOpen
+	waitFor(t, func() bool { return p.Stats().Open == 4 })
+	time.Sleep(20 * time.Millisecond)
+	if st := p.Stats(); st.Open != 4 || st.Idle != 2 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+	r1.Release()
+	r2.Release()
+
+	p.Close()
+	r, err := p.Acquire(ctx)
+	if err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v %v", r, err)
+	}
+	if n := s.live(); n != 0 {
+		t.Errorf("%d resources left open", n)
+	}
+}
+
+func TestCloseWakesWaiters(t *testing.T) {
+	s := &fakeServer{}
+	p := newPool(s, Options[*fakeConn]{MaxOpen: 1})
+
+	r, _ := p.Acquire(context.Background())
+	errs := make(chan error)
+	go func() {
+		_, err := p.Acquire(context.Background())
+		errs <- err
+	}()
+	waitFor(t, func() bool { return p.Stats().Waits == 1 })
+	p.Close()
+
+	if err := <-errs; err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	r.Release()
+	if n := s.live(); n != 0 {
+		t.Errorf("%d resources left open", n)
+	}
+}
+
+func TestStatsCounts(t *testing.T) {
+	s := &fakeServer{}
+	p := newPool(s, Options[*fakeConn]{MaxOpen: 1})
+	defer p.Close()
+
+	r1, _ := p.Acquire(context.Background())
+	got := make(chan *Resource[*fakeConn])
+	go func() {
+		r, _ := p.Acquire(context.Background())
+		got <- r
+	}()
+	waiters := func() int {
+		p.mx.Lock()
+		defer p.mx.Unlock()
+		return len(p.waiters)
+	}
+	waitFor(t, func() bool { return waiters() == 1 })
+
+	// wake the waiter as if a freed slot was taken
+	// by another Acquire before it, so it queues again
+	p.mx.Lock()
+	req := p.waiters[0]
+	p.waiters = p.waiters[1:]
+	p.mx.Unlock()
+	req <- nil
+	waitFor(t, func() bool { return waiters() == 1 })
+
+	r1.Release()
+	(<-got).Release()
+	if st := p.Stats(); st.Waits != 1 {
+		t.Errorf("one waiter counted %d times", st.Waits)
+	}
+
+	// resource being created isn't in use yet
+	gate := make(chan struct{})
+	slow := New(func(ctx context.Context) (int, error) {
+		<-gate
+		return 1, nil
+	}, Options[int]{})
+	defer slow.Close()
+	created := make(chan *Resource[int])
+	go func() {
+		r, _ := slow.Acquire(context.Background())
+		created <- r
+	}()
+	waitFor(t, func() bool { return slow.Stats().Creating == 1 })
+	if st := slow.Stats(); st.InUse != 0 || st.Open != 1 {
+		t.Errorf("unexpected stats while creating %+v", st)
+	}
+	close(gate)
+	r := <-created
+	if st := slow.Stats(); st.InUse != 1 || st.Creating != 0 {
+		t.Errorf("unexpected stats after creating %+v", st)
+	}
+	r.Release()
+}
+
+func TestConcurrent(t *testing.T) {
+	const maxOpen = 4
+	s := &fakeServer{}
+	p := newPool(s, Options[*fakeConn]{MaxOpen: maxOpen, MinIdle: 1, MaintainInterval: time.Millisecond})
+
+	var inUse, peak int32
+	wg := sync.WaitGroup{}
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Duration(i%5)*time.Millisecond)
+				r, err := p.Acquire(ctx)
+				cancel()
+				if err != nil {
+					continue
+				}
+				n := atomic.AddInt32(&inUse, 1)
+				for {
+					cur := atomic.LoadInt32(&peak)
+					if n <= cur || atomic.CompareAndSwapInt32(&peak, cur, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Microsecond)
+				atomic.AddInt32(&inUse, -1)
+				if i%17 == 0 {
+					r.Discard()
+				} else {
+					r.Release()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if peak > maxOpen {
+		t.Errorf("%d resources in use, MaxOpen %d", peak, maxOpen)
+	}
+	// maintenance may be creating an idle one right now
+	waitFor(t, func() bool {
+		st := p.Stats()
+		return st.InUse == 0 && st.Open <= maxOpen && st.Open == s.live()
+	})
+	p.Close()
+	if n := s.live(); n != 0 {
+		t.Errorf("%d resources left open", n)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition is not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"math/rand"
 	"sync"
+
+	"geekbrains/examples/lesson5/lockdebug"
 )
 
 var (
-	mu = sync.RWMutex{}
+	mu = lockdebug.RWMutex{}
 )
 
 func main() {
@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"math/rand"
 	"sync"
+
+	"geekbrains/examples/lesson5/lockdebug"
 )
 
 var (
 	globalMap   = map[int]int{}
-	globalMapMu = sync.RWMutex{}
+	globalMapMu = lockdebug.RWMutex{}
 )
 
 func main() {
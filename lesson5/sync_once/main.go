@@ -1,37 +1,134 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"geekbrains/examples/lesson5/lazy"
+	"geekbrains/examples/lesson5/respool"
 )
 
+var errBroken = errors.New("connection is broken")
+
+type conn struct {
+	id     int32
+	broken atomic.Bool
+}
+
+// Connector resolves server address on the first query and
+// keeps a pool of connections, so queries don't share one.
+// sync.Once can't retry a failed resolve, lazy.Lazy can
 type Connector struct {
-	connection string
-	init       sync.Once
+	pool  *lazy.Lazy[*respool.Pool[*conn]]
+	dials atomic.Int32
+}
+
+func NewConnector() *Connector {
+	c := &Connector{}
+	var resolves int32
+	c.pool = lazy.New(func(ctx context.Context) (*respool.Pool[*conn], error) {
+		n := atomic.AddInt32(&resolves, 1)
+		fmt.Println("resolving server address, attempt", n)
+		// first resolve fails to show retry
+		if n == 1 {
+			return nil, errors.New("no such host")
+		}
+		return respool.New(c.dial, respool.Options[*conn]{
+			Validate: func(_ context.Context, cn *conn) error {
+				if cn.broken.Load() {
+					return errBroken
+				}
+				return nil
+			},
+			MaxOpen:     3,
+			IdleTimeout: time.Minute,
+		}), nil
+	}, lazy.Options{Backoff: 10 * time.Millisecond, Timeout: time.Second})
+	return c
+}
+
+func (c *Connector) dial(ctx context.Context) (*conn, error) {
+	cn := &conn{id: c.dials.Add(1)}
+	fmt.Println("establishing connection", cn.id)
+	return cn, nil
+}
+
+func (c *Connector) DoQuery(ctx context.Context, query string) error {
+	pool, gen, err := c.pool.GetGen(ctx)
+	if err != nil {
+		return err
+	}
+	r, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	if err := exec(r.Value(), query); err != nil {
+		r.Discard()
+		if errors.Is(err, errBroken) && c.pool.Reset(gen) {
+			// server is gone, the next query resolves it again,
+			// queries which got the same pool don't reset the new one
+			fmt.Printf("pool stats: %+v\n", pool.Stats())
+			pool.Close()
+		}
+		return err
+	}
+	r.Release()
+	return nil
 }
 
-func (c *Connector) DoQuery(query string) {
-	c.init.Do(func() {
-		fmt.Println("establishing connection")
-		c.connection = "connected"
-	})
+func exec(cn *conn, query string) error {
+	if query == "break" {
+		cn.broken.Store(true)
+		return errBroken
+	}
+	time.Sleep(time.Millisecond)
+	fmt.Println("do query", query, "via connection", cn.id)
+	return nil
+}
 
-	fmt.Println("do query", query)
+func (c *Connector) Close() {
+	if pool, ok := c.pool.Peek(); ok {
+		fmt.Printf("pool stats: %+v\n", pool.Stats())
+		pool.Close()
+	}
 }
 
 func main() {
-	conn := Connector{}
-	wg := sync.WaitGroup{}
+	conn := NewConnector()
+	defer conn.Close()
 
-	for i := 0; i < 10; i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			conn.DoQuery(fmt.Sprint("i'm goroutine number", i))
-		}(i)
+	query := func(q string) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		if err := conn.DoQuery(ctx, q); err != nil {
+			fmt.Println("query failed:", err)
+		}
+	}
+
+	// the first query fails on resolve,
+	// the next ones retry it after backoff
+	query("ping")
+
+	wg := sync.WaitGroup{}
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				query(fmt.Sprint("i'm goroutine number ", i))
+			}(i)
+		}
+		// the second round reuses connections
+		wg.Wait()
 	}
 
-	wg.Wait()
+	query("break")
+	query("select 1")
 
 	fmt.Println("main done")
 }
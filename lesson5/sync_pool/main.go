@@ -2,8 +2,11 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"strings"
 	"sync"
+
+	"geekbrains/examples/lesson5/bufpool"
 )
 
 func main() {
@@ -30,5 +33,32 @@ func main() {
 
 	wg.Wait()
 
+	// sync.Pool above keeps whatever it is given, a builder grown
+	// to megabytes stays pinned and serves tiny writes.
+	// bufpool keeps buffers by size classes and drops too large ones
+	bp := bufpool.New(bufpool.Options{MaxSize: 1 << 20, Debug: true})
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			buf := bp.GetBuffer(64)
+			fmt.Fprintf(buf, "i'm goroutine number %d", i)
+			fmt.Println(buf.String())
+
+			if i == 7 {
+				// forgot to return it, debug mode will tell
+				return
+			}
+			bp.PutBuffer(buf)
+		}(i)
+	}
+	wg.Wait()
+
+	huge := bp.Get(16 << 20)
+	bp.Put(huge) // not retained
+
+	fmt.Printf("pool stats: %+v\n", bp.Stats())
+	bp.WriteLeaks(os.Stdout, 0)
+
 	fmt.Println("main done")
 }
@@ -0,0 +1,164 @@
+// Package health checks hosts periodically with pluggable
+// probes and keeps their results and up/down transitions
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Target is a host checked by Checker
+type Target struct {
+	// Name should be unique
+	Name  string
+	Probe Probe
+	// Interval between checks and Timeout of one check,
+	// zero values are taken from Options
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+type Options struct {
+	// Concurrency limits checks running at once, 0 means no limit
+	Concurrency int
+	Interval    time.Duration
+	Timeout     time.Duration
+}
+
+// ErrDuplicate is returned on adding target with existing name
+var ErrDuplicate = errors.New("health: duplicate target")
+
+// Checker runs probes of targets and records results to Store
+type Checker struct {
+	store *Store
+	opts  Options
+	sem   chan struct{}
+
+	mx      sync.Mutex
+	targets []Target
+	names   map[string]struct{}
+}
+
+func NewChecker(store *Store, opts Options) *Checker {
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Second
+	}
+	c := &Checker{
+		store: store,
+		opts:  opts,
+		names: map[string]struct{}{},
+	}
+	if opts.Concurrency > 0 {
+		c.sem = make(chan struct{}, opts.Concurrency)
+	}
+	return c
+}
+
+// Add registers target, it should be called before Run
+func (c *Checker) Add(t Target) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if _, ok := c.names[t.Name]; ok {
+		return ErrDuplicate
+	}
+	if t.Interval <= 0 {
+		t.Interval = c.opts.Interval
+	}
+	if t.Timeout <= 0 {
+		t.Timeout = c.opts.Timeout
+	}
+	c.names[t.Name] = struct{}{}
+	c.targets = append(c.targets, t)
+	return nil
+}
+
+func (c *Checker) snapshot() []Target {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	return append([]Target(nil), c.targets...)
+}
+
+// Run checks every target at its interval until ctx is done
+func (c *Checker) Run(ctx context.Context) {
+	wg := sync.WaitGroup{}
+	for _, t := range c.snapshot() {
+		wg.Add(1)
+		go func(t Target) {
+			defer wg.Done()
+
+			ticker := time.NewTicker(t.Interval)
+			defer ticker.Stop()
+			for {
+				c.check(ctx, t)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(t)
+	}
+	wg.Wait()
+}
+
+// CheckAll checks every target once and waits for results
+func (c *Checker) CheckAll(ctx context.Context) {
+	wg := sync.WaitGroup{}
+	for _, t := range c.snapshot() {
+		wg.Add(1)
+		go func(t Target) {
+			defer wg.Done()
+			c.check(ctx, t)
+		}(t)
+	}
+	wg.Wait()
+}
+
+// check runs probe of {t} with timeout and records the result,
+// nothing is recorded if ctx is done before the probe started
+func (c *Checker) check(ctx context.Context, t Target) {
+	if c.sem != nil {
+		select {
+		case c.sem <- struct{}{}:
+			defer func() { <-c.sem }()
+		case <-ctx.Done():
+			return
+		}
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := t.Probe.Check(checkCtx)
+	if stopped(ctx) {
+		// not a host failure
+		return
+	}
+	c.store.Record(Result{
+		Target:   t.Name,
+		Time:     start,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+}
+
+// stopped reports if ctx is done or its deadline has passed,
+// probes may fail on the inherited deadline before ctx.Err is set
+func stopped(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && !time.Now().Before(deadline)
+}
@@ -0,0 +1,266 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTCPProbe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	addr := ln.Addr().String()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := (TCPProbe{Addr: addr}).Check(ctx); err != nil {
+		t.Fatalf("expected up, got %v", err)
+	}
+
+	ln.Close()
+	if err := (TCPProbe{Addr: addr}).Check(ctx); err == nil {
+		t.Fatal("expected down after listener closed")
+	}
+}
+
+func TestHTTPProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+		case "/slow":
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		path   string
+		expect int
+		up     bool
+	}{
+		{"/ok", 0, true},
+		{"/created", 0, true},
+		{"/created", http.StatusOK, false},
+		{"/created", http.StatusCreated, true},
+		{"/broken", 0, false},
+		{"/broken", http.StatusServiceUnavailable, true},
+		{"/slow", 0, false},
+	}
+	for _, c := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		err := HTTPProbe{URL: srv.URL + c.path, ExpectStatus: c.expect}.Check(ctx)
+		cancel()
+		if (err == nil) != c.up {
+			t.Errorf("%s expect %d: up %v, got err %v", c.path, c.expect, c.up, err)
+		}
+	}
+}
+
+func TestStoreHistoryAndTransitions(t *testing.T) {
+	s := NewStore(3)
+	var transitions []Transition
+	s.OnTransition(func(tr Transition) { transitions = append(transitions, tr) })
+
+	errDown := errors.New("down")
+	base := time.Now()
+	errs := []error{nil, nil, errDown, errDown, nil}
+	for i, err := range errs {
+		s.Record(Result{Target: "a", Time: base.Add(time.Duration(i) * time.Second), Err: err})
+	}
+
+	hist := s.History("a")
+	if len(hist) != 3 {
+		t.Fatalf("history len %d, expect 3", len(hist))
+	}
+	for i, res := range hist {
+		if expect := base.Add(time.Duration(i+2) * time.Second); !res.Time.Equal(expect) {
+			t.Errorf("history[%d] at %v, expect %v", i, res.Time, expect)
+		}
+	}
+
+	expect := []struct{ from, to State }{{Unknown, Up}, {Up, Down}, {Down, Up}}
+	if len(transitions) != len(expect) {
+		t.Fatalf("got %d transitions, expect %d", len(transitions), len(expect))
+	}
+	for i, e := range expect {
+		if tr := transitions[i]; tr.From != e.from || tr.To != e.to {
+			t.Errorf("transition %d: %v->%v, expect %v->%v", i, tr.From, tr.To, e.from, e.to)
+		}
+	}
+	if transitions[1].Err != errDown {
+		t.Errorf("down transition err %v", transitions[1].Err)
+	}
+
+	st, ok := s.Status("a")
+	if !ok || st.State != Up || st.Checks != 5 || st.Failures != 2 || st.Transitions != 3 {
+		t.Errorf("unexpected status %+v", st)
+	}
+	if !st.Since.Equal(base.Add(4 * time.Second)) {
+		t.Errorf("since %v", st.Since)
+	}
+	if _, ok := s.Status("b"); ok {
+		t.Error("unknown target has status")
+	}
+}
+
+func TestCheckerRun(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	// nobody listens on a closed port
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+
+	store := NewStore(10)
+	c := NewChecker(store, Options{Interval: 10 * time.Millisecond, Timeout: 200 * time.Millisecond})
+	targets := []Target{
+		{Name: "tcp", Probe: TCPProbe{Addr: ln.Addr().String()}},
+		{Name: "closed", Probe: TCPProbe{Addr: closedAddr}},
+		{Name: "http", Probe: HTTPProbe{URL: srv.URL}, Interval: 5 * time.Millisecond},
+	}
+	for _, target := range targets {
+		if err := c.Add(target); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Add(targets[0]); err != ErrDuplicate {
+		t.Fatalf("expected ErrDuplicate, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	c.Run(ctx)
+
+	expect := map[string]State{"tcp": Up, "closed": Down, "http": Up}
+	for _, st := range store.Statuses() {
+		if st.State != expect[st.Target] {
+			t.Errorf("%s is %v, expect %v", st.Target, st.State, expect[st.Target])
+		}
+		if st.Checks < 2 {
+			t.Errorf("%s checked %d times, expect periodic checks", st.Target, st.Checks)
+		}
+		delete(expect, st.Target)
+	}
+	if len(expect) != 0 {
+		t.Errorf("no status for %v", expect)
+	}
+}
+
+func TestCheckerConcurrencyLimit(t *testing.T) {
+	const limit = 3
+	var running, peak int32
+	probe := ProbeFunc(func(ctx context.Context) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	})
+
+	store := NewStore(1)
+	c := NewChecker(store, Options{Concurrency: limit})
+	for i := 0; i < 20; i++ {
+		c.Add(Target{Name: string(rune('a' + i)), Probe: probe})
+	}
+	c.CheckAll(context.Background())
+
+	if peak > limit {
+		t.Errorf("%d probes ran at once, limit %d", peak, limit)
+	}
+	if n := len(store.Statuses()); n != 20 {
+		t.Errorf("got %d statuses, expect 20", n)
+	}
+}
+
+func TestCheckerTimeout(t *testing.T) {
+	store := NewStore(1)
+	c := NewChecker(store, Options{Timeout: 10 * time.Millisecond})
+	c.Add(Target{Name: "hang", Probe: ProbeFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})})
+
+	start := time.Now()
+	c.CheckAll(context.Background())
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("check took %v, timeout ignored", d)
+	}
+	st, _ := store.Status("hang")
+	if st.State != Down || !errors.Is(st.Last.Err, context.DeadlineExceeded) {
+		t.Errorf("unexpected status %+v", st)
+	}
+}
+
+func TestStoreConcurrent(t *testing.T) {
+	s := NewStore(5)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				var err error
+				if j%3 == 0 {
+					err = errors.New("fail")
+				}
+				s.Record(Result{Target: string(rune('a' + i%2)), Err: err})
+				s.History("a")
+				s.Statuses()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, st := range s.Statuses() {
+		if st.Checks != 4000 {
+			t.Errorf("%s: %d checks, expect 4000", st.Target, st.Checks)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// Probe checks one host, nil error means the host is up.
+// Implementations must respect ctx deadline
+type Probe interface {
+	Check(ctx context.Context) error
+}
+
+// ProbeFunc makes Probe from a function
+type ProbeFunc func(ctx context.Context) error
+
+func (f ProbeFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// TCPProbe is up if TCP connection to Addr is accepted
+type TCPProbe struct {
+	Addr string
+}
+
+func (p TCPProbe) Check(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.Addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// HTTPProbe is up if GET of URL returns ExpectStatus,
+// zero ExpectStatus means any 2xx status
+type HTTPProbe struct {
+	URL          string
+	ExpectStatus int
+	// Client is http.DefaultClient if nil
+	Client *http.Client
+}
+
+func (p HTTPProbe) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return err
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	// drain to reuse connection
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	ok := resp.StatusCode/100 == 2
+	if p.ExpectStatus != 0 {
+		ok = resp.StatusCode == p.ExpectStatus
+	}
+	if !ok {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
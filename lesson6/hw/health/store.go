@@ -0,0 +1,178 @@
+package health
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type State int
+
+const (
+	Unknown State = iota // not checked yet
+	Up
+	Down
+)
+
+func (s State) String() string {
+	switch s {
+	case Up:
+		return "up"
+	case Down:
+		return "down"
+	}
+	return "unknown"
+}
+
+// Result is an outcome of one check
+type Result struct {
+	Target   string
+	Time     time.Time
+	Duration time.Duration
+	Err      error
+}
+
+func (r Result) State() State {
+	if r.Err != nil {
+		return Down
+	}
+	return Up
+}
+
+// Transition is a change of target state
+type Transition struct {
+	Target   string
+	Time     time.Time
+	From, To State
+	// Err is the error of the check, which caused transition to Down
+	Err error
+}
+
+// Status is the current state of a target
+type Status struct {
+	Target string
+	State  State
+	// Since is time of the last transition
+	Since       time.Time
+	Last        Result
+	Checks      int
+	Failures    int
+	Transitions int
+}
+
+type targetRecord struct {
+	status Status
+	// ring buffer of the last results
+	history []Result
+	next    int
+}
+
+// Store keeps the latest results of every target, it is safe for concurrent use
+type Store struct {
+	mx          sync.RWMutex
+	historySize int
+	targets     map[string]*targetRecord
+	listeners   []func(Transition)
+}
+
+// NewStore creates store, which keeps {historySize} last results per target
+func NewStore(historySize int) *Store {
+	if historySize < 1 {
+		historySize = 1
+	}
+	return &Store{
+		historySize: historySize,
+		targets:     map[string]*targetRecord{},
+	}
+}
+
+// OnTransition registers {f} to be called on every state change,
+// it is called synchronously without store lock
+func (s *Store) OnTransition(f func(Transition)) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.listeners = append(s.listeners, f)
+}
+
+// Record saves {res} and reports if target state changed
+func (s *Store) Record(res Result) bool {
+	s.mx.Lock()
+	rec, ok := s.targets[res.Target]
+	if !ok {
+		rec = &targetRecord{status: Status{Target: res.Target}}
+		s.targets[res.Target] = rec
+	}
+
+	if len(rec.history) < s.historySize {
+		rec.history = append(rec.history, res)
+	} else {
+		rec.history[rec.next] = res
+	}
+	rec.next = (rec.next + 1) % s.historySize
+
+	st := &rec.status
+	st.Last = res
+	st.Checks++
+	if res.Err != nil {
+		st.Failures++
+	}
+	var tr Transition
+	changed := st.State != res.State()
+	if changed {
+		tr = Transition{Target: res.Target, Time: res.Time, From: st.State, To: res.State(), Err: res.Err}
+		st.State, st.Since = res.State(), res.Time
+		st.Transitions++
+	}
+	listeners := s.listeners
+	s.mx.Unlock()
+
+	if changed {
+		for _, f := range listeners {
+			f(tr)
+		}
+	}
+	return changed
+}
+
+// Status returns current status of {target}
+func (s *Store) Status(target string) (Status, bool) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	rec, ok := s.targets[target]
+	if !ok {
+		return Status{Target: target}, false
+	}
+	return rec.status, true
+}
+
+// Statuses returns statuses of all targets sorted by name
+func (s *Store) Statuses() []Status {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	res := make([]Status, 0, len(s.targets))
+	for _, rec := range s.targets {
+		res = append(res, rec.status)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Target < res[j].Target })
+	return res
+}
+
+// History returns the last results of {target}, the oldest first
+func (s *Store) History(target string) []Result {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	rec, ok := s.targets[target]
+	if !ok {
+		return nil
+	}
+	if len(rec.history) < s.historySize {
+		return append([]Result(nil), rec.history...)
+	}
+	res := make([]Result, 0, s.historySize)
+	res = append(res, rec.history[rec.next:]...)
+	return append(res, rec.history[:rec.next]...)
+}
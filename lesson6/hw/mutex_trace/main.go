@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"runtime"
 	"runtime/trace"
 	"sync"
 	"time"
@@ -32,7 +33,6 @@ func main() {
 
 	// tracing
 	trace.Start(os.Stderr)
-	defer trace.Stop()
 
 	// try to merge two slices into one with unique values
 	uniqValues := set.NewSetInt(10)
@@ -54,4 +54,25 @@ func main() {
 	wg.Wait()
 
 	fmt.Printf("unique values count: %d\n", uniqValues.Len())
+
+	// the same without shared lock: every goroutine
+	// fills its own set, then sets are merged
+	parts := make([]*set.SetInt, len(testSlices))
+	for i, slice := range testSlices {
+		wg.Add(1)
+		go func(i int, values []int) {
+			defer wg.Done()
+			parts[i] = set.NewSetInt(len(values))
+			for _, val := range values {
+				parts[i].Add(val)
+			}
+		}(i, slice)
+	}
+	wg.Wait()
+
+	// merge takes set locks too, keep them out of the trace
+	trace.Stop()
+	merged := parts[0].UnionParallel(parts[1], runtime.GOMAXPROCS(0))
+	fmt.Printf("unique values count by union: %d, equal: %v\n",
+		merged.Len(), merged.Equal(uniqValues))
 }
@@ -0,0 +1,67 @@
+package primes
+
+import "math/bits"
+
+// smallPrimes are trial divisors and Miller-Rabin bases,
+// the first 12 primes are enough to test any 64-bit number
+var smallPrimes = []uint64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37}
+
+// IsPrime reports if {n} is prime, using deterministic Miller-Rabin test
+func IsPrime(n uint64) bool {
+	if n < 2 {
+		return false
+	}
+	for _, p := range smallPrimes {
+		if n%p == 0 {
+			return n == p
+		}
+	}
+	if n < 41*41 {
+		return true
+	}
+
+	// n-1 = d * 2^s
+	d := n - 1
+	s := bits.TrailingZeros64(d)
+	d >>= s
+
+	for _, a := range smallPrimes {
+		if !mrRound(n, d, s, a) {
+			return false
+		}
+	}
+	return true
+}
+
+// mrRound reports if {n} is a strong probable prime to base {a}
+func mrRound(n, d uint64, s int, a uint64) bool {
+	x := powMod(a, d, n)
+	if x == 1 || x == n-1 {
+		return true
+	}
+	for i := 1; i < s; i++ {
+		x = mulMod(x, x, n)
+		if x == n-1 {
+			return true
+		}
+	}
+	return false
+}
+
+// mulMod returns a*b mod m without overflow
+func mulMod(a, b, m uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return bits.Rem64(hi, lo, m)
+}
+
+func powMod(base, exp, m uint64) uint64 {
+	res := uint64(1)
+	base %= m
+	for ; exp > 0; exp >>= 1 {
+		if exp&1 == 1 {
+			res = mulMod(res, base, m)
+		}
+		base = mulMod(base, base, m)
+	}
+	return res
+}
@@ -0,0 +1,122 @@
+package primes
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"testing"
+)
+
+// naiveIsPrime is trial division, slow but obviously right
+func naiveIsPrime(n uint64) bool {
+	if n < 2 {
+		return false
+	}
+	for d := uint64(2); d*d <= n; d++ {
+		if n%d == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+const largestPrime64 = 18446744073709551557
+
+func TestIsPrime(t *testing.T) {
+	for n := uint64(0); n < 20000; n++ {
+		if got, expect := IsPrime(n), naiveIsPrime(n); got != expect {
+			t.Fatalf("IsPrime(%d): got %v, expect %v", n, got, expect)
+		}
+	}
+
+	cases := []struct {
+		n      uint64
+		expect bool
+	}{
+		{561, false},                 // Carmichael number
+		{3215031751, false},          // strong pseudoprime to bases 2, 3, 5, 7
+		{3825123056546413051, false}, // strong pseudoprime to bases up to 23
+		{4294967291, true},           // the largest 32-bit prime
+		{4294967297, false},          // 2^32 + 1 = 641 * 6700417
+		{1000000007, true},
+		{largestPrime64, true},
+		{math.MaxUint64, false},
+		{(1 << 61) - 1, true}, // Mersenne prime
+		{4294967291 * 4294967279, false},
+	}
+	for _, c := range cases {
+		if got := IsPrime(c.n); got != c.expect {
+			t.Errorf("IsPrime(%d): got %v, expect %v", c.n, got, c.expect)
+		}
+	}
+}
+
+func TestPrimesInRange(t *testing.T) {
+	ranges := [][2]uint64{
+		{0, 0},
+		{0, 2},
+		{0, 100},
+		{90, 97},
+		{90, 98},
+		{1000, 300000}, // a few segments
+		{1 << 40, 1<<40 + 1000},
+	}
+	for _, r := range ranges {
+		var expect []uint64
+		for n := r[0]; n < r[1]; n++ {
+			if IsPrime(n) {
+				expect = append(expect, n)
+			}
+		}
+		got := PrimesInRange(r[0], r[1])
+		if fmt.Sprint(got) != fmt.Sprint(expect) {
+			t.Errorf("primes in [%d, %d): got %d primes, expect %d", r[0], r[1], len(got), len(expect))
+		}
+	}
+
+	if got := len(PrimesInRange(0, 1000000)); got != 78498 {
+		t.Errorf("primes below 1e6: got %d, expect 78498", got)
+	}
+	// several windows with a single worker
+	prev := runtime.GOMAXPROCS(1)
+	got := len(PrimesInRange(0, 1000000))
+	runtime.GOMAXPROCS(prev)
+	if got != 78498 {
+		t.Errorf("primes below 1e6 by one worker: got %d, expect 78498", got)
+	}
+	// above cached base primes, checked by Miller-Rabin
+	if got := PrimesInRange(largestPrime64-100, math.MaxUint64); fmt.Sprint(got) != "[18446744073709551521 18446744073709551533 18446744073709551557]" {
+		t.Errorf("largest 64-bit primes: got %v", got)
+	}
+}
+
+func TestIterator(t *testing.T) {
+	it := NewIterator(0)
+	expect := PrimesInRange(0, 200000)
+	for i, p := range expect {
+		got, ok := it.Next()
+		if !ok || got != p {
+			t.Fatalf("prime %d: got %d, expect %d", i, got, p)
+		}
+	}
+
+	it = NewIterator(largestPrime64 - 10)
+	if p, ok := it.Next(); !ok || p != largestPrime64 {
+		t.Errorf("last prime: got %d %v", p, ok)
+	}
+	if p, ok := it.Next(); ok {
+		t.Errorf("iterator should stop after the last prime, got %d", p)
+	}
+}
+
+func BenchmarkPrimesInRange(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		PrimesInRange(0, 10_000_000)
+	}
+}
+
+func BenchmarkIsPrime(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		IsPrime(largestPrime64)
+	}
+}
@@ -0,0 +1,205 @@
+// Package primes finds primes with a cached segmented sieve
+// and checks single numbers with Miller-Rabin test
+package primes
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// segmentSize is count of numbers sieved at once,
+// a segment fits into L1/L2 cache
+const segmentSize = 1 << 16
+
+// maxBase limits cached base primes: ranges above maxBase^2
+// are checked number by number with Miller-Rabin test
+const maxBase = 1 << 24
+
+// base keeps primes below {limit}, it grows on demand
+var base struct {
+	mx     sync.RWMutex
+	limit  uint64
+	primes []uint64
+}
+
+// basePrimes returns cached primes up to sqrt({hi}),
+// ok is false if they are too many to cache
+func basePrimes(hi uint64) (primes []uint64, ok bool) {
+	need := isqrt(hi) + 1
+	if need > maxBase {
+		return nil, false
+	}
+
+	base.mx.RLock()
+	if base.limit >= need {
+		primes = base.primes
+		base.mx.RUnlock()
+		return primes, true
+	}
+	base.mx.RUnlock()
+
+	base.mx.Lock()
+	defer base.mx.Unlock()
+
+	if base.limit < need {
+		// grow twice to amortize re-sieving
+		limit := max(need, 2*base.limit, 1<<12)
+		limit = min(limit, maxBase)
+		base.primes = simpleSieve(limit)
+		base.limit = limit
+	}
+	return base.primes, true
+}
+
+// simpleSieve returns primes below {limit}
+func simpleSieve(limit uint64) []uint64 {
+	composite := make([]bool, limit)
+	var primes []uint64
+	for i := uint64(2); i < limit; i++ {
+		if composite[i] {
+			continue
+		}
+		primes = append(primes, i)
+		for j := i * i; j < limit; j += i {
+			composite[j] = true
+		}
+	}
+	return primes
+}
+
+func isqrt(n uint64) uint64 {
+	r := uint64(math.Sqrt(float64(n)))
+	// float rounding may be off by one
+	for r*r > n {
+		r--
+	}
+	for (r+1)*(r+1) <= n && r+1 <= math.MaxUint32 {
+		r++
+	}
+	return r
+}
+
+// sieveSegment appends primes in [lo, hi) to {dst}, hi-lo <= segmentSize
+func sieveSegment(dst []uint64, lo, hi uint64, composite []bool) []uint64 {
+	primes, ok := basePrimes(hi - 1)
+	if !ok {
+		for n := lo; n < hi; n++ {
+			if IsPrime(n) {
+				dst = append(dst, n)
+			}
+		}
+		return dst
+	}
+
+	composite = composite[:hi-lo]
+	for i := range composite {
+		composite[i] = false
+	}
+	for _, p := range primes {
+		if p*p >= hi {
+			break
+		}
+		// the first multiple of p in the segment, but not p itself
+		start := max(p*p, (lo+p-1)/p*p)
+		for j := start; j < hi; j += p {
+			composite[j-lo] = true
+		}
+	}
+	for i, c := range composite {
+		if n := lo + uint64(i); !c && n >= 2 {
+			dst = append(dst, n)
+		}
+	}
+	return dst
+}
+
+// windowSegments is count of segments sieved in parallel per worker,
+// the result of a window is appended before the next one starts
+const windowSegments = 4
+
+// PrimesInRange returns sorted primes in [lo, hi),
+// segments are sieved by GOMAXPROCS goroutines a window at a time,
+// so memory besides the result doesn't grow with the range
+func PrimesInRange(lo, hi uint64) []uint64 {
+	if lo >= hi {
+		return nil
+	}
+	segments := (hi-lo-1)/segmentSize + 1
+	workers := int(min(uint64(runtime.GOMAXPROCS(0)), segments))
+	window := uint64(workers * windowSegments)
+
+	results := make([][]uint64, min(window, segments))
+	composites := make([][]bool, workers)
+	for w := range composites {
+		composites[w] = make([]bool, segmentSize)
+	}
+
+	var primes []uint64
+	for first := uint64(0); first < segments; first += window {
+		n := min(window, segments-first)
+		var next atomic.Uint64
+		wg := sync.WaitGroup{}
+		wg.Add(workers)
+		for w := 0; w < workers; w++ {
+			go func(composite []bool) {
+				defer wg.Done()
+				for {
+					i := next.Add(1) - 1
+					if i >= n {
+						return
+					}
+					segLo := lo + (first+i)*segmentSize
+					segHi := segLo + min(segmentSize, hi-segLo)
+					results[i] = sieveSegment(results[i][:0], segLo, segHi, composite)
+				}
+			}(composites[w])
+		}
+		wg.Wait()
+
+		for _, r := range results[:n] {
+			primes = append(primes, r...)
+		}
+	}
+	return primes
+}
+
+// Iterator returns primes one by one in ascending order,
+// sieving a segment ahead. It isn't safe for concurrent use
+type Iterator struct {
+	next      uint64 // start of the next segment
+	buf       []uint64
+	pos       int
+	composite []bool
+	done      bool
+}
+
+// NewIterator creates iterator over primes >= {from}
+func NewIterator(from uint64) *Iterator {
+	return &Iterator{next: from}
+}
+
+// Next returns the next prime, it is false after the largest 64-bit prime
+func (it *Iterator) Next() (uint64, bool) {
+	for it.pos == len(it.buf) {
+		if it.done {
+			return 0, false
+		}
+		if it.composite == nil {
+			it.composite = make([]bool, segmentSize)
+		}
+		lo := it.next
+		size := min(segmentSize, math.MaxUint64-lo)
+		it.buf = sieveSegment(it.buf[:0], lo, lo+size, it.composite)
+		it.pos = 0
+		it.next = lo + size
+		if it.next == math.MaxUint64 {
+			// MaxUint64 itself isn't prime
+			it.done = true
+		}
+	}
+	p := it.buf[it.pos]
+	it.pos++
+	return p, true
+}
@@ -1,41 +1,83 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"math/rand"
-	"sync"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"time"
+
+	"geekbrains/examples/lesson6/hw/health"
 )
 
-// simulates ping tool
-func ping(hostname string) bool {
-	response := rand.Intn(100)
-	time.Sleep(time.Duration(response * 1000))
-	if response == 100 {
-		return false
+func main() {
+	// results used to be written to a plain map from two goroutines,
+	// now they go to health.Store, which is guarded by a mutex,
+	// so `go run -race` stays quiet
+
+	tcpLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatal(err)
 	}
-	return true
-}
+	go accept(tcpLn)
 
-func main() {
-	// to simulate race condition,
-	// try to store ping results in global map
+	healthy := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer healthy.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
 
-	pingResults := make(map[string]bool, 1000)
+	store := health.NewStore(20)
+	store.OnTransition(func(tr health.Transition) {
+		log.Printf("%-8s %v -> %v %v", tr.Target, tr.From, tr.To, errOrEmpty(tr.Err))
+	})
 
-	wg := sync.WaitGroup{}
-	go func() {
-		for i := 0; i < 500; i++ {
-			hostname := fmt.Sprintf("host%4.d", i)
-			pingResults[hostname] = ping(hostname)
+	checker := health.NewChecker(store, health.Options{
+		Concurrency: 2,
+		Interval:    100 * time.Millisecond,
+		Timeout:     50 * time.Millisecond,
+	})
+	targets := []health.Target{
+		{Name: "tcp", Probe: health.TCPProbe{Addr: tcpLn.Addr().String()}},
+		{Name: "healthy", Probe: health.HTTPProbe{URL: healthy.URL}, Interval: 50 * time.Millisecond},
+		{Name: "broken", Probe: health.HTTPProbe{URL: broken.URL}},
+		{Name: "want500", Probe: health.HTTPProbe{URL: broken.URL, ExpectStatus: http.StatusInternalServerError}},
+	}
+	for _, t := range targets {
+		if err := checker.Add(t); err != nil {
+			log.Fatal(err)
 		}
-	}()
-	go func() {
-		for i := 500; i < 1000; i++ {
-			hostname := fmt.Sprintf("host%4.d", i)
-			pingResults[hostname] = ping(hostname)
+	}
+
+	// take tcp host down in the middle of the run
+	time.AfterFunc(500*time.Millisecond, func() { tcpLn.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	checker.Run(ctx)
+
+	for _, st := range store.Statuses() {
+		fmt.Printf("%-8s %-5v checks=%d failures=%d transitions=%d\n",
+			st.Target, st.State, st.Checks, st.Failures, st.Transitions)
+	}
+}
+
+func accept(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
 		}
-	}()
+		conn.Close()
+	}
+}
 
-	wg.Wait()
+func errOrEmpty(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
 }
@@ -0,0 +1,223 @@
+package set
+
+import "sync"
+
+// rlockBoth read-locks both sets, lower id first,
+// so concurrent a.Union(b) and b.Union(a) can't deadlock
+// with a writer waiting for one of the sets
+func rlockBoth(a, b *SetInt) (unlock func()) {
+	return rlockOrdered(&a.mx, a.id, &b.mx, b.id)
+}
+
+// rlockOrdered read-locks mutexes in order of their owners ids
+func rlockOrdered(a *sync.RWMutex, aID uint64, b *sync.RWMutex, bID uint64) (unlock func()) {
+	if a == b {
+		// RLock twice may block on a pending writer
+		a.RLock()
+		return a.RUnlock
+	}
+	if aID > bID {
+		a, b = b, a
+	}
+	a.RLock()
+	b.RLock()
+	return func() {
+		b.RUnlock()
+		a.RUnlock()
+	}
+}
+
+// Union returns new set with elements of both sets
+func (ms *SetInt) Union(other *SetInt) *SetInt {
+	unlock := rlockBoth(ms, other)
+	defer unlock()
+
+	res := NewSetInt(len(ms.data) + len(other.data))
+	for val := range ms.data {
+		res.data[val] = struct{}{}
+	}
+	for val := range other.data {
+		res.data[val] = struct{}{}
+	}
+	return res
+}
+
+// Intersect returns new set with elements present in both sets
+func (ms *SetInt) Intersect(other *SetInt) *SetInt {
+	unlock := rlockBoth(ms, other)
+	defer unlock()
+
+	small, large := ms.data, other.data
+	if len(small) > len(large) {
+		small, large = large, small
+	}
+	res := NewSetInt(len(small))
+	for val := range small {
+		if _, ok := large[val]; ok {
+			res.data[val] = struct{}{}
+		}
+	}
+	return res
+}
+
+// Difference returns new set with elements of {ms} not present in {other}
+func (ms *SetInt) Difference(other *SetInt) *SetInt {
+	unlock := rlockBoth(ms, other)
+	defer unlock()
+
+	res := NewSetInt(len(ms.data))
+	addMissing(res.data, ms.data, other.data)
+	return res
+}
+
+// SymmetricDifference returns new set with elements present in only one of sets
+func (ms *SetInt) SymmetricDifference(other *SetInt) *SetInt {
+	unlock := rlockBoth(ms, other)
+	defer unlock()
+
+	res := NewSetInt(0)
+	addMissing(res.data, ms.data, other.data)
+	addMissing(res.data, other.data, ms.data)
+	return res
+}
+
+// addMissing adds to {dst} elements of {src} missing in {exclude}
+func addMissing(dst, src, exclude map[int]struct{}) {
+	for val := range src {
+		if _, ok := exclude[val]; !ok {
+			dst[val] = struct{}{}
+		}
+	}
+}
+
+// IsSubset reports if every element of {ms} is in {other}
+func (ms *SetInt) IsSubset(other *SetInt) bool {
+	unlock := rlockBoth(ms, other)
+	defer unlock()
+
+	if len(ms.data) > len(other.data) {
+		return false
+	}
+	for val := range ms.data {
+		if _, ok := other.data[val]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal reports if sets have the same elements
+func (ms *SetInt) Equal(other *SetInt) bool {
+	unlock := rlockBoth(ms, other)
+	defer unlock()
+
+	if len(ms.data) != len(other.data) {
+		return false
+	}
+	for val := range ms.data {
+		if _, ok := other.data[val]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+//
+// Parallel variants split elements between {workers} goroutines,
+// they pay off for sets with hundreds of thousands of elements
+//
+
+// UnionParallel is Union, which checks elements of {other} in parallel
+func (ms *SetInt) UnionParallel(other *SetInt, workers int) *SetInt {
+	unlock := rlockBoth(ms, other)
+	defer unlock()
+
+	res := NewSetInt(len(ms.data) + len(other.data))
+	for val := range ms.data {
+		res.data[val] = struct{}{}
+	}
+	for _, val := range filterParallel(other.data, ms.data, false, workers) {
+		res.data[val] = struct{}{}
+	}
+	return res
+}
+
+// IntersectParallel is Intersect, which checks elements in parallel
+func (ms *SetInt) IntersectParallel(other *SetInt, workers int) *SetInt {
+	unlock := rlockBoth(ms, other)
+	defer unlock()
+
+	small, large := ms.data, other.data
+	if len(small) > len(large) {
+		small, large = large, small
+	}
+	return fromSlice(filterParallel(small, large, true, workers))
+}
+
+// DifferenceParallel is Difference, which checks elements in parallel
+func (ms *SetInt) DifferenceParallel(other *SetInt, workers int) *SetInt {
+	unlock := rlockBoth(ms, other)
+	defer unlock()
+
+	return fromSlice(filterParallel(ms.data, other.data, false, workers))
+}
+
+// SymmetricDifferenceParallel is SymmetricDifference,
+// which checks elements in parallel
+func (ms *SetInt) SymmetricDifferenceParallel(other *SetInt, workers int) *SetInt {
+	unlock := rlockBoth(ms, other)
+	defer unlock()
+
+	res := fromSlice(filterParallel(ms.data, other.data, false, workers))
+	for _, val := range filterParallel(other.data, ms.data, false, workers) {
+		res.data[val] = struct{}{}
+	}
+	return res
+}
+
+func fromSlice(values []int) *SetInt {
+	res := NewSetInt(len(values))
+	for _, val := range values {
+		res.data[val] = struct{}{}
+	}
+	return res
+}
+
+// filterParallel returns elements of {src}, which are present in {check}
+// if {present} is true or missing otherwise. Maps are only read,
+// so workers don't need locks while callers hold read locks
+func filterParallel(src, check map[int]struct{}, present bool, workers int) []int {
+	if workers < 1 {
+		workers = 1
+	}
+	values := make([]int, 0, len(src))
+	for val := range src {
+		values = append(values, val)
+	}
+
+	chunk := (len(values) + workers - 1) / workers
+	results := make([][]int, workers)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers && w*chunk < len(values); w++ {
+		end := (w + 1) * chunk
+		if end > len(values) {
+			end = len(values)
+		}
+		wg.Add(1)
+		go func(w int, part []int) {
+			defer wg.Done()
+			for _, val := range part {
+				if _, ok := check[val]; ok == present {
+					results[w] = append(results[w], val)
+				}
+			}
+		}(w, values[w*chunk:end])
+	}
+	wg.Wait()
+
+	res := values[:0]
+	for _, part := range results {
+		res = append(res, part...)
+	}
+	return res
+}
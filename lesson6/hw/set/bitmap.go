@@ -0,0 +1,308 @@
+package set
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// BitmapSetInt is a compressed set of ints in roaring bitmap style.
+// Elements are split into chunks by high bits, each chunk is kept
+// in a sorted array, a bitmap or a list of runs, whichever is smaller.
+// Values and Range return elements in ascending order
+type BitmapSetInt struct {
+	mx sync.RWMutex
+	// sorted high bits of chunks and their containers
+	keys  []uint64
+	conts []container
+	id    uint64
+}
+
+func NewBitmapSetInt() *BitmapSetInt {
+	return &BitmapSetInt{id: atomic.AddUint64(&lastID, 1)}
+}
+
+// split maps int to chunk key and low bits,
+// sign bit is flipped so negative ints go first
+func split(elem int) (uint64, uint16) {
+	u := uint64(elem) ^ 1<<63
+	return u >> 16, uint16(u)
+}
+
+func join(key uint64, low uint16) int {
+	return int((key<<16 | uint64(low)) ^ 1<<63)
+}
+
+// find returns position of chunk {key} or where it should be, mx should be held
+func (bs *BitmapSetInt) find(key uint64) (int, bool) {
+	i := sort.Search(len(bs.keys), func(i int) bool { return bs.keys[i] >= key })
+	return i, i < len(bs.keys) && bs.keys[i] == key
+}
+
+func (bs *BitmapSetInt) Add(elem int) {
+	key, low := split(elem)
+
+	bs.mx.Lock()
+	defer bs.mx.Unlock()
+
+	i, found := bs.find(key)
+	if found {
+		bs.conts[i] = bs.conts[i].add(low)
+		return
+	}
+	bs.keys = append(bs.keys, 0)
+	copy(bs.keys[i+1:], bs.keys[i:])
+	bs.keys[i] = key
+	bs.conts = append(bs.conts, nil)
+	copy(bs.conts[i+1:], bs.conts[i:])
+	bs.conts[i] = &arrayContainer{vals: []uint16{low}}
+}
+
+func (bs *BitmapSetInt) Remove(elem int) {
+	key, low := split(elem)
+
+	bs.mx.Lock()
+	defer bs.mx.Unlock()
+
+	i, found := bs.find(key)
+	if !found {
+		return
+	}
+	bs.conts[i] = bs.conts[i].remove(low)
+	if bs.conts[i].card() == 0 {
+		bs.keys = append(bs.keys[:i], bs.keys[i+1:]...)
+		bs.conts = append(bs.conts[:i], bs.conts[i+1:]...)
+	}
+}
+
+func (bs *BitmapSetInt) Has(elem int) bool {
+	key, low := split(elem)
+
+	bs.mx.RLock()
+	defer bs.mx.RUnlock()
+
+	i, found := bs.find(key)
+	return found && bs.conts[i].has(low)
+}
+
+func (bs *BitmapSetInt) Len() int {
+	bs.mx.RLock()
+	defer bs.mx.RUnlock()
+
+	return bs.card()
+}
+
+// card is Len without lock
+func (bs *BitmapSetInt) card() int {
+	n := 0
+	for _, c := range bs.conts {
+		n += c.card()
+	}
+	return n
+}
+
+// Values returns elements in ascending order
+func (bs *BitmapSetInt) Values() []int {
+	bs.mx.RLock()
+	defer bs.mx.RUnlock()
+
+	values := make([]int, 0, bs.card())
+	bs.iterate(func(elem int) bool {
+		values = append(values, elem)
+		return true
+	})
+	return values
+}
+
+// SortedValues is the same as Values, for compatibility with SetInt
+func (bs *BitmapSetInt) SortedValues() []int {
+	return bs.Values()
+}
+
+// Range calls {f} in ascending order until it returns false.
+// Set is read-locked meanwhile, so {f} must not modify it
+func (bs *BitmapSetInt) Range(f func(elem int) bool) {
+	bs.mx.RLock()
+	defer bs.mx.RUnlock()
+
+	bs.iterate(f)
+}
+
+func (bs *BitmapSetInt) iterate(f func(elem int) bool) {
+	for i, c := range bs.conts {
+		key := bs.keys[i]
+		ok := c.iterate(func(low uint16) bool {
+			return f(join(key, low))
+		})
+		if !ok {
+			return
+		}
+	}
+}
+
+// Rank returns count of elements <= {elem}
+func (bs *BitmapSetInt) Rank(elem int) int {
+	key, low := split(elem)
+
+	bs.mx.RLock()
+	defer bs.mx.RUnlock()
+
+	i, found := bs.find(key)
+	n := 0
+	for _, c := range bs.conts[:i] {
+		n += c.card()
+	}
+	if found {
+		n += bs.conts[i].rank(low)
+	}
+	return n
+}
+
+// Select returns {i}-th smallest element, counting from 0,
+// it is false if {i} is out of range
+func (bs *BitmapSetInt) Select(i int) (int, bool) {
+	bs.mx.RLock()
+	defer bs.mx.RUnlock()
+
+	if i < 0 {
+		return 0, false
+	}
+	for ci, c := range bs.conts {
+		if i < c.card() {
+			return join(bs.keys[ci], c.selectAt(i)), true
+		}
+		i -= c.card()
+	}
+	return 0, false
+}
+
+// Optimize converts chunks to the smallest representation,
+// it is worth calling after bulk load of consecutive values
+func (bs *BitmapSetInt) Optimize() {
+	bs.mx.Lock()
+	defer bs.mx.Unlock()
+
+	for i, c := range bs.conts {
+		bs.conts[i] = optimize(c)
+	}
+}
+
+//
+// Algebra, see algebra.go for SetInt versions
+//
+
+// rlockBitmaps is rlockBoth for bitmap sets
+func rlockBitmaps(a, b *BitmapSetInt) (unlock func()) {
+	return rlockOrdered(&a.mx, a.id, &b.mx, b.id)
+}
+
+// merge walks chunks of both sets in order, {op} gets nil container
+// for missing chunk and returns nil to skip the chunk
+func (bs *BitmapSetInt) merge(other *BitmapSetInt, op func(a, b container) container) *BitmapSetInt {
+	unlock := rlockBitmaps(bs, other)
+	defer unlock()
+
+	res := NewBitmapSetInt()
+	push := func(key uint64, c container) {
+		if c != nil && c.card() > 0 {
+			res.keys = append(res.keys, key)
+			res.conts = append(res.conts, c)
+		}
+	}
+	i, j := 0, 0
+	for i < len(bs.keys) || j < len(other.keys) {
+		switch {
+		case j == len(other.keys) || i < len(bs.keys) && bs.keys[i] < other.keys[j]:
+			push(bs.keys[i], op(bs.conts[i], nil))
+			i++
+		case i == len(bs.keys) || bs.keys[i] > other.keys[j]:
+			push(other.keys[j], op(nil, other.conts[j]))
+			j++
+		default:
+			push(bs.keys[i], op(bs.conts[i], other.conts[j]))
+			i++
+			j++
+		}
+	}
+	return res
+}
+
+// Union returns new set with elements of both sets
+func (bs *BitmapSetInt) Union(other *BitmapSetInt) *BitmapSetInt {
+	return bs.merge(other, func(a, b container) container {
+		switch {
+		case a == nil:
+			return b.clone()
+		case b == nil:
+			return a.clone()
+		}
+		return or(a, b)
+	})
+}
+
+// Intersect returns new set with elements present in both sets
+func (bs *BitmapSetInt) Intersect(other *BitmapSetInt) *BitmapSetInt {
+	return bs.merge(other, func(a, b container) container {
+		if a == nil || b == nil {
+			return nil
+		}
+		return and(a, b)
+	})
+}
+
+// Difference returns new set with elements of {bs} not present in {other}
+func (bs *BitmapSetInt) Difference(other *BitmapSetInt) *BitmapSetInt {
+	return bs.merge(other, func(a, b container) container {
+		switch {
+		case a == nil:
+			return nil
+		case b == nil:
+			return a.clone()
+		}
+		return andNot(a, b)
+	})
+}
+
+// SymmetricDifference returns new set with elements present in only one of sets
+func (bs *BitmapSetInt) SymmetricDifference(other *BitmapSetInt) *BitmapSetInt {
+	return bs.merge(other, func(a, b container) container {
+		switch {
+		case a == nil:
+			return b.clone()
+		case b == nil:
+			return a.clone()
+		}
+		return xor(a, b)
+	})
+}
+
+// IsSubset reports if every element of {bs} is in {other}
+func (bs *BitmapSetInt) IsSubset(other *BitmapSetInt) bool {
+	unlock := rlockBitmaps(bs, other)
+	defer unlock()
+
+	for i, key := range bs.keys {
+		j, found := other.find(key)
+		if !found || and(bs.conts[i], other.conts[j]).card() != bs.conts[i].card() {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal reports if sets have the same elements
+func (bs *BitmapSetInt) Equal(other *BitmapSetInt) bool {
+	unlock := rlockBitmaps(bs, other)
+	defer unlock()
+
+	if len(bs.keys) != len(other.keys) {
+		return false
+	}
+	for i, key := range bs.keys {
+		a, b := bs.conts[i], other.conts[i]
+		if key != other.keys[i] || a.card() != b.card() || and(a, b).card() != a.card() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,262 @@
+package set
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"testing"
+)
+
+func newBitmap(values ...int) *BitmapSetInt {
+	s := NewBitmapSetInt()
+	for _, val := range values {
+		s.Add(val)
+	}
+	return s
+}
+
+// randomValues returns values of mixed density: sparse,
+// dense and consecutive chunks, so every container kind is used
+func randomValues(r *rand.Rand, n int) []int {
+	values := make([]int, 0, n)
+	for len(values) < n {
+		switch r.Intn(3) {
+		case 0:
+			values = append(values, r.Intn(1<<30)-1<<29)
+		case 1:
+			values = append(values, r.Intn(1<<16)+1<<20)
+		case 2:
+			start := r.Intn(1 << 18)
+			for i := 0; i < 100; i++ {
+				values = append(values, start+i)
+			}
+		}
+	}
+	return values
+}
+
+func TestBitmapModel(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	bs := NewBitmapSetInt()
+	model := map[int]struct{}{}
+
+	for _, val := range randomValues(r, 50000) {
+		bs.Add(val)
+		model[val] = struct{}{}
+	}
+	// remove some, so bitmaps shrink back
+	for val := range model {
+		if r.Intn(3) == 0 {
+			bs.Remove(val)
+			delete(model, val)
+		}
+	}
+
+	check := func(stage string) {
+		expect := make([]int, 0, len(model))
+		for val := range model {
+			expect = append(expect, val)
+		}
+		sort.Ints(expect)
+
+		if got := bs.Len(); got != len(expect) {
+			t.Fatalf("%s: len: got %d, expect %d", stage, got, len(expect))
+		}
+		if got := bs.Values(); fmt.Sprint(got) != fmt.Sprint(expect) {
+			t.Fatalf("%s: values differ", stage)
+		}
+		for i := 0; i < 1000; i++ {
+			k := r.Intn(len(expect))
+			if got, ok := bs.Select(k); !ok || got != expect[k] {
+				t.Fatalf("%s: select %d: got %d, expect %d", stage, k, got, expect[k])
+			}
+			if got := bs.Rank(expect[k]); got != k+1 {
+				t.Fatalf("%s: rank of %d: got %d, expect %d", stage, expect[k], got, k+1)
+			}
+			if bs.Has(expect[k]+1) != (k+1 < len(expect) && expect[k+1] == expect[k]+1) {
+				t.Fatalf("%s: has %d is wrong", stage, expect[k]+1)
+			}
+		}
+	}
+	check("after add")
+	bs.Optimize()
+	check("after optimize")
+	// run containers are converted back on change
+	bs.Add(5)
+	model[5] = struct{}{}
+	bs.Remove(values(model)[0])
+	delete(model, values(model)[0])
+	check("after change")
+}
+
+func values(m map[int]struct{}) []int {
+	res := make([]int, 0, len(m))
+	for val := range m {
+		res = append(res, val)
+	}
+	sort.Ints(res)
+	return res
+}
+
+func TestBitmapEdges(t *testing.T) {
+	bs := newBitmap(math.MinInt, -1, 0, 1, math.MaxInt)
+	if got := fmt.Sprint(bs.Values()); got != fmt.Sprint([]int{math.MinInt, -1, 0, 1, math.MaxInt}) {
+		t.Errorf("values: got %s", got)
+	}
+	if got := bs.Rank(-2); got != 1 {
+		t.Errorf("rank of -2: got %d, expect 1", got)
+	}
+	if _, ok := bs.Select(5); ok {
+		t.Errorf("select out of range should fail")
+	}
+
+	// full chunk in every representation
+	full := NewBitmapSetInt()
+	for i := 0; i < 1<<16; i++ {
+		full.Add(i)
+	}
+	for _, stage := range []string{"bitmap", "runs"} {
+		if full.Len() != 1<<16 || full.Rank(1<<16-1) != 1<<16 || full.Rank(63) != 64 {
+			t.Errorf("%s: wrong len or rank", stage)
+		}
+		if got, _ := full.Select(1<<16 - 1); got != 1<<16-1 {
+			t.Errorf("%s: select last: got %d", stage, got)
+		}
+		full.Optimize()
+	}
+}
+
+func TestBitmapAlgebra(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	av, bv := randomValues(r, 20000), randomValues(r, 20000)
+	// shared part
+	bv = append(bv, av[:5000]...)
+
+	a, b := newBitmap(av...), newBitmap(bv...)
+	ma, mb := newSet(av...), newSet(bv...)
+
+	cases := []struct {
+		name   string
+		got    *BitmapSetInt
+		expect *SetInt
+	}{
+		{"union", a.Union(b), ma.Union(mb)},
+		{"intersect", a.Intersect(b), ma.Intersect(mb)},
+		{"difference", a.Difference(b), ma.Difference(mb)},
+		{"symmetric difference", a.SymmetricDifference(b), ma.SymmetricDifference(mb)},
+		{"with itself", a.Intersect(a), ma},
+	}
+	for _, c := range cases {
+		if fmt.Sprint(c.got.Values()) != fmt.Sprint(c.expect.SortedValues()) {
+			t.Errorf("%s differs from map version", c.name)
+		}
+	}
+
+	if !a.Intersect(b).IsSubset(a) || a.IsSubset(b) {
+		t.Errorf("wrong subset check")
+	}
+	if !a.Equal(a.Union(a)) || a.Equal(b) || !a.Union(b).Equal(b.Union(a)) {
+		t.Errorf("wrong equal check")
+	}
+
+	// results are independent of operands
+	u := a.Union(b)
+	u.Add(-12345)
+	if a.Has(-12345) || b.Has(-12345) {
+		t.Errorf("result shares memory with operands")
+	}
+}
+
+//
+// Benchmarks against map based SetInt,
+// on the mutex_trace workload: 1M values in [0, 250K)
+//
+
+const benchValues = 1_000_000
+
+func benchWorkload() []int {
+	r := rand.New(rand.NewSource(3))
+	values := make([]int, benchValues)
+	for i := range values {
+		values[i] = r.Intn(benchValues / 4)
+	}
+	return values
+}
+
+func benchSets() map[string]func() IntSet {
+	return map[string]func() IntSet{
+		"map":    func() IntSet { return NewSetInt(0) },
+		"bitmap": func() IntSet { return NewBitmapSetInt() },
+	}
+}
+
+func BenchmarkAdd(b *testing.B) {
+	values := benchWorkload()
+	for name, newSet := range benchSets() {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s := newSet()
+				for _, val := range values {
+					s.Add(val)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkHas(b *testing.B) {
+	values := benchWorkload()
+	for name, newSet := range benchSets() {
+		s := newSet()
+		for _, val := range values[:benchValues/2] {
+			s.Add(val)
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s.Has(values[i%benchValues])
+			}
+		})
+	}
+}
+
+// BenchmarkMemory reports retained bytes per unique element
+func BenchmarkMemory(b *testing.B) {
+	values := benchWorkload()
+	for name, newSet := range benchSets() {
+		b.Run(name, func(b *testing.B) {
+			var before, after runtime.MemStats
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				s := newSet()
+				for _, val := range values {
+					s.Add(val)
+				}
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(s.Len()), "B/elem")
+				runtime.KeepAlive(s)
+			}
+		})
+	}
+}
+
+func BenchmarkIntersect(b *testing.B) {
+	values := benchWorkload()
+	half := benchValues / 2
+
+	b.Run("map", func(b *testing.B) {
+		x, y := newSet(values[:half]...), newSet(values[half:]...)
+		for i := 0; i < b.N; i++ {
+			x.Intersect(y)
+		}
+	})
+	b.Run("bitmap", func(b *testing.B) {
+		x, y := newBitmap(values[:half]...), newBitmap(values[half:]...)
+		for i := 0; i < b.N; i++ {
+			x.Intersect(y)
+		}
+	})
+}
@@ -0,0 +1,432 @@
+package set
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// container keeps low 16 bits of elements with the same high bits.
+// Mutating methods return the container to use further,
+// as it may be converted to another kind
+type container interface {
+	add(x uint16) container
+	remove(x uint16) container
+	has(x uint16) bool
+	card() int
+	// rank returns count of elements <= x
+	rank(x uint16) int
+	// selectAt returns i-th smallest element
+	selectAt(i int) uint16
+	// iterate calls f in ascending order, returns false if f stopped it
+	iterate(f func(x uint16) bool) bool
+	clone() container
+}
+
+// arrayMaxSize is the cardinality, at which sorted array
+// takes as much memory as bitmap: 4096 * 2 bytes = 8KB
+const arrayMaxSize = 4096
+
+const bitmapWords = 1 << 16 / 64
+
+//
+// Array container, for sparse chunks
+//
+
+type arrayContainer struct {
+	vals []uint16
+}
+
+func (c *arrayContainer) search(x uint16) (int, bool) {
+	i := sort.Search(len(c.vals), func(i int) bool { return c.vals[i] >= x })
+	return i, i < len(c.vals) && c.vals[i] == x
+}
+
+func (c *arrayContainer) add(x uint16) container {
+	i, found := c.search(x)
+	if found {
+		return c
+	}
+	if len(c.vals) == arrayMaxSize {
+		return toBitmap(c).add(x)
+	}
+	c.vals = append(c.vals, 0)
+	copy(c.vals[i+1:], c.vals[i:])
+	c.vals[i] = x
+	return c
+}
+
+func (c *arrayContainer) remove(x uint16) container {
+	if i, found := c.search(x); found {
+		c.vals = append(c.vals[:i], c.vals[i+1:]...)
+	}
+	return c
+}
+
+func (c *arrayContainer) has(x uint16) bool {
+	_, found := c.search(x)
+	return found
+}
+
+func (c *arrayContainer) card() int {
+	return len(c.vals)
+}
+
+func (c *arrayContainer) rank(x uint16) int {
+	i, found := c.search(x)
+	if found {
+		return i + 1
+	}
+	return i
+}
+
+func (c *arrayContainer) selectAt(i int) uint16 {
+	return c.vals[i]
+}
+
+func (c *arrayContainer) iterate(f func(x uint16) bool) bool {
+	for _, x := range c.vals {
+		if !f(x) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *arrayContainer) clone() container {
+	return &arrayContainer{vals: append([]uint16(nil), c.vals...)}
+}
+
+//
+// Bitmap container, for dense chunks
+//
+
+type bitmapContainer struct {
+	words [bitmapWords]uint64
+	n     int
+}
+
+func (c *bitmapContainer) add(x uint16) container {
+	if !c.has(x) {
+		c.words[x/64] |= 1 << (x % 64)
+		c.n++
+	}
+	return c
+}
+
+func (c *bitmapContainer) remove(x uint16) container {
+	if !c.has(x) {
+		return c
+	}
+	c.words[x/64] &^= 1 << (x % 64)
+	c.n--
+	return shrink(c)
+}
+
+func (c *bitmapContainer) has(x uint16) bool {
+	return c.words[x/64]&(1<<(x%64)) != 0
+}
+
+func (c *bitmapContainer) card() int {
+	return c.n
+}
+
+func (c *bitmapContainer) rank(x uint16) int {
+	n := 0
+	for _, w := range c.words[:x/64] {
+		n += bits.OnesCount64(w)
+	}
+	// bits up to x inclusive, shift by 64 gives 0, so mask is all ones
+	mask := uint64(1)<<(x%64+1) - 1
+	return n + bits.OnesCount64(c.words[x/64]&mask)
+}
+
+func (c *bitmapContainer) selectAt(i int) uint16 {
+	for wi, w := range c.words {
+		cnt := bits.OnesCount64(w)
+		if i >= cnt {
+			i -= cnt
+			continue
+		}
+		for ; i > 0; i-- {
+			// drop the lowest set bit
+			w &= w - 1
+		}
+		return uint16(wi*64 + bits.TrailingZeros64(w))
+	}
+	panic("set: select out of range")
+}
+
+func (c *bitmapContainer) iterate(f func(x uint16) bool) bool {
+	for wi, w := range c.words {
+		for w != 0 {
+			if !f(uint16(wi*64 + bits.TrailingZeros64(w))) {
+				return false
+			}
+			w &= w - 1
+		}
+	}
+	return true
+}
+
+func (c *bitmapContainer) clone() container {
+	cp := *c
+	return &cp
+}
+
+func (c *bitmapContainer) recount() {
+	c.n = 0
+	for _, w := range c.words {
+		c.n += bits.OnesCount64(w)
+	}
+}
+
+//
+// Run container, for chunks of consecutive values,
+// it is created by Optimize and converted back on change
+//
+
+// run is a range [start, last]
+type run struct {
+	start, last uint16
+}
+
+type runContainer struct {
+	runs []run
+	n    int
+}
+
+func (c *runContainer) add(x uint16) container {
+	if c.has(x) {
+		return c
+	}
+	return expand(c).add(x)
+}
+
+func (c *runContainer) remove(x uint16) container {
+	if !c.has(x) {
+		return c
+	}
+	return expand(c).remove(x)
+}
+
+// find returns index of the last run starting at or before {x}, or -1
+func (c *runContainer) find(x uint16) int {
+	return sort.Search(len(c.runs), func(i int) bool { return c.runs[i].start > x }) - 1
+}
+
+func (c *runContainer) has(x uint16) bool {
+	i := c.find(x)
+	return i >= 0 && x <= c.runs[i].last
+}
+
+func (c *runContainer) card() int {
+	return c.n
+}
+
+func (c *runContainer) rank(x uint16) int {
+	i := c.find(x)
+	if i < 0 {
+		return 0
+	}
+	n := 0
+	for _, r := range c.runs[:i] {
+		n += int(r.last-r.start) + 1
+	}
+	last := c.runs[i].last
+	if x < last {
+		last = x
+	}
+	return n + int(last-c.runs[i].start) + 1
+}
+
+func (c *runContainer) selectAt(i int) uint16 {
+	for _, r := range c.runs {
+		size := int(r.last-r.start) + 1
+		if i < size {
+			return r.start + uint16(i)
+		}
+		i -= size
+	}
+	panic("set: select out of range")
+}
+
+func (c *runContainer) iterate(f func(x uint16) bool) bool {
+	for _, r := range c.runs {
+		for x := int(r.start); x <= int(r.last); x++ {
+			if !f(uint16(x)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (c *runContainer) clone() container {
+	return &runContainer{runs: append([]run(nil), c.runs...), n: c.n}
+}
+
+//
+// Conversions
+//
+
+func toBitmap(c container) *bitmapContainer {
+	if b, ok := c.(*bitmapContainer); ok {
+		return b
+	}
+	b := &bitmapContainer{}
+	c.iterate(func(x uint16) bool {
+		b.words[x/64] |= 1 << (x % 64)
+		return true
+	})
+	b.n = c.card()
+	return b
+}
+
+func toArray(c container) *arrayContainer {
+	if a, ok := c.(*arrayContainer); ok {
+		return a
+	}
+	a := &arrayContainer{vals: make([]uint16, 0, c.card())}
+	c.iterate(func(x uint16) bool {
+		a.vals = append(a.vals, x)
+		return true
+	})
+	return a
+}
+
+func toRuns(c container) *runContainer {
+	r := &runContainer{n: c.card()}
+	c.iterate(func(x uint16) bool {
+		if last := len(r.runs) - 1; last >= 0 && r.runs[last].last+1 == x {
+			r.runs[last].last = x
+		} else {
+			r.runs = append(r.runs, run{x, x})
+		}
+		return true
+	})
+	return r
+}
+
+// expand converts run container to a mutable kind
+func expand(c *runContainer) container {
+	if c.n < arrayMaxSize {
+		return toArray(c)
+	}
+	return toBitmap(c)
+}
+
+// shrink converts bitmap to array if the array is smaller
+func shrink(c *bitmapContainer) container {
+	if c.n <= arrayMaxSize {
+		return toArray(c)
+	}
+	return c
+}
+
+// optimize returns the smallest representation of {c}
+func optimize(c container) container {
+	runs := toRuns(c)
+	runSize := 4 * len(runs.runs)
+	if c.card() <= arrayMaxSize {
+		if runSize < 2*c.card() {
+			return runs
+		}
+		return toArray(c)
+	}
+	if runSize < 8*bitmapWords {
+		return runs
+	}
+	return toBitmap(c)
+}
+
+//
+// Algebra, results don't share memory with operands
+// and may be empty, but never nil
+//
+
+func and(a, b container) container {
+	if a.card() > b.card() {
+		a, b = b, a
+	}
+	if _, ok := a.(*bitmapContainer); !ok {
+		// iterate over the smaller one
+		return filter(a, b, true)
+	}
+	ab, bb := toBitmap(a), toBitmap(b)
+	res := &bitmapContainer{}
+	for i := range res.words {
+		res.words[i] = ab.words[i] & bb.words[i]
+	}
+	res.recount()
+	return shrink(res)
+}
+
+func or(a, b container) container {
+	if a.card()+b.card() <= arrayMaxSize {
+		aa, ba := toArray(a), toArray(b)
+		return &arrayContainer{vals: mergeSorted(aa.vals, ba.vals)}
+	}
+	ab, bb := toBitmap(a), toBitmap(b)
+	res := &bitmapContainer{}
+	for i := range res.words {
+		res.words[i] = ab.words[i] | bb.words[i]
+	}
+	res.recount()
+	return shrink(res)
+}
+
+func andNot(a, b container) container {
+	if _, ok := a.(*bitmapContainer); !ok {
+		return filter(a, b, false)
+	}
+	ab, bb := toBitmap(a), toBitmap(b)
+	res := &bitmapContainer{}
+	for i := range res.words {
+		res.words[i] = ab.words[i] &^ bb.words[i]
+	}
+	res.recount()
+	return shrink(res)
+}
+
+func xor(a, b container) container {
+	ab, bb := toBitmap(a), toBitmap(b)
+	res := &bitmapContainer{}
+	for i := range res.words {
+		res.words[i] = ab.words[i] ^ bb.words[i]
+	}
+	res.recount()
+	return shrink(res)
+}
+
+// filter returns elements of {a} present in {b} if {present} is true,
+// or missing in {b} otherwise
+func filter(a, b container, present bool) container {
+	res := &arrayContainer{}
+	a.iterate(func(x uint16) bool {
+		if b.has(x) == present {
+			res.vals = append(res.vals, x)
+		}
+		return true
+	})
+	if res.card() > arrayMaxSize {
+		return toBitmap(res)
+	}
+	return res
+}
+
+// mergeSorted returns sorted union of sorted slices
+func mergeSorted(a, b []uint16) []uint16 {
+	res := make([]uint16, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0] < b[0]:
+			res, a = append(res, a[0]), a[1:]
+		case a[0] > b[0]:
+			res, b = append(res, b[0]), b[1:]
+		default:
+			res, a, b = append(res, a[0]), a[1:], b[1:]
+		}
+	}
+	res = append(res, a...)
+	return append(res, b...)
+}
@@ -0,0 +1,87 @@
+package set
+
+import (
+	"io"
+
+	"geekbrains/examples/internal/setcodec"
+)
+
+// Sets are saved in setcodec format, with a versioned header and checksum.
+// Unmarshal and ReadFrom replace elements at once
+// and leave the set unchanged on error
+
+// replace sets {values} as new elements
+func (ms *SetInt) replace(values []int) error {
+	data := make(map[int]struct{}, len(values))
+	for _, val := range values {
+		data[val] = struct{}{}
+	}
+
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	ms.data = data
+	return nil
+}
+
+func (ms *SetInt) MarshalBinary() ([]byte, error) {
+	return setcodec.Marshal(ms.Values())
+}
+
+func (ms *SetInt) UnmarshalBinary(data []byte) error {
+	return setcodec.UnmarshalTo(data, ms.replace)
+}
+
+func (ms *SetInt) MarshalJSON() ([]byte, error) {
+	return setcodec.MarshalJSON(ms.Values())
+}
+
+func (ms *SetInt) UnmarshalJSON(data []byte) error {
+	return setcodec.UnmarshalJSONTo(data, ms.replace)
+}
+
+func (ms *SetInt) WriteTo(w io.Writer) (int64, error) {
+	return setcodec.Write(w, ms.Values())
+}
+
+func (ms *SetInt) ReadFrom(r io.Reader) (int64, error) {
+	return setcodec.ReadTo(r, ms.replace)
+}
+
+// replace sets {values} as new elements
+func (bs *BitmapSetInt) replace(values []int) error {
+	loaded := NewBitmapSetInt()
+	for _, val := range values {
+		loaded.Add(val)
+	}
+
+	bs.mx.Lock()
+	defer bs.mx.Unlock()
+
+	bs.keys, bs.conts = loaded.keys, loaded.conts
+	return nil
+}
+
+func (bs *BitmapSetInt) MarshalBinary() ([]byte, error) {
+	return setcodec.Marshal(bs.Values())
+}
+
+func (bs *BitmapSetInt) UnmarshalBinary(data []byte) error {
+	return setcodec.UnmarshalTo(data, bs.replace)
+}
+
+func (bs *BitmapSetInt) MarshalJSON() ([]byte, error) {
+	return setcodec.MarshalJSON(bs.Values())
+}
+
+func (bs *BitmapSetInt) UnmarshalJSON(data []byte) error {
+	return setcodec.UnmarshalJSONTo(data, bs.replace)
+}
+
+func (bs *BitmapSetInt) WriteTo(w io.Writer) (int64, error) {
+	return setcodec.Write(w, bs.Values())
+}
+
+func (bs *BitmapSetInt) ReadFrom(r io.Reader) (int64, error) {
+	return setcodec.ReadTo(r, bs.replace)
+}
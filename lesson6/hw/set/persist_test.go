@@ -0,0 +1,64 @@
+package set
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"geekbrains/examples/internal/setcodec"
+)
+
+func TestPersist(t *testing.T) {
+	src := newSet(-5, 0, 7, 1<<40)
+	expect := fmt.Sprint(src.SortedValues())
+
+	var file bytes.Buffer
+	if _, err := src.WriteTo(&file); err != nil {
+		t.Fatal(err)
+	}
+	// both implementations share the format
+	dst := newBitmap(1)
+	if _, err := dst.ReadFrom(bytes.NewReader(file.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(dst.Values()); got != expect {
+		t.Errorf("bitmap from file: got %s, expect %s", got, expect)
+	}
+
+	js, err := json.Marshal(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := NewSetInt(0)
+	if err := json.Unmarshal(js, back); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(back.SortedValues()); got != expect {
+		t.Errorf("set from json: got %s, expect %s", got, expect)
+	}
+
+	bin, _ := back.MarshalBinary()
+	if !bytes.Equal(bin, file.Bytes()) {
+		t.Errorf("equal sets should give equal data")
+	}
+}
+
+func TestPersistCorrupt(t *testing.T) {
+	data, _ := newSet(1, 2, 3).MarshalBinary()
+	data[len(data)-6] ^= 1
+
+	s, bs := newSet(42), newBitmap(42)
+	for name, err := range map[string]error{
+		"set":    s.UnmarshalBinary(data),
+		"bitmap": bs.UnmarshalBinary(data),
+	} {
+		if !errors.Is(err, setcodec.ErrCorrupt) {
+			t.Errorf("%s: got %v, expect ErrCorrupt", name, err)
+		}
+	}
+	if fmt.Sprint(s.Values(), bs.Values()) != "[42] [42]" {
+		t.Errorf("sets changed by failed load")
+	}
+}
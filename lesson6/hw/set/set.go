@@ -1,15 +1,38 @@
 package set
 
-import "sync"
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// IntSet is the common API of SetInt and BitmapSetInt
+type IntSet interface {
+	Add(elem int)
+	Has(elem int) bool
+	Len() int
+	Values() []int
+	SortedValues() []int
+}
+
+var (
+	_ IntSet = &SetInt{}
+	_ IntSet = &BitmapSetInt{}
+)
+
+// lastID is used to give every set an id,
+// which defines locking order of two sets
+var lastID uint64
 
 type SetInt struct {
 	mx   sync.RWMutex
 	data map[int]struct{}
+	id   uint64
 }
 
 func NewSetInt(size int) *SetInt {
 	data := make(map[int]struct{}, size)
-	return &SetInt{data: data}
+	return &SetInt{data: data, id: atomic.AddUint64(&lastID, 1)}
 }
 
 func (ms *SetInt) Add(elem int) {
@@ -43,3 +66,10 @@ func (ms *SetInt) Values() (values []int) {
 	}
 	return values
 }
+
+// SortedValues returns values in ascending order
+func (ms *SetInt) SortedValues() []int {
+	values := ms.Values()
+	sort.Ints(values)
+	return values
+}
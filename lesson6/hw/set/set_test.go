@@ -0,0 +1,103 @@
+package set
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func newSet(values ...int) *SetInt {
+	s := NewSetInt(len(values))
+	for _, val := range values {
+		s.Add(val)
+	}
+	return s
+}
+
+func TestAlgebra(t *testing.T) {
+	a := newSet(1, 2, 3, 4)
+	b := newSet(3, 4, 5)
+
+	cases := []struct {
+		name   string
+		got    *SetInt
+		expect string
+	}{
+		{"union", a.Union(b), "[1 2 3 4 5]"},
+		{"intersect", a.Intersect(b), "[3 4]"},
+		{"difference", a.Difference(b), "[1 2]"},
+		{"symmetric difference", a.SymmetricDifference(b), "[1 2 5]"},
+		{"union parallel", a.UnionParallel(b, 3), "[1 2 3 4 5]"},
+		{"intersect parallel", a.IntersectParallel(b, 3), "[3 4]"},
+		{"difference parallel", a.DifferenceParallel(b, 3), "[1 2]"},
+		{"symmetric difference parallel", a.SymmetricDifferenceParallel(b, 3), "[1 2 5]"},
+		{"with itself", a.Difference(a), "[]"},
+		{"with empty", a.Union(NewSetInt(0)), "[1 2 3 4]"},
+	}
+	for _, c := range cases {
+		if got := fmt.Sprint(c.got.SortedValues()); got != c.expect {
+			t.Errorf("%s: got %s, expect %s", c.name, got, c.expect)
+		}
+	}
+	// operands are not changed
+	if a.Len() != 4 || b.Len() != 3 {
+		t.Errorf("operands changed: %v %v", a.SortedValues(), b.SortedValues())
+	}
+}
+
+func TestSubsetEqual(t *testing.T) {
+	a := newSet(1, 2)
+	b := newSet(1, 2, 3)
+
+	if !a.IsSubset(b) || b.IsSubset(a) {
+		t.Errorf("wrong subset check")
+	}
+	if !a.IsSubset(a) || !NewSetInt(0).IsSubset(a) {
+		t.Errorf("set and empty set should be subsets")
+	}
+	if a.Equal(b) || !a.Equal(newSet(2, 1)) || !a.Equal(a) {
+		t.Errorf("wrong equal check")
+	}
+	if newSet(1, 4).IsSubset(b) {
+		t.Errorf("set with extra element is not a subset")
+	}
+}
+
+func TestParallelLarge(t *testing.T) {
+	a, b := NewSetInt(0), NewSetInt(0)
+	for i := 0; i < 10000; i++ {
+		a.Add(i)
+		b.Add(i * 2)
+	}
+	for _, workers := range []int{0, 1, 7, 64} {
+		if !a.IntersectParallel(b, workers).Equal(a.Intersect(b)) {
+			t.Errorf("intersect with %d workers differs", workers)
+		}
+		if !a.SymmetricDifferenceParallel(b, workers).Equal(a.SymmetricDifference(b)) {
+			t.Errorf("symmetric difference with %d workers differs", workers)
+		}
+	}
+}
+
+// TestLockOrder runs opposite operations with concurrent writers,
+// it hangs if sets are locked in inconsistent order
+func TestLockOrder(t *testing.T) {
+	a, b := newSet(1, 2), newSet(2, 3)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(4)
+		go func() { defer wg.Done(); a.Union(b) }()
+		go func() { defer wg.Done(); b.Intersect(a) }()
+		go func(i int) { defer wg.Done(); a.Add(i) }(i)
+		go func(i int) { defer wg.Done(); b.Add(i) }(i)
+	}
+	wg.Wait()
+}
+
+func TestSortedValues(t *testing.T) {
+	s := newSet(5, -1, 3, 0)
+	if got := fmt.Sprint(s.SortedValues()); got != "[-1 0 3 5]" {
+		t.Errorf("sorted values: got %s", got)
+	}
+}
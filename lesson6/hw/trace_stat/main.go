@@ -0,0 +1,55 @@
+// trace_stat prints summary of runtime/trace output, e.g.
+//
+//	go run ./lesson6/hw/mutex_trace 2> mutex.trace
+//	go run ./lesson6/hw/trace_stat -json mutex.trace > mutex.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"geekbrains/examples/lesson6/hw/tracestat"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "print report as JSON")
+	top := flag.Int("top", 20, "show only {top} blocking sites, 0 means all")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [trace file]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var in io.Reader = os.Stdin
+	if path := flag.Arg(0); path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	report, err := tracestat.Analyze(in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *top > 0 && len(report.Blocking) > *top {
+		report.Blocking = report.Blocking[:*top]
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
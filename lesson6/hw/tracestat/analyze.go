@@ -0,0 +1,328 @@
+package tracestat
+
+import (
+	"sort"
+	"time"
+)
+
+// goroutine and P statuses of GoStatus and ProcStatus events
+const (
+	goRunnable  = 1
+	goRunning   = 2
+	goSyscall   = 3
+	procRunning = 1
+)
+
+type gState struct {
+	running  bool
+	runStart uint64
+	p        int64
+
+	runnable      bool
+	runnableSince uint64
+
+	blocked    bool
+	blockStart uint64
+	blockKey   blockKey
+}
+
+type mState struct {
+	g    uint64
+	hasG bool
+	p    int64
+
+	inSTW    bool
+	stwStart uint64
+	stwKind  string
+}
+
+type blockKey struct {
+	reason, site string
+}
+
+type analyzer struct {
+	t  *rawTrace
+	gs map[uint64]*gState
+	ms map[uint64]*mState
+
+	blocks    map[blockKey]*BlockStat
+	latencies []time.Duration
+	busy      map[int64]time.Duration
+	pauses    []time.Duration
+	byKind    map[string]*PauseStat
+
+	gcCycles  int
+	gcTime    time.Duration
+	gcStart   uint64
+	gcRunning bool
+}
+
+func analyze(t *rawTrace) *Report {
+	a := &analyzer{
+		t:      t,
+		gs:     map[uint64]*gState{},
+		ms:     map[uint64]*mState{},
+		blocks: map[blockKey]*BlockStat{},
+		busy:   map[int64]time.Duration{},
+		byKind: map[string]*PauseStat{},
+	}
+
+	// batches of different Ms are interleaved,
+	// events within a batch are already ordered
+	sort.SliceStable(t.events, func(i, j int) bool { return t.events[i].time < t.events[j].time })
+	for _, ev := range t.events {
+		a.handle(ev)
+	}
+
+	r := &Report{GoVersion: t.version}
+	if n := len(t.events); n > 0 {
+		r.Duration = a.dur(t.events[0].time, t.events[n-1].time)
+		// goroutines still running at the end
+		for _, g := range a.gs {
+			a.stopRunning(g, t.events[n-1].time)
+		}
+	}
+
+	for _, b := range a.blocks {
+		r.Blocking = append(r.Blocking, *b)
+	}
+	sort.Slice(r.Blocking, func(i, j int) bool {
+		bi, bj := r.Blocking[i], r.Blocking[j]
+		if bi.Total != bj.Total {
+			return bi.Total > bj.Total
+		}
+		if bi.Reason != bj.Reason {
+			return bi.Reason < bj.Reason
+		}
+		return bi.Site < bj.Site
+	})
+
+	r.SchedLatency = newDistribution(a.latencies)
+
+	for p, busy := range a.busy {
+		stat := ProcStat{P: int(p), Busy: busy}
+		if r.Duration > 0 {
+			stat.Utilization = float64(busy) / float64(r.Duration)
+		}
+		r.Procs = append(r.Procs, stat)
+	}
+	sort.Slice(r.Procs, func(i, j int) bool { return r.Procs[i].P < r.Procs[j].P })
+
+	r.GC = GCStat{
+		Cycles: a.gcCycles,
+		Time:   a.gcTime,
+		Pauses: newDistribution(a.pauses),
+	}
+	for _, k := range a.byKind {
+		r.GC.ByKind = append(r.GC.ByKind, *k)
+	}
+	sort.Slice(r.GC.ByKind, func(i, j int) bool { return r.GC.ByKind[i].Kind < r.GC.ByKind[j].Kind })
+
+	return r
+}
+
+// dur converts ticks between {from} and {to} to duration
+func (a *analyzer) dur(from, to uint64) time.Duration {
+	if to < from {
+		return 0
+	}
+	d, freq := to-from, a.t.freq
+	// split to avoid overflow on long traces
+	return time.Duration(d/freq*1e9 + d%freq*1e9/freq)
+}
+
+func (a *analyzer) g(id uint64) *gState {
+	g, ok := a.gs[id]
+	if !ok {
+		g = &gState{p: -1}
+		a.gs[id] = g
+	}
+	return g
+}
+
+func (a *analyzer) m(id uint64) *mState {
+	m, ok := a.ms[id]
+	if !ok {
+		m = &mState{p: -1}
+		a.ms[id] = m
+	}
+	return m
+}
+
+// current returns goroutine running on {m}
+func (a *analyzer) current(m *mState) *gState {
+	if !m.hasG {
+		return nil
+	}
+	return a.g(m.g)
+}
+
+func (a *analyzer) handle(ev event) {
+	m := a.m(ev.m)
+	args := ev.args
+
+	switch ev.typ {
+	case evProcStart:
+		m.p = int64(args[1])
+	case evProcStop:
+		m.p = -1
+	case evProcSteal:
+		if victim := a.m(args[3]); victim.p == int64(args[1]) {
+			victim.p = -1
+		}
+	case evProcStatus:
+		if args[2] == procRunning {
+			m.p = int64(args[1])
+		}
+
+	case evGoStatus, evGoStatusStack:
+		// statuses are repeated at every generation,
+		// they matter only for goroutines seen first time
+		if _, known := a.gs[args[1]]; known {
+			return
+		}
+		g := a.g(args[1])
+		switch args[3] {
+		case goRunnable:
+			g.runnable, g.runnableSince = true, ev.time
+		case goRunning:
+			owner := a.m(args[2])
+			owner.g, owner.hasG = args[1], true
+			g.running, g.runStart, g.p = true, ev.time, owner.p
+		case goSyscall:
+			owner := a.m(args[2])
+			owner.g, owner.hasG = args[1], true
+		}
+
+	case evGoCreate:
+		g := a.g(args[1])
+		g.runnable, g.runnableSince = true, ev.time
+	case evGoCreateBlocked:
+		a.g(args[1])
+	case evGoCreateSys:
+		a.g(args[1])
+		m.g, m.hasG = args[1], true
+
+	case evGoStart:
+		a.start(m, args[1], ev.time, true)
+	case evGoStop:
+		if g := a.current(m); g != nil {
+			a.stopRunning(g, ev.time)
+			g.runnable, g.runnableSince = true, ev.time
+		}
+		m.hasG = false
+	case evGoBlock:
+		if g := a.current(m); g != nil {
+			a.stopRunning(g, ev.time)
+			g.blocked, g.blockStart = true, ev.time
+			g.blockKey = blockKey{
+				reason: a.t.strings[genKey{ev.gen, args[1]}],
+				site:   a.t.site(ev.gen, args[2]),
+			}
+		}
+		m.hasG = false
+	case evGoUnblock:
+		g := a.g(args[1])
+		a.unblock(g, ev.time)
+		g.runnable, g.runnableSince = true, ev.time
+	case evGoDestroy, evGoDestroySy:
+		if g := a.current(m); g != nil {
+			a.stopRunning(g, ev.time)
+			delete(a.gs, m.g)
+		}
+		m.hasG = false
+
+	case evSyscall:
+		if g := a.current(m); g != nil {
+			a.stopRunning(g, ev.time)
+		}
+	case evSyscallEnd:
+		if g := a.current(m); g != nil {
+			g.running, g.runStart, g.p = true, ev.time, m.p
+		}
+	case evSyscallBlk:
+		// returned from syscall without P, waits for GoStart
+		if g := a.current(m); g != nil {
+			g.runnable, g.runnableSince = true, ev.time
+		}
+		m.hasG = false
+
+	case evGoSwitch, evGoSwitchDestroy:
+		if g := a.current(m); g != nil {
+			a.stopRunning(g, ev.time)
+			if ev.typ == evGoSwitchDestroy {
+				delete(a.gs, m.g)
+			}
+		}
+		// coroutine switch isn't a scheduling
+		a.start(m, args[1], ev.time, false)
+
+	case evSTWBegin:
+		m.inSTW, m.stwStart = true, ev.time
+		m.stwKind = a.t.strings[genKey{ev.gen, args[1]}]
+	case evSTWEnd:
+		if m.inSTW {
+			pause := a.dur(m.stwStart, ev.time)
+			a.pauses = append(a.pauses, pause)
+			k, ok := a.byKind[m.stwKind]
+			if !ok {
+				k = &PauseStat{Kind: m.stwKind}
+				a.byKind[m.stwKind] = k
+			}
+			k.Count++
+			k.Total += pause
+			m.inSTW = false
+		}
+
+	case evGCBegin:
+		a.gcStart, a.gcRunning = ev.time, true
+	case evGCEnd:
+		if a.gcRunning {
+			a.gcCycles++
+			a.gcTime += a.dur(a.gcStart, ev.time)
+			a.gcRunning = false
+		}
+	}
+}
+
+// start makes {id} running on {m}
+func (a *analyzer) start(m *mState, id uint64, now uint64, scheduled bool) {
+	g := a.g(id)
+	// unblock may be missed, if goroutine was woken up
+	// by the runtime before the trace started
+	a.unblock(g, now)
+	if g.runnable && scheduled {
+		a.latencies = append(a.latencies, a.dur(g.runnableSince, now))
+	}
+	g.runnable = false
+	g.running, g.runStart, g.p = true, now, m.p
+	m.g, m.hasG = id, true
+}
+
+func (a *analyzer) unblock(g *gState, now uint64) {
+	if !g.blocked {
+		return
+	}
+	g.blocked = false
+	d := a.dur(g.blockStart, now)
+	b, ok := a.blocks[g.blockKey]
+	if !ok {
+		b = &BlockStat{Reason: g.blockKey.reason, Site: g.blockKey.site}
+		a.blocks[g.blockKey] = b
+	}
+	b.Count++
+	b.Total += d
+	if d > b.Max {
+		b.Max = d
+	}
+}
+
+func (a *analyzer) stopRunning(g *gState, now uint64) {
+	if !g.running {
+		return
+	}
+	g.running = false
+	if g.p >= 0 {
+		a.busy[g.p] += a.dur(g.runStart, now)
+	}
+}
@@ -0,0 +1,304 @@
+package tracestat
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Wire format of execution traces written by Go 1.22 and later,
+// see internal/trace/tracev2 in Go sources. Only events used
+// by the analysis are named, others are just skipped.
+// Each Go release may change the format, a new one is added to specLimits
+
+// ErrFormat is returned for data which isn't a supported Go trace
+var ErrFormat = errors.New("tracestat: unsupported trace format")
+
+type eventType uint8
+
+const (
+	evEventBatch eventType = 1
+	evStack      eventType = 3
+	evString     eventType = 5
+	evFrequency  eventType = 8
+
+	evProcStart   eventType = 10
+	evProcStop    eventType = 11
+	evProcSteal   eventType = 12
+	evProcStatus  eventType = 13
+	evGoCreate    eventType = 14
+	evGoCreateSys eventType = 15
+	evGoStart     eventType = 16
+	evGoDestroy   eventType = 17
+	evGoDestroySy eventType = 18
+	evGoStop      eventType = 19
+	evGoBlock     eventType = 20
+	evGoUnblock   eventType = 21
+	evSyscall     eventType = 22
+	evSyscallEnd  eventType = 23
+	evSyscallBlk  eventType = 24
+	evGoStatus    eventType = 25
+	evSTWBegin    eventType = 26
+	evSTWEnd      eventType = 27
+	evGCBegin     eventType = 29
+	evGCEnd       eventType = 30
+
+	evGoSwitch        eventType = 45
+	evGoSwitchDestroy eventType = 46
+	evGoCreateBlocked eventType = 47
+	evGoStatusStack   eventType = 48
+	evExpBatch        eventType = 49
+)
+
+// eventSpec is a wire layout of event type
+type eventSpec struct {
+	args  int
+	timed bool // the first arg is time delta
+	stack bool // followed by frames
+	data  bool // followed by length and bytes
+}
+
+// specs are indexed by event type, a version supports
+// event types below its limit in specLimits
+var specs = []eventSpec{
+	1:  {args: 4},              // EventBatch
+	2:  {},                     // Stacks
+	3:  {args: 2, stack: true}, // Stack
+	4:  {},                     // Strings
+	5:  {args: 1, data: true},  // String
+	6:  {},                     // CPUSamples
+	7:  {args: 5},              // CPUSample
+	8:  {args: 1},              // Frequency
+	9:  {args: 3, timed: true}, // ProcsChange
+	10: {args: 3, timed: true}, // ProcStart
+	11: {args: 1, timed: true}, // ProcStop
+	12: {args: 4, timed: true}, // ProcSteal
+	13: {args: 3, timed: true}, // ProcStatus
+	14: {args: 4, timed: true}, // GoCreate
+	15: {args: 2, timed: true}, // GoCreateSyscall
+	16: {args: 3, timed: true}, // GoStart
+	17: {args: 1, timed: true}, // GoDestroy
+	18: {args: 1, timed: true}, // GoDestroySyscall
+	19: {args: 3, timed: true}, // GoStop
+	20: {args: 3, timed: true}, // GoBlock
+	21: {args: 4, timed: true}, // GoUnblock
+	22: {args: 3, timed: true}, // GoSyscallBegin
+	23: {args: 1, timed: true}, // GoSyscallEnd
+	24: {args: 1, timed: true}, // GoSyscallEndBlocked
+	25: {args: 4, timed: true}, // GoStatus
+	26: {args: 3, timed: true}, // STWBegin
+	27: {args: 1, timed: true}, // STWEnd
+	28: {args: 2, timed: true}, // GCActive
+	29: {args: 3, timed: true}, // GCBegin
+	30: {args: 2, timed: true}, // GCEnd
+	31: {args: 2, timed: true}, // GCSweepActive
+	32: {args: 2, timed: true}, // GCSweepBegin
+	33: {args: 3, timed: true}, // GCSweepEnd
+	34: {args: 2, timed: true}, // GCMarkAssistActive
+	35: {args: 2, timed: true}, // GCMarkAssistBegin
+	36: {args: 1, timed: true}, // GCMarkAssistEnd
+	37: {args: 2, timed: true}, // HeapAlloc
+	38: {args: 2, timed: true}, // HeapGoal
+	39: {args: 2, timed: true}, // GoLabel
+	40: {args: 5, timed: true}, // UserTaskBegin
+	41: {args: 3, timed: true}, // UserTaskEnd
+	42: {args: 4, timed: true}, // UserRegionBegin
+	43: {args: 4, timed: true}, // UserRegionEnd
+	44: {args: 5, timed: true}, // UserLog
+	45: {args: 3, timed: true}, // GoSwitch
+	46: {args: 3, timed: true}, // GoSwitchDestroy
+	47: {args: 4, timed: true}, // GoCreateBlocked
+	48: {args: 5, timed: true}, // GoStatusStack
+	49: {args: 4, data: true},  // ExperimentalBatch
+	50: {},                     // Sync
+	51: {args: 4, timed: true}, // ClockSnapshot
+	52: {},                     // EndOfGeneration
+}
+
+// specLimits maps trace version (minor Go version)
+// to the first event type it doesn't support
+var specLimits = map[int]eventType{
+	22: 45,
+	23: 50,
+	25: 52,
+	26: 53,
+}
+
+// supportedVersions lists trace versions in specLimits
+func supportedVersions() string {
+	versions := make([]int, 0, len(specLimits))
+	for v := range specLimits {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	list := make([]string, len(versions))
+	for i, v := range versions {
+		list[i] = fmt.Sprintf("go1.%d", v)
+	}
+	return strings.Join(list, ", ")
+}
+
+// event is a decoded timed event
+type event struct {
+	typ  eventType
+	gen  uint64
+	m    uint64
+	time uint64 // trace clock ticks
+	args []uint64
+}
+
+// frame is a stack frame, names are string ids
+type frame struct {
+	fn, file, line uint64
+}
+
+// genKey identifies a string or a stack, their ids are per generation
+type genKey struct {
+	gen, id uint64
+}
+
+// rawTrace is a parsed trace before analysis
+type rawTrace struct {
+	version int
+	freq    uint64 // ticks per second
+	events  []event
+	strings map[genKey]string
+	stacks  map[genKey][]frame
+}
+
+// byteCounter counts bytes read to find batch ends
+type byteCounter struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (bc *byteCounter) ReadByte() (byte, error) {
+	b, err := bc.r.ReadByte()
+	if err == nil {
+		bc.n++
+	}
+	return b, err
+}
+
+func parse(r io.Reader) (*rawTrace, error) {
+	br := &byteCounter{r: bufio.NewReader(r)}
+
+	t := &rawTrace{
+		strings: map[genKey]string{},
+		stacks:  map[genKey][]frame{},
+	}
+	var header [16]byte
+	for i := range header {
+		b, err := br.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: no header", ErrFormat)
+		}
+		header[i] = b
+	}
+	if _, err := fmt.Sscanf(string(header[:]), "go 1.%d trace\x00\x00\x00", &t.version); err != nil {
+		return nil, fmt.Errorf("%w: bad header %q", ErrFormat, header)
+	}
+	limit, ok := specLimits[t.version]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported trace version go1.%d, supported: %s",
+			ErrFormat, t.version, supportedVersions())
+	}
+
+	// current batch
+	var gen, m, now uint64
+	var batchEnd int64
+
+	readArgs := func(n int) ([]uint64, error) {
+		args := make([]uint64, n)
+		for i := range args {
+			v, err := binary.ReadUvarint(br)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		return args, nil
+	}
+	readData := func() ([]byte, error) {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, n)
+		for i := range data {
+			if data[i], err = br.ReadByte(); err != nil {
+				return nil, err
+			}
+		}
+		return data, nil
+	}
+
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		typ := eventType(b)
+		if typ == 0 || typ >= limit {
+			return nil, fmt.Errorf("%w: unknown event %d at byte %d", ErrFormat, typ, br.n-1)
+		}
+		spec := specs[typ]
+
+		args, err := readArgs(spec.args)
+		if err != nil {
+			return nil, fmt.Errorf("%w: truncated event: %v", ErrFormat, err)
+		}
+		if spec.stack {
+			frames := make([]frame, args[1])
+			for i := range frames {
+				f, err := readArgs(4)
+				if err != nil {
+					return nil, fmt.Errorf("%w: truncated stack: %v", ErrFormat, err)
+				}
+				// f[0] is pc
+				frames[i] = frame{fn: f[1], file: f[2], line: f[3]}
+			}
+			t.stacks[genKey{gen, args[0]}] = frames
+		}
+		var data []byte
+		if spec.data {
+			if data, err = readData(); err != nil {
+				return nil, fmt.Errorf("%w: truncated data: %v", ErrFormat, err)
+			}
+		}
+
+		switch {
+		case typ == evEventBatch:
+			gen, m, now = args[0], args[1], args[2]
+			batchEnd = br.n + int64(args[3])
+			continue
+		case typ == evExpBatch:
+			// experimental data is skipped with its batch
+			continue
+		case typ == evString:
+			t.strings[genKey{gen, args[0]}] = string(data)
+		case typ == evFrequency:
+			if t.freq == 0 {
+				t.freq = args[0]
+			}
+		case spec.timed:
+			if br.n > batchEnd {
+				return nil, fmt.Errorf("%w: timed event out of batch at byte %d", ErrFormat, br.n)
+			}
+			now += args[0]
+			t.events = append(t.events, event{typ: typ, gen: gen, m: m, time: now, args: args})
+		}
+	}
+
+	if t.freq == 0 {
+		return nil, fmt.Errorf("%w: no clock frequency", ErrFormat)
+	}
+	return t, nil
+}
@@ -0,0 +1,62 @@
+package tracestat
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// WriteText writes human readable report to {w}
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "trace of go 1.%d, duration %v\n", r.GoVersion, r.Duration)
+
+	fmt.Fprintf(tw, "\nBlocking:\n")
+	if len(r.Blocking) == 0 {
+		fmt.Fprintf(tw, "  none\n")
+	} else {
+		fmt.Fprintf(tw, "  TOTAL\tCOUNT\tMAX\tREASON\tSITE\n")
+		for _, b := range r.Blocking {
+			fmt.Fprintf(tw, "  %v\t%d\t%v\t%s\t%s\n", b.Total, b.Count, b.Max, b.Reason, b.Site)
+		}
+	}
+
+	fmt.Fprintf(tw, "\nScheduling latency:\n")
+	writeDistribution(tw, r.SchedLatency)
+
+	fmt.Fprintf(tw, "\nProcs:\n")
+	for _, p := range r.Procs {
+		fmt.Fprintf(tw, "  P%d\tbusy %v\t%.1f%%\n", p.P, p.Busy, 100*p.Utilization)
+	}
+
+	fmt.Fprintf(tw, "\nGC: %d cycles, %v\n", r.GC.Cycles, r.GC.Time)
+	fmt.Fprintf(tw, "Stop-the-world pauses:\n")
+	writeDistribution(tw, r.GC.Pauses)
+	if len(r.GC.ByKind) > 0 {
+		fmt.Fprintf(tw, "By kind:\n")
+	}
+	for _, k := range r.GC.ByKind {
+		fmt.Fprintf(tw, "  %s\t%d\t%v\n", k.Kind, k.Count, k.Total)
+	}
+
+	return tw.Flush()
+}
+
+func writeDistribution(w io.Writer, d Distribution) {
+	if d.Count == 0 {
+		fmt.Fprintf(w, "  none\n")
+		return
+	}
+	fmt.Fprintf(w, "  count %d, mean %v, p50 %v, p90 %v, p99 %v, max %v\n",
+		d.Count, d.Mean, d.P50, d.P90, d.P99, d.Max)
+	for _, b := range d.Buckets {
+		bound := "<= " + b.Le.String()
+		if b.Le == 0 {
+			bound = "> " + bucketBounds[len(bucketBounds)-1].String()
+		}
+		bar := strings.Repeat("#", (b.Count*40+d.Count-1)/d.Count)
+		fmt.Fprintf(w, "  %s\t%d\t%s\n", bound, b.Count, bar)
+	}
+}
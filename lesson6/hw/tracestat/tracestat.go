@@ -0,0 +1,157 @@
+// Package tracestat summarizes runtime/trace output: blocking time
+// per sync primitive and call site, goroutine scheduling latency,
+// P utilization and GC pauses. Reports are printed as text or JSON,
+// so traces of different implementations can be compared in CI.
+//
+// Traces are decoded by a small reader of the wire format instead of
+// golang.org/x/exp/trace: its versions which read current traces need
+// go 1.26 in go.mod, and raising it from 1.21 turns on per-iteration
+// loop variables, which breaks lessons showing the shared one
+// (e.g. lesson5/error_group). The format changes between Go releases,
+// a trace of a version missing in specLimits fails with ErrFormat
+// naming the supported versions, it is never read as a known one
+package tracestat
+
+import (
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Report is a summary of a trace
+type Report struct {
+	// GoVersion is the minor Go version of trace format
+	GoVersion int           `json:"go_version"`
+	Duration  time.Duration `json:"duration_ns"`
+	// Blocking is sorted by total time, the longest first
+	Blocking     []BlockStat  `json:"blocking"`
+	SchedLatency Distribution `json:"sched_latency"`
+	Procs        []ProcStat   `json:"procs"`
+	GC           GCStat       `json:"gc"`
+}
+
+// BlockStat is time goroutines spent blocked for one reason at one place
+type BlockStat struct {
+	// Reason is a runtime block reason, like "sync" or "chan receive"
+	Reason string `json:"reason"`
+	// Site is the first frame of blocked goroutine outside
+	// runtime and sync packages, "func file:line"
+	Site  string        `json:"site"`
+	Count int           `json:"count"`
+	Total time.Duration `json:"total_ns"`
+	Max   time.Duration `json:"max_ns"`
+}
+
+// ProcStat is time some goroutine was running on P, syscalls excluded
+type ProcStat struct {
+	P           int           `json:"p"`
+	Busy        time.Duration `json:"busy_ns"`
+	Utilization float64       `json:"utilization"`
+}
+
+type GCStat struct {
+	Cycles int           `json:"cycles"`
+	Time   time.Duration `json:"time_ns"`
+	// Pauses are stop-the-world pauses of any kind
+	Pauses Distribution `json:"pauses"`
+	ByKind []PauseStat  `json:"by_kind"`
+}
+
+type PauseStat struct {
+	Kind  string        `json:"kind"`
+	Count int           `json:"count"`
+	Total time.Duration `json:"total_ns"`
+}
+
+// Distribution describes a set of durations
+type Distribution struct {
+	Count int           `json:"count"`
+	Total time.Duration `json:"total_ns"`
+	Mean  time.Duration `json:"mean_ns"`
+	P50   time.Duration `json:"p50_ns"`
+	P90   time.Duration `json:"p90_ns"`
+	P99   time.Duration `json:"p99_ns"`
+	Max   time.Duration `json:"max_ns"`
+	// Buckets count durations up to Le, the last one is unbounded
+	Buckets []Bucket `json:"buckets"`
+}
+
+type Bucket struct {
+	// Le is upper bound, 0 for the last bucket
+	Le    time.Duration `json:"le_ns"`
+	Count int           `json:"count"`
+}
+
+var bucketBounds = []time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+func newDistribution(values []time.Duration) Distribution {
+	d := Distribution{Buckets: make([]Bucket, len(bucketBounds)+1)}
+	for i, le := range bucketBounds {
+		d.Buckets[i].Le = le
+	}
+	if len(values) == 0 {
+		return d
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	for _, v := range values {
+		d.Total += v
+		i := sort.Search(len(bucketBounds), func(i int) bool { return v <= bucketBounds[i] })
+		d.Buckets[i].Count++
+	}
+	// nearest rank
+	rank := func(p int) time.Duration {
+		return values[(len(values)*p+99)/100-1]
+	}
+	d.Count = len(values)
+	d.Mean = d.Total / time.Duration(len(values))
+	d.P50, d.P90, d.P99 = rank(50), rank(90), rank(99)
+	d.Max = values[len(values)-1]
+	return d
+}
+
+// Analyze reads trace written by runtime/trace and summarizes it
+func Analyze(r io.Reader) (*Report, error) {
+	t, err := parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return analyze(t), nil
+}
+
+// site returns the first frame outside of runtime internals
+func (t *rawTrace) site(gen, stack uint64) string {
+	frames := t.stacks[genKey{gen, stack}]
+	for _, f := range frames {
+		fn := t.strings[genKey{gen, f.fn}]
+		if isInternal(fn) {
+			continue
+		}
+		file := filepath.Base(t.strings[genKey{gen, f.file}])
+		return fn + " " + file + ":" + strconv.FormatUint(f.line, 10)
+	}
+	if len(frames) == 0 {
+		return "unknown"
+	}
+	return t.strings[genKey{gen, frames[0].fn}]
+}
+
+func isInternal(fn string) bool {
+	for _, prefix := range []string{"runtime.", "runtime/", "sync.", "sync/", "internal/", "time.Sleep"} {
+		if strings.HasPrefix(fn, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package tracestat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/trace"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// record returns trace of {work}
+func record(t *testing.T, work func()) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := trace.Start(&buf); err != nil {
+		t.Fatal(err)
+	}
+	work()
+	trace.Stop()
+	return buf.Bytes()
+}
+
+// holdLock keeps {mx} locked, so others block on it
+func holdLock(mx *sync.Mutex, started chan<- struct{}) {
+	mx.Lock()
+	close(started)
+	time.Sleep(20 * time.Millisecond)
+	mx.Unlock()
+}
+
+func waitLock(mx *sync.Mutex) {
+	mx.Lock()
+	mx.Unlock()
+}
+
+func waitChan(ch <-chan int) {
+	<-ch
+}
+
+func TestAnalyze(t *testing.T) {
+	data := record(t, func() {
+		mx := sync.Mutex{}
+		started := make(chan struct{})
+		wg := sync.WaitGroup{}
+		wg.Add(3)
+		go func() { defer wg.Done(); holdLock(&mx, started) }()
+		<-started
+		go func() { defer wg.Done(); waitLock(&mx) }()
+
+		ch := make(chan int)
+		go func() { defer wg.Done(); waitChan(ch) }()
+		time.Sleep(5 * time.Millisecond)
+		ch <- 1
+
+		wg.Wait()
+		runtime.GC()
+	})
+
+	r, err := Analyze(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.GoVersion < 22 || r.Duration < 20*time.Millisecond {
+		t.Errorf("wrong header: go 1.%d, duration %v", r.GoVersion, r.Duration)
+	}
+
+	find := func(reason, fn string) *BlockStat {
+		for i, b := range r.Blocking {
+			if b.Reason == reason && strings.Contains(b.Site, fn) {
+				return &r.Blocking[i]
+			}
+		}
+		return nil
+	}
+	if b := find("sync", "tracestat.waitLock"); b == nil || b.Total < 10*time.Millisecond {
+		t.Errorf("mutex wait is not found or too short: %+v", b)
+	}
+	if b := find("chan receive", "tracestat.waitChan"); b == nil || b.Total < 4*time.Millisecond {
+		t.Errorf("chan receive is not found or too short: %+v", b)
+	}
+	for i := 1; i < len(r.Blocking); i++ {
+		if r.Blocking[i].Total > r.Blocking[i-1].Total {
+			t.Errorf("blocking is not sorted by total")
+		}
+	}
+
+	if r.SchedLatency.Count == 0 || r.SchedLatency.P50 > r.SchedLatency.Max {
+		t.Errorf("wrong scheduling latency: %+v", r.SchedLatency)
+	}
+	if len(r.Procs) == 0 {
+		t.Errorf("no procs")
+	}
+	for _, p := range r.Procs {
+		if p.Utilization <= 0 || p.Utilization > 1 {
+			t.Errorf("P%d utilization is out of range: %v", p.P, p.Utilization)
+		}
+	}
+	if r.GC.Cycles == 0 || r.GC.Pauses.Count == 0 {
+		t.Errorf("GC is not found: %+v", r.GC)
+	}
+
+	var text bytes.Buffer
+	if err := r.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text.String(), "tracestat.waitLock") {
+		t.Errorf("text report has no blocking site:\n%s", text.String())
+	}
+	var decoded Report
+	js, _ := json.Marshal(r)
+	if err := json.Unmarshal(js, &decoded); err != nil || decoded.Duration != r.Duration {
+		t.Errorf("json round trip failed: %v", err)
+	}
+}
+
+func TestBadInput(t *testing.T) {
+	cases := map[string][]byte{
+		"empty":       nil,
+		"not a trace": []byte("hello, world! it's not a trace"),
+		"old version": []byte("go 1.21 trace\x00\x00\x00"),
+		"new version": []byte("go 1.99 trace\x00\x00\x00"),
+		"garbage":     append([]byte("go 1.26 trace\x00\x00\x00"), 0xff, 0xff),
+	}
+	for name, data := range cases {
+		if _, err := Analyze(bytes.NewReader(data)); !errors.Is(err, ErrFormat) {
+			t.Errorf("%s: got %v, expect ErrFormat", name, err)
+		}
+	}
+
+	_, err := Analyze(bytes.NewReader(cases["old version"]))
+	if msg := fmt.Sprint(err); !strings.Contains(msg, "unsupported trace version go1.21, supported: go1.22, go1.23") {
+		t.Errorf("old version: unclear error %q", msg)
+	}
+
+	data := record(t, func() {})
+	if _, err := Analyze(bytes.NewReader(data[:len(data)/2])); err == nil {
+		t.Errorf("truncated trace: no error")
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	var values []time.Duration
+	for i := 100; i >= 1; i-- {
+		values = append(values, time.Duration(i)*time.Millisecond)
+	}
+	d := newDistribution(values)
+	if d.Count != 100 || d.P50 != 50*time.Millisecond || d.P90 != 90*time.Millisecond ||
+		d.P99 != 99*time.Millisecond || d.Max != 100*time.Millisecond {
+		t.Errorf("wrong percentiles: %+v", d)
+	}
+	// 1ms, 2..10ms, 11..100ms
+	if d.Buckets[3].Count != 1 || d.Buckets[4].Count != 9 || d.Buckets[5].Count != 90 {
+		t.Errorf("wrong buckets: %+v", d.Buckets)
+	}
+	if empty := newDistribution(nil); empty.Count != 0 || len(empty.Buckets) != len(bucketBounds)+1 {
+		t.Errorf("wrong empty distribution: %+v", empty)
+	}
+}